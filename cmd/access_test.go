@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"testing"
+
+	"github.com/naveeshkumar24/internal/models"
+)
+
+// promote gives a user a global role and signs them in again so that the
+// new role is in their token.
+func (a *testAPI) promote(s session, username, role string) session {
+	a.t.Helper()
+	if err := a.repos.Users.ForOrg(s.User.OrgID).UpdateRole(s.User.ID, role); err != nil {
+		a.t.Fatalf("UpdateRole: %v", err)
+	}
+	var fresh session
+	a.expect(a.do("POST", "/api/v2/auth/login", "", map[string]string{"email": username + "@example.com", "password": "secret123"}), http.StatusOK, &fresh)
+	return fresh
+}
+
+func (a *testAPI) createProject(token, key string) int {
+	a.t.Helper()
+	var project struct {
+		ID int `json:"id"`
+	}
+	a.expect(a.do("POST", "/api/v2/projects", token, map[string]string{"key": key, "name": key + " project"}), http.StatusCreated, &project)
+	return project.ID
+}
+
+func (a *testAPI) setMember(token string, projectID, userID int, role string) {
+	a.t.Helper()
+	path := fmt.Sprintf("/api/v2/projects/%d/members/%d", projectID, userID)
+	a.expect(a.do("PUT", path, token, map[string]string{"role": role}), http.StatusOK, nil)
+}
+
+// dashboardTasks returns the IDs of every task on a user's dashboard.
+func (a *testAPI) dashboardTasks(token string, userID int) []int {
+	a.t.Helper()
+	var dashboard struct {
+		ByStatus map[string][]taskResponse `json:"by_status"`
+	}
+	a.expect(a.do("GET", fmt.Sprintf("/api/v2/users/%d/dashboard", userID), token, nil), http.StatusOK, &dashboard)
+	var ids []int
+	for _, tasks := range dashboard.ByStatus {
+		for _, task := range tasks {
+			ids = append(ids, task.ID)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func TestDashboardUsesProjectRoles(t *testing.T) {
+	api := newTestAPI(t)
+	manager := api.promote(api.signUp("manny", ""), "manny", models.RoleManager)
+	bob := api.signUp("bob", "")
+	pam := api.signUp("pam", "")
+	carol := api.signUp("carol", "")
+
+	ops := api.createProject(manager.Token, "OPS")
+	web := api.createProject(manager.Token, "WEB")
+	api.setMember(manager.Token, ops, bob.User.ID, models.RoleUser)
+	api.setMember(manager.Token, web, bob.User.ID, models.RoleUser)
+	api.setMember(manager.Token, ops, pam.User.ID, models.RoleAdmin)
+
+	inOps := api.createTask(bob.Token, map[string]any{"project_id": ops})
+	inWeb := api.createTask(bob.Token, map[string]any{"project_id": web})
+	loose := api.createTask(bob.Token, nil)
+
+	if got, want := api.dashboardTasks(bob.Token, bob.User.ID), []int{inOps.ID, inWeb.ID, loose.ID}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("own dashboard = %v, want %v", got, want)
+	}
+
+	// Pam is a plain user globally but admin of OPS, so she sees Bob's OPS
+	// task and nothing else
+	if got, want := api.dashboardTasks(pam.Token, bob.User.ID), []int{inOps.ID}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("project admin's view = %v, want %v", got, want)
+	}
+
+	// Carol holds no role that may view other dashboards
+	api.expect(api.do("GET", fmt.Sprintf("/api/v2/users/%d/dashboard", bob.User.ID), carol.Token, nil), http.StatusForbidden, nil)
+
+	// A global manager sees tasks outside projects and in the projects they
+	// belong to: all of them for the manager who created both projects, only
+	// the loose task for one who belongs to neither
+	if got, want := api.dashboardTasks(manager.Token, bob.User.ID), []int{inOps.ID, inWeb.ID, loose.ID}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("project creator's view = %v, want %v", got, want)
+	}
+	outsider := api.promote(api.signUp("mia", ""), "mia", models.RoleManager)
+	if got, want := api.dashboardTasks(outsider.Token, bob.User.ID), []int{loose.ID}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("outside manager's view = %v, want %v", got, want)
+	}
+
+	// Once removed from WEB, Bob no longer sees its tasks anywhere
+	api.expect(api.do("DELETE", fmt.Sprintf("/api/v2/projects/%d/members/%d", web, bob.User.ID), manager.Token, nil), http.StatusNoContent, nil)
+	if got, want := api.dashboardTasks(bob.Token, bob.User.ID), []int{inOps.ID, loose.ID}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("own dashboard after removal = %v, want %v", got, want)
+	}
+	api.expect(api.do("GET", fmt.Sprintf("/api/v2/tasks/%d", inWeb.ID), bob.Token, nil), http.StatusNotFound, nil)
+}
+
+func TestProjectAdminManagesTasksWithoutGlobalRole(t *testing.T) {
+	api := newTestAPI(t)
+	manager := api.promote(api.signUp("manny", ""), "manny", models.RoleManager)
+	bob := api.signUp("bob", "")
+	pam := api.signUp("pam", "")
+
+	ops := api.createProject(manager.Token, "OPS")
+	api.setMember(manager.Token, ops, bob.User.ID, models.RoleUser)
+	api.setMember(manager.Token, ops, pam.User.ID, models.RoleAdmin)
+
+	task := api.createTask(bob.Token, map[string]any{"project_id": ops})
+	path := fmt.Sprintf("/api/v2/tasks/%d", task.ID)
+
+	// As project admin Pam may edit and delete Bob's task; Bob may not touch
+	// hers outside what his project role allows
+	api.expect(api.do("PATCH", path, pam.Token, []byte(`{"priority":"high"}`), "Content-Type", "application/merge-patch+json"), http.StatusOK, nil)
+	other := api.createTask(pam.Token, map[string]any{"project_id": ops})
+	api.expect(api.do("DELETE", fmt.Sprintf("/api/v2/tasks/%d", other.ID), bob.Token, nil), http.StatusForbidden, nil)
+	api.expect(api.do("DELETE", path, pam.Token, nil), http.StatusNoContent, nil)
+}
@@ -2,18 +2,23 @@ package main
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
-	"os"
 
 	_ "github.com/lib/pq"
+	"github.com/naveeshkumar24/internal/models"
+	"github.com/naveeshkumar24/pkg/blob"
+	"github.com/naveeshkumar24/pkg/config"
+	"github.com/naveeshkumar24/pkg/database"
+	"github.com/naveeshkumar24/repository"
 )
 
 type Connection struct {
 	DB *sql.DB
 }
 
-func NewConnection() *Connection {
-	conn, err := sql.Open("postgres", os.Getenv("DB_URL"))
+func NewConnection(dbURL string) *Connection {
+	conn, err := sql.Open("postgres", dbURL)
 	if err != nil {
 		log.Fatalf("unable to create database")
 	}
@@ -21,3 +26,116 @@ func NewConnection() *Connection {
 		DB: conn,
 	}
 }
+
+// Repositories bundles the storage implementations the handlers depend on.
+type Repositories struct {
+	Tasks       models.TaskInterface
+	Users       models.UserInterface
+	Tokens      models.TokenInterface
+	Comments    models.CommentInterface
+	Attachments models.AttachmentInterface
+	Projects    models.ProjectInterface
+	Teams       models.TeamInterface
+	Memberships models.MembershipInterface
+
+	// Orgs is not scoped; the others serve one organization through ForOrg.
+	Orgs models.OrganizationInterface
+
+	// Blobs holds attachment content for either metadata backend.
+	Blobs blob.Store
+
+	close func() error
+}
+
+func (r *Repositories) Close() error {
+	if r.close == nil {
+		return nil
+	}
+	return r.close()
+}
+
+// NewRepositories builds the repositories for the configured storage backend.
+func NewRepositories(cfg *config.Config) (*Repositories, error) {
+	if cfg.Storage == config.StorageMemory {
+		log.Println("using in-memory storage; data will not survive a restart")
+		store := repository.NewMemoryStore()
+		blobs, err := NewBlobStore(cfg.Blob)
+		if err != nil {
+			return nil, err
+		}
+		return &Repositories{
+			Tasks:       repository.NewMemoryTaskRepository(store),
+			Users:       repository.NewMemoryUserRepository(store),
+			Tokens:      repository.NewMemoryTokenRepository(store),
+			Comments:    repository.NewMemoryCommentRepository(store),
+			Attachments: repository.NewMemoryAttachmentRepository(store),
+			Projects:    repository.NewMemoryProjectRepository(store),
+			Teams:       repository.NewMemoryTeamRepository(store),
+			Memberships: repository.NewMemoryMembershipRepository(store),
+			Orgs:        repository.NewMemoryOrganizationRepository(store),
+			Blobs:       blobs,
+		}, nil
+	}
+
+	blobs, err := NewBlobStore(cfg.Blob)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without row-level security the service relies on the org_id
+	// conditions of its queries and every connection bypasses the policies,
+	// which deny all rows by default
+	dsn := cfg.DB
+	if !cfg.RowLevelSecurity {
+		if dsn, err = database.BypassRowLevelSecurity(dsn); err != nil {
+			return nil, fmt.Errorf("invalid DB_URL: %w", err)
+		}
+	}
+
+	conn := NewConnection(dsn)
+	if cfg.MigrateOnStart {
+		migrator, err := database.NewMigrator(conn.DB)
+		if err != nil {
+			conn.DB.Close()
+			return nil, err
+		}
+		if _, err := migrator.Up(); err != nil {
+			conn.DB.Close()
+			return nil, err
+		}
+	}
+
+	database.SetRowLevelSecurity(cfg.RowLevelSecurity)
+	if cfg.RowLevelSecurity {
+		log.Println("row-level security enabled for organization-scoped queries")
+	}
+
+	return &Repositories{
+		Tasks:       repository.NewTaskRepository(conn.DB),
+		Users:       repository.NewUserRepository(conn.DB),
+		Tokens:      repository.NewTokenRepository(conn.DB),
+		Comments:    repository.NewCommentRepository(conn.DB),
+		Attachments: repository.NewAttachmentRepository(conn.DB),
+		Projects:    repository.NewProjectRepository(conn.DB),
+		Teams:       repository.NewTeamRepository(conn.DB),
+		Memberships: repository.NewMembershipRepository(conn.DB),
+		Orgs:        repository.NewOrganizationRepository(conn.DB),
+		Blobs:       blobs,
+		close:       conn.DB.Close,
+	}, nil
+}
+
+// NewBlobStore builds the blob store for the configured backend.
+func NewBlobStore(cfg config.BlobConfig) (blob.Store, error) {
+	if cfg.Backend == config.BlobS3 {
+		return blob.NewS3(blob.S3Config{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.S3Bucket,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			PathStyle:       cfg.S3PathStyle,
+		})
+	}
+	return blob.NewLocal(cfg.Dir)
+}
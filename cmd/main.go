@@ -4,10 +4,13 @@ import (
 	"log"
 	"net/http"
 	"os"
+	_ "time/tzdata" // embed the zone database so user time zones never depend on the host
 
 	"github.com/joho/godotenv"
 	"github.com/naveeshkumar24/internal/middleware"
-	"github.com/naveeshkumar24/pkg/database"
+	"github.com/naveeshkumar24/internal/workflow"
+	"github.com/naveeshkumar24/pkg/config"
+	"github.com/naveeshkumar24/pkg/utils"
 )
 
 func main() {
@@ -15,20 +18,45 @@ func main() {
 	if err := godotenv.Load(".env"); err != nil {
 		log.Fatalf("unable to load env: %v", err)
 	}
-	conn := NewConnection()
-	defer conn.DB.Close()
-	server := &http.Server{
-		Addr:    ":" + os.Getenv("PORT"),
-		Handler: middleware.CorsMiddleware(registerTaskRouter(conn.DB)),
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, os.Args[2:]); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("refusing to start: %v", err)
+	}
+	if cfg.IsDev() {
+		log.Printf("running in %s mode", cfg.Env)
 	}
-	query := database.NewQuery(conn.DB)
-	err := query.CreateTaskTables()
+	utils.ConfigureJWT(cfg.JWT)
+	utils.ConfigureDecoding(cfg.MaxBodyBytes)
+
+	machine, err := workflow.Load(cfg.WorkflowFile)
+	if err != nil {
+		log.Fatalf("invalid task workflow: %v", err)
+	}
+	machine.RequireSubtasksDone = cfg.RequireSubtasksDone
+
+	repos, err := NewRepositories(cfg)
 	if err != nil {
 		log.Fatalf("Unable to create database: %v", err)
+	}
+	defer repos.Close()
 
+	server := &http.Server{
+		Addr:    ":" + cfg.Port,
+		Handler: middleware.CorsMiddleware(registerTaskRouter(repos, machine, cfg)),
 	}
 
-	log.Printf("server is running at port %s", os.Getenv("PORT"))
+	log.Printf("server is running at port %s", cfg.Port)
 	err = server.ListenAndServe()
 	if err != nil {
 		log.Fatalf("unable to start the server: %v", err)
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/naveeshkumar24/pkg/config"
+	"github.com/naveeshkumar24/pkg/database"
+)
+
+const migrateUsage = "usage: migrate status | migrate up | migrate down N"
+
+// runMigrate implements the "migrate" subcommand, which manages the schema
+// without starting the HTTP server.
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(migrateUsage)
+	}
+
+	// Migrations move rows of every organization, so they always run past
+	// the row-level security policies
+	dsn, err := database.BypassRowLevelSecurity(cfg.DB)
+	if err != nil {
+		return fmt.Errorf("invalid DB_URL: %w", err)
+	}
+	conn := NewConnection(dsn)
+	defer conn.DB.Close()
+
+	migrator, err := database.NewMigrator(conn.DB)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Printf("%04d  %-40s %s\n", status.Version, status.Name, applied)
+		}
+		return nil
+
+	case "up":
+		count, err := migrator.Up()
+		if err != nil {
+			return err
+		}
+		log.Printf("%d migration(s) applied", count)
+		return nil
+
+	case "down":
+		if len(args) != 2 {
+			return fmt.Errorf(migrateUsage)
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid migration count %q", args[1])
+		}
+		count, err := migrator.Down(n)
+		if err != nil {
+			return err
+		}
+		log.Printf("%d migration(s) rolled back", count)
+		return nil
+	}
+
+	return fmt.Errorf("unknown migrate command %q; %s", args[0], migrateUsage)
+}
@@ -1,35 +1,188 @@
 package main
 
 import (
-	"database/sql"
+	"net/http"
+
 	"github.com/gorilla/mux"
+	"github.com/naveeshkumar24/internal/apierror"
 	"github.com/naveeshkumar24/internal/handlers"
 	"github.com/naveeshkumar24/internal/middleware"
-	"github.com/naveeshkumar24/repository"
+	"github.com/naveeshkumar24/internal/rbac"
+	"github.com/naveeshkumar24/internal/validation"
+	"github.com/naveeshkumar24/internal/workflow"
+	"github.com/naveeshkumar24/pkg/config"
 )
 
-func registerTaskRouter(db *sql.DB) *mux.Router {
+func registerTaskRouter(repos *Repositories, machine *workflow.Machine, cfg *config.Config) *mux.Router {
 	router := mux.NewRouter()
 	router.Use(middleware.CorsMiddleware)
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apierror.Write(w, r, apierror.NotFound("No route matches "+r.URL.Path))
+	})
+	router.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apierror.Write(w, r, apierror.New(http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, r.Method+" is not supported here"))
+	})
+
+	validator := validation.New(repos.Users)
+	access := handlers.NewProjectAccess(repos.Tasks, repos.Memberships)
+	taskHandler := handlers.NewTaskHandler(repos.Tasks, repos.Users, access, machine, validator)
+	userHandler := handlers.NewUserHandler(repos.Users, repos.Orgs, repos.Memberships, repos.Tokens, validator, cfg.AllowOrgSignup)
+	commentHandler := handlers.NewCommentHandler(repos.Comments, access, validator)
+	attachmentHandler := handlers.NewAttachmentHandler(repos.Attachments, access, repos.Blobs, cfg.Attachments.MaxBytes, cfg.Attachments.AllowedTypes)
+
+	projectHandler := handlers.NewProjectHandler(repos.Projects, repos.Memberships, access, validator)
+	membershipHandler := handlers.NewMembershipHandler(repos.Memberships, repos.Projects, access, validator)
+	teamHandler := handlers.NewTeamHandler(repos.Teams, validator)
+	orgHandler := handlers.NewOrganizationHandler(repos.Orgs)
 
-	taskRepo := repository.NewTaskRepository(db)
-	taskHandler := handlers.NewTaskHandler(taskRepo)
+	registerV2Routes(router.PathPrefix("/api/v2").Subrouter(), v2Handlers{
+		tasks:       taskHandler,
+		users:       userHandler,
+		comments:    commentHandler,
+		attachments: attachmentHandler,
+		projects:    projectHandler,
+		members:     membershipHandler,
+		teams:       teamHandler,
+		orgs:        orgHandler,
+	})
 
-	userRepo := repository.NewUserRepository(db)
-	userHandler := handlers.NewUserHandler(userRepo)
+	// The original RPC-style routes stay available but are deprecated in
+	// favour of /api/v2
+	v1 := router.NewRoute().Subrouter()
+	v1.Use(middleware.Deprecation)
 
-	// Task routes
-	router.HandleFunc("/task/create", taskHandler.CreateTask).Methods("POST")
-	router.HandleFunc("/task/get/{id}", taskHandler.GetTask).Methods("GET")
-	router.HandleFunc("/task/update", taskHandler.UpdateTask).Methods("POST")
-	router.HandleFunc("/task/delete/{id}", taskHandler.DeleteTask).Methods("POST")
-	router.HandleFunc("/task/list", taskHandler.ListTasks).Methods("GET")
-	router.HandleFunc("/task/dashboard/{userID}", taskHandler.GetDashboard).Methods("GET")
+	// Public routes
+	v1.HandleFunc("/user/register", userHandler.RegisterUser).Methods("POST")
+	v1.HandleFunc("/user/login", userHandler.LoginUser).Methods("POST")
+	v1.HandleFunc("/user/token/refresh", userHandler.RefreshToken).Methods("POST")
+	v1.HandleFunc("/user/logout", userHandler.Logout).Methods("POST")
+
+	// Everything below requires a valid access token
+	protected := v1.NewRoute().Subrouter()
+	protected.Use(middleware.AuthMiddleware)
+
+	// Task routes; permissions depend on the task's project and are checked
+	// by the handlers
+	protected.HandleFunc("/task/create", taskHandler.CreateTask).Methods("POST")
+	protected.HandleFunc("/task/get/{id}", taskHandler.GetTask).Methods("GET")
+	protected.HandleFunc("/task/update", taskHandler.UpdateTask).Methods("POST")
+	protected.HandleFunc("/tasks/{id}", taskHandler.PatchTask).Methods("PATCH")
+	protected.HandleFunc("/task/delete/{id}", taskHandler.DeleteTask).Methods("POST")
+	protected.HandleFunc("/task/list", taskHandler.ListTasks).Methods("GET")
+	protected.HandleFunc("/task/search", taskHandler.SearchTasks).Methods("GET")
+	protected.HandleFunc("/task/dashboard/{userID}", taskHandler.GetDashboard).Methods("GET")
 
 	// User routes
-	router.HandleFunc("/user/register", userHandler.RegisterUser).Methods("POST")
-	router.HandleFunc("/user/login", userHandler.LoginUser).Methods("POST")
-	router.HandleFunc("/user/get/{id}", userHandler.GetUserByID).Methods("GET")
+	protected.HandleFunc("/user/get/{id}", userHandler.GetUserByID).Methods("GET")
+	protected.HandleFunc("/user/logout/all", userHandler.LogoutAll).Methods("POST")
+	protected.HandleFunc("/user/timezone", userHandler.UpdateTimezone).Methods("PUT")
+	protected.Handle("/user/{id}/role", requirePermission(rbac.ActionRoleManage, userHandler.UpdateUserRole)).Methods("PUT")
 
 	return router
 }
+
+// v2Handlers bundles the handlers served under /api/v2.
+type v2Handlers struct {
+	tasks       *handlers.TaskHandler
+	users       *handlers.UserHandler
+	comments    *handlers.CommentHandler
+	attachments *handlers.AttachmentHandler
+	projects    *handlers.ProjectHandler
+	members     *handlers.MembershipHandler
+	teams       *handlers.TeamHandler
+	orgs        *handlers.OrganizationHandler
+}
+
+// registerV2Routes mounts the resource-oriented API on the /api/v2 subrouter.
+func registerV2Routes(v2 *mux.Router, h v2Handlers) {
+	// Public routes
+	v2.HandleFunc("/users", h.users.RegisterUserV2).Methods("POST")
+	v2.HandleFunc("/auth/login", h.users.LoginUser).Methods("POST")
+	v2.HandleFunc("/auth/refresh", h.users.RefreshToken).Methods("POST")
+	v2.HandleFunc("/auth/logout", h.users.Logout).Methods("POST")
+
+	protected := v2.NewRoute().Subrouter()
+	protected.Use(middleware.AuthMiddleware)
+
+	// Task routes; /tasks/search is registered before /tasks/{id} so that it
+	// is not taken for an ID. Permissions depend on the task's project and
+	// are checked by the handlers against the caller's role there.
+	protected.HandleFunc("/tasks", h.tasks.ListTasks).Methods("GET")
+	protected.HandleFunc("/tasks", h.tasks.CreateTaskV2).Methods("POST")
+	protected.HandleFunc("/tasks/search", h.tasks.SearchTasks).Methods("GET")
+	protected.HandleFunc("/tasks/{id:[0-9]+}", h.tasks.GetTask).Methods("GET")
+	protected.HandleFunc("/tasks/{key:[A-Z][A-Z0-9]+-[0-9]+}", h.tasks.GetTask).Methods("GET")
+	protected.HandleFunc("/tasks/{id:[0-9]+}", h.tasks.PatchTask).Methods("PATCH")
+	protected.HandleFunc("/tasks/{id:[0-9]+}", h.tasks.ReplaceTaskV2).Methods("PUT")
+	protected.HandleFunc("/tasks/{id:[0-9]+}", h.tasks.DeleteTaskV2).Methods("DELETE")
+	protected.HandleFunc("/tasks/{id:[0-9]+}/subtasks", h.tasks.ListSubtasksV2).Methods("GET")
+	protected.HandleFunc("/tasks/{id:[0-9]+}/subtree", h.tasks.GetSubtreeV2).Methods("GET")
+	protected.HandleFunc("/tasks/{id:[0-9]+}/dependencies", h.tasks.ListDependenciesV2).Methods("GET")
+	protected.HandleFunc("/tasks/{id:[0-9]+}/dependencies/{dependsOnID:[0-9]+}", h.tasks.AddDependencyV2).Methods("PUT")
+	protected.HandleFunc("/tasks/{id:[0-9]+}/dependencies/{dependsOnID:[0-9]+}", h.tasks.RemoveDependencyV2).Methods("DELETE")
+
+	// Comment routes
+	protected.HandleFunc("/tasks/{id:[0-9]+}/comments", h.comments.ListComments).Methods("GET")
+	protected.HandleFunc("/tasks/{id:[0-9]+}/comments", h.comments.CreateComment).Methods("POST")
+	protected.HandleFunc("/comments/{id:[0-9]+}", h.comments.GetComment).Methods("GET")
+	protected.HandleFunc("/comments/{id:[0-9]+}", h.comments.UpdateComment).Methods("PATCH")
+	protected.HandleFunc("/comments/{id:[0-9]+}", h.comments.DeleteComment).Methods("DELETE")
+	protected.HandleFunc("/comments/{id:[0-9]+}/replies", h.comments.ListReplies).Methods("GET")
+
+	// Attachment routes
+	protected.HandleFunc("/tasks/{id:[0-9]+}/attachments", h.attachments.ListAttachments).Methods("GET")
+	protected.HandleFunc("/tasks/{id:[0-9]+}/attachments", h.attachments.UploadAttachment).Methods("POST")
+	protected.HandleFunc("/tasks/{id:[0-9]+}/attachments/{attachmentID:[0-9]+}", h.attachments.DownloadAttachment).Methods("GET", "HEAD")
+	protected.HandleFunc("/tasks/{id:[0-9]+}/attachments/{attachmentID:[0-9]+}", h.attachments.DeleteAttachment).Methods("DELETE")
+
+	// Project routes; everything below /projects/{projectID} answers 404 for
+	// unknown projects and for projects the caller is not a member of. Roles
+	// inside a project are checked by the handlers against the caller's
+	// project role, not the global one.
+	protected.HandleFunc("/projects", h.projects.ListProjects).Methods("GET")
+	protected.Handle("/projects", requirePermission(rbac.ActionProjectManage, h.projects.CreateProject)).Methods("POST")
+	protected.HandleFunc("/projects/{projectID:[0-9]+}", h.projects.GetProject).Methods("GET")
+	protected.HandleFunc("/projects/{projectID:[0-9]+}", h.projects.UpdateProject).Methods("PUT")
+	protected.HandleFunc("/projects/{projectID:[0-9]+}", h.projects.DeleteProject).Methods("DELETE")
+	protected.HandleFunc("/projects/{projectID:[0-9]+}/archive", h.projects.ArchiveProject).Methods("PUT")
+	protected.HandleFunc("/projects/{projectID:[0-9]+}/archive", h.projects.RestoreProject).Methods("DELETE")
+	protected.Handle("/projects/{projectID:[0-9]+}/tasks", h.projects.Scoped(http.HandlerFunc(h.tasks.SearchTasks))).Methods("GET")
+	protected.Handle("/projects/{projectID:[0-9]+}/tasks", h.projects.Scoped(http.HandlerFunc(h.tasks.CreateTaskV2))).Methods("POST")
+	protected.Handle("/projects/{projectID:[0-9]+}/dashboard", h.projects.Scoped(http.HandlerFunc(h.tasks.GetProjectDashboard))).Methods("GET")
+	protected.Handle("/projects/{projectID:[0-9]+}/critical-path", h.projects.Scoped(http.HandlerFunc(h.tasks.GetCriticalPath))).Methods("GET")
+
+	// Project membership routes
+	protected.Handle("/projects/{projectID:[0-9]+}/members", h.projects.Scoped(http.HandlerFunc(h.members.ListMembers))).Methods("GET")
+	protected.Handle("/projects/{projectID:[0-9]+}/members/{userID:[0-9]+}", h.projects.Scoped(http.HandlerFunc(h.members.SetMember))).Methods("PUT")
+	protected.Handle("/projects/{projectID:[0-9]+}/members/{userID:[0-9]+}", h.projects.Scoped(http.HandlerFunc(h.members.RemoveMember))).Methods("DELETE")
+	protected.Handle("/projects/{projectID:[0-9]+}/teams", h.projects.Scoped(http.HandlerFunc(h.members.ListTeams))).Methods("GET")
+	protected.Handle("/projects/{projectID:[0-9]+}/teams/{teamID:[0-9]+}", h.projects.Scoped(http.HandlerFunc(h.members.SetTeam))).Methods("PUT")
+	protected.Handle("/projects/{projectID:[0-9]+}/teams/{teamID:[0-9]+}", h.projects.Scoped(http.HandlerFunc(h.members.RemoveTeam))).Methods("DELETE")
+	protected.Handle("/projects/{projectID:[0-9]+}/invitations", h.projects.Scoped(http.HandlerFunc(h.members.ListInvitations))).Methods("GET")
+	protected.Handle("/projects/{projectID:[0-9]+}/invitations", h.projects.Scoped(http.HandlerFunc(h.members.CreateInvitation))).Methods("POST")
+	protected.Handle("/projects/{projectID:[0-9]+}/invitations/{invitationID:[0-9]+}", h.projects.Scoped(http.HandlerFunc(h.members.DeleteInvitation))).Methods("DELETE")
+	protected.HandleFunc("/invitations/accept", h.members.AcceptInvitation).Methods("POST")
+
+	// Team routes
+	protected.HandleFunc("/teams", h.teams.ListTeams).Methods("GET")
+	protected.Handle("/teams", requirePermission(rbac.ActionTeamManage, h.teams.CreateTeam)).Methods("POST")
+	protected.HandleFunc("/teams/{teamID:[0-9]+}", h.teams.GetTeam).Methods("GET")
+	protected.Handle("/teams/{teamID:[0-9]+}", requirePermission(rbac.ActionTeamManage, h.teams.DeleteTeam)).Methods("DELETE")
+	protected.HandleFunc("/teams/{teamID:[0-9]+}/members", h.teams.ListMembers).Methods("GET")
+	protected.Handle("/teams/{teamID:[0-9]+}/members/{userID:[0-9]+}", requirePermission(rbac.ActionTeamManage, h.teams.AddMember)).Methods("PUT")
+	protected.Handle("/teams/{teamID:[0-9]+}/members/{userID:[0-9]+}", requirePermission(rbac.ActionTeamManage, h.teams.RemoveMember)).Methods("DELETE")
+
+	// Organization routes
+	protected.HandleFunc("/organization", h.orgs.GetOrganization).Methods("GET")
+
+	// User routes
+	protected.HandleFunc("/users/me/timezone", h.users.UpdateTimezone).Methods("PUT")
+	protected.HandleFunc("/users/me/sessions", h.users.LogoutAll).Methods("DELETE")
+	protected.HandleFunc("/users/{id:[0-9]+}", h.users.GetUserByID).Methods("GET")
+	protected.HandleFunc("/users/{userID:[0-9]+}/dashboard", h.tasks.GetDashboard).Methods("GET")
+	protected.Handle("/users/{id:[0-9]+}/role", requirePermission(rbac.ActionRoleManage, h.users.UpdateUserRole)).Methods("PUT")
+}
+
+func requirePermission(action rbac.Action, handler http.HandlerFunc) http.Handler {
+	return middleware.RequirePermission(action)(handler)
+}
@@ -0,0 +1,469 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/naveeshkumar24/internal/models"
+	"github.com/naveeshkumar24/internal/workflow"
+	"github.com/naveeshkumar24/pkg/config"
+	"github.com/naveeshkumar24/pkg/utils"
+)
+
+// testAPI serves the full router on the memory backend.
+type testAPI struct {
+	t       *testing.T
+	handler http.Handler
+	repos   *Repositories
+}
+
+// newTestAPI builds the API with the default configuration, in which
+// everyone signs up into the default organization; options may change it.
+func newTestAPI(t *testing.T, options ...func(*config.Config)) *testAPI {
+	t.Helper()
+	cfg := &config.Config{
+		Env:     "dev",
+		Storage: config.StorageMemory,
+		JWT: config.JWTConfig{
+			Keys:       map[string][]byte{"test": []byte("test-secret-that-is-at-least-32-bytes")},
+			ActiveKID:  "test",
+			TTL:        15 * time.Minute,
+			RefreshTTL: time.Hour,
+		},
+		MaxBodyBytes: config.DefaultMaxBodyBytes,
+		Blob:         config.BlobConfig{Backend: config.BlobLocal, Dir: t.TempDir()},
+		Attachments: config.AttachmentConfig{
+			MaxBytes:     config.DefaultMaxAttachmentBytes,
+			AllowedTypes: config.DefaultAttachmentTypes,
+		},
+	}
+	for _, option := range options {
+		option(cfg)
+	}
+	utils.ConfigureJWT(cfg.JWT)
+	utils.ConfigureDecoding(cfg.MaxBodyBytes)
+
+	repos, err := NewRepositories(cfg)
+	if err != nil {
+		t.Fatalf("NewRepositories: %v", err)
+	}
+	t.Cleanup(func() { repos.Close() })
+
+	return &testAPI{t: t, handler: registerTaskRouter(repos, workflow.Default(), cfg), repos: repos}
+}
+
+// withOrgSignup lets users found organizations at sign-up.
+func withOrgSignup(cfg *config.Config) { cfg.AllowOrgSignup = true }
+
+// do sends body, encoded as JSON unless it is already a []byte, and returns
+// the recorded response. Extra headers are given as name, value pairs.
+func (a *testAPI) do(method, path, token string, body any, headers ...string) *httptest.ResponseRecorder {
+	a.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		raw, ok := body.([]byte)
+		if !ok {
+			var err error
+			if raw, err = json.Marshal(body); err != nil {
+				a.t.Fatalf("encode %s %s body: %v", method, path, err)
+			}
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	a.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// expect fails the test unless rec has the wanted status, and decodes the
+// body into out when out is not nil.
+func (a *testAPI) expect(rec *httptest.ResponseRecorder, status int, out any) {
+	a.t.Helper()
+	if rec.Code != status {
+		a.t.Fatalf("got status %d, want %d; body: %s", rec.Code, status, rec.Body.String())
+	}
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			a.t.Fatalf("decode response %q: %v", rec.Body.String(), err)
+		}
+	}
+}
+
+type session struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	User         struct {
+		ID    int    `json:"id"`
+		Role  string `json:"role"`
+		OrgID int    `json:"org_id"`
+	} `json:"user"`
+}
+
+// signUp registers a user, founding organization when it is not empty, and
+// logs them in.
+func (a *testAPI) signUp(username, organization string) session {
+	a.t.Helper()
+	email := username + "@example.com"
+	body := map[string]string{"username": username, "email": email, "password": "secret123"}
+	if organization != "" {
+		body["organization"] = organization
+	}
+	rec := a.do("POST", "/api/v2/users", "", body)
+	var user struct {
+		ID       int    `json:"id"`
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	a.expect(rec, http.StatusCreated, &user)
+	if want := fmt.Sprintf("/api/v2/users/%d", user.ID); rec.Header().Get("Location") != want {
+		a.t.Errorf("Location = %q, want %q", rec.Header().Get("Location"), want)
+	}
+	if user.Email != email || user.Password != "" {
+		a.t.Errorf("created user = %+v", user)
+	}
+
+	var s session
+	a.expect(a.do("POST", "/api/v2/auth/login", "", map[string]string{"email": email, "password": "secret123"}), http.StatusOK, &s)
+	if s.User.ID != user.ID {
+		a.t.Errorf("logged in as user %d, registered %d", s.User.ID, user.ID)
+	}
+	return s
+}
+
+type taskResponse struct {
+	ID        int    `json:"id"`
+	Title     string `json:"title"`
+	Status    string `json:"status"`
+	Priority  string `json:"priority"`
+	Version   int    `json:"version"`
+	CreatedBy int    `json:"created_by"`
+	ProjectID int    `json:"project_id"`
+}
+
+func (a *testAPI) createTask(token string, body map[string]any) taskResponse {
+	a.t.Helper()
+	task := map[string]any{"title": "Write report", "status": "todo", "priority": "medium"}
+	for k, v := range body {
+		task[k] = v
+	}
+	var created taskResponse
+	a.expect(a.do("POST", "/api/v2/tasks", token, task), http.StatusCreated, &created)
+	return created
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	api := newTestAPI(t)
+
+	s := api.signUp("alice", "")
+	if s.Token == "" || s.RefreshToken == "" {
+		t.Fatalf("login returned no tokens: %+v", s)
+	}
+	if s.User.Role != "user" {
+		t.Errorf("self-registered role = %q, want user", s.User.Role)
+	}
+
+	// The same e-mail cannot register twice
+	dup := map[string]string{"username": "alice2", "email": "alice@example.com", "password": "secret123"}
+	if rec := api.do("POST", "/api/v2/users", "", dup); rec.Code != http.StatusConflict {
+		t.Errorf("duplicate registration: got %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	// v1 keeps answering with a message only
+	legacy := map[string]string{"username": "carol", "email": "carol@example.com", "password": "secret123"}
+	var message map[string]string
+	api.expect(api.do("POST", "/user/register", "", legacy), http.StatusOK, &message)
+	if message["message"] != "Registration successful" {
+		t.Errorf("v1 registration = %v", message)
+	}
+
+	weak := map[string]string{"username": "bob", "email": "bob@example.com", "password": "short"}
+	if rec := api.do("POST", "/api/v2/users", "", weak); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("weak password: got %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+
+	wrong := map[string]string{"email": "alice@example.com", "password": "wrong-password1"}
+	if rec := api.do("POST", "/api/v2/auth/login", "", wrong); rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong password: got %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	if rec := api.do("GET", "/api/v2/tasks", "", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("request without token: got %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if rec := api.do("GET", "/api/v2/tasks", s.Token, nil); rec.Code != http.StatusOK {
+		t.Errorf("request with token: got %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	api := newTestAPI(t)
+	s := api.signUp("alice", "")
+
+	var refreshed session
+	api.expect(api.do("POST", "/api/v2/auth/refresh", "", map[string]string{"refresh_token": s.RefreshToken}), http.StatusOK, &refreshed)
+	if refreshed.Token == "" || refreshed.RefreshToken == "" {
+		t.Fatalf("refresh returned no tokens: %s", refreshed.Token)
+	}
+	if refreshed.RefreshToken == s.RefreshToken {
+		t.Error("refresh token was not rotated")
+	}
+	if rec := api.do("GET", "/api/v2/tasks", refreshed.Token, nil); rec.Code != http.StatusOK {
+		t.Errorf("refreshed access token: got %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	// A rotated refresh token cannot be used again
+	if rec := api.do("POST", "/api/v2/auth/refresh", "", map[string]string{"refresh_token": s.RefreshToken}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("reused refresh token: got %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if rec := api.do("POST", "/api/v2/auth/refresh", "", map[string]string{"refresh_token": "garbage"}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unknown refresh token: got %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestTaskCRUD(t *testing.T) {
+	api := newTestAPI(t)
+	s := api.signUp("alice", "")
+
+	created := api.createTask(s.Token, nil)
+	if created.ID == 0 || created.CreatedBy != s.User.ID || created.Version != 1 {
+		t.Fatalf("unexpected created task: %+v", created)
+	}
+	path := fmt.Sprintf("/api/v2/tasks/%d", created.ID)
+
+	rec := api.do("GET", path, s.Token, nil)
+	var got taskResponse
+	api.expect(rec, http.StatusOK, &got)
+	if got != created {
+		t.Errorf("GET returned %+v, want %+v", got, created)
+	}
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("GET returned no ETag")
+	}
+	api.expect(api.do("GET", path, s.Token, nil, "If-None-Match", etag), http.StatusNotModified, nil)
+
+	var patched taskResponse
+	api.expect(api.do("PATCH", path, s.Token, []byte(`{"status":"in-progress"}`),
+		"Content-Type", "application/merge-patch+json", "If-Match", etag), http.StatusOK, &patched)
+	if patched.Status != "in-progress" || patched.Version != 2 {
+		t.Errorf("PATCH returned %+v", patched)
+	}
+
+	// The old ETag is now stale
+	stale := api.do("PATCH", path, s.Token, []byte(`{"title":"Other"}`),
+		"Content-Type", "application/merge-patch+json", "If-Match", etag)
+	api.expect(stale, http.StatusPreconditionFailed, nil)
+
+	replacement := map[string]any{"title": "Final report", "status": "in-progress", "priority": "high"}
+	var replaced taskResponse
+	api.expect(api.do("PUT", path, s.Token, replacement), http.StatusOK, &replaced)
+	if replaced.Title != "Final report" || replaced.Priority != "high" || replaced.Version != 3 {
+		t.Errorf("PUT returned %+v", replaced)
+	}
+
+	var list struct {
+		Items []taskResponse `json:"items"`
+	}
+	api.expect(api.do("GET", "/api/v2/tasks", s.Token, nil), http.StatusOK, &list)
+	if len(list.Items) != 1 || list.Items[0].ID != created.ID {
+		t.Errorf("list returned %+v", list.Items)
+	}
+
+	api.expect(api.do("DELETE", path, s.Token, nil), http.StatusNoContent, nil)
+	api.expect(api.do("GET", path, s.Token, nil), http.StatusNotFound, nil)
+	api.expect(api.do("DELETE", path, s.Token, nil), http.StatusNotFound, nil)
+}
+
+func TestTaskValidation(t *testing.T) {
+	api := newTestAPI(t)
+	s := api.signUp("alice", "")
+
+	invalid := map[string]any{"title": "", "status": "someday", "priority": "medium"}
+	var problem struct {
+		Errors map[string]string `json:"errors"`
+	}
+	api.expect(api.do("POST", "/api/v2/tasks", s.Token, invalid), http.StatusUnprocessableEntity, &problem)
+	for _, field := range []string{"title", "status"} {
+		if problem.Errors[field] == "" {
+			t.Errorf("no error reported for %q: %+v", field, problem.Errors)
+		}
+	}
+
+	api.expect(api.do("POST", "/api/v2/tasks", s.Token, []byte(`{"title":"x"} trailing`)), http.StatusBadRequest, nil)
+	api.expect(api.do("GET", "/api/v2/tasks/999", s.Token, nil), http.StatusNotFound, nil)
+}
+
+func TestTaskETagFollowsDerivedFields(t *testing.T) {
+	api := newTestAPI(t)
+	s := api.signUp("alice", "")
+	task := api.createTask(s.Token, nil)
+	path := fmt.Sprintf("/api/v2/tasks/%d", task.ID)
+
+	etag := api.do("GET", path, s.Token, nil).Header().Get("ETag")
+	api.expect(api.do("POST", path+"/comments", s.Token, map[string]string{"body": "First!"}), http.StatusCreated, nil)
+
+	// A new comment changes comment_count, so the cached copy is stale
+	rec := api.do("GET", path, s.Token, nil, "If-None-Match", etag)
+	api.expect(rec, http.StatusOK, nil)
+	if rec.Header().Get("ETag") == etag {
+		t.Error("ETag unchanged after adding a comment")
+	}
+
+	// The ETag returned by a write matches the one a read returns
+	etag = rec.Header().Get("ETag")
+	rec = api.do("PATCH", path, s.Token, []byte(`{"title":"Renamed"}`),
+		"Content-Type", "application/merge-patch+json", "If-Match", etag)
+	api.expect(rec, http.StatusOK, nil)
+	etag = rec.Header().Get("ETag")
+	api.expect(api.do("GET", path, s.Token, nil, "If-None-Match", etag), http.StatusNotModified, nil)
+}
+
+func TestListSubtasks(t *testing.T) {
+	api := newTestAPI(t)
+	s := api.signUp("alice", "")
+	parent := api.createTask(s.Token, nil)
+	first := api.createTask(s.Token, map[string]any{"title": "Outline", "parent_id": parent.ID})
+	second := api.createTask(s.Token, map[string]any{"title": "Draft", "parent_id": parent.ID})
+	api.createTask(s.Token, map[string]any{"title": "Polish", "parent_id": first.ID})
+
+	var list struct {
+		Items []taskResponse `json:"items"`
+	}
+	api.expect(api.do("GET", fmt.Sprintf("/api/v2/tasks/%d/subtasks", parent.ID), s.Token, nil), http.StatusOK, &list)
+	if len(list.Items) != 2 || list.Items[0].ID != first.ID || list.Items[1].ID != second.ID {
+		t.Errorf("subtasks = %+v, want tasks %d and %d", list.Items, first.ID, second.ID)
+	}
+
+	// A task without subtasks has an empty list, not null
+	rec := api.do("GET", fmt.Sprintf("/api/v2/tasks/%d/subtasks", second.ID), s.Token, nil)
+	api.expect(rec, http.StatusOK, nil)
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"items":[]}` {
+		t.Errorf("empty subtasks = %s", got)
+	}
+}
+
+func TestListDependencies(t *testing.T) {
+	api := newTestAPI(t)
+	s := api.signUp("alice", "")
+	task := api.createTask(s.Token, nil)
+	design := api.createTask(s.Token, map[string]any{"title": "Design"})
+	budget := api.createTask(s.Token, map[string]any{"title": "Budget"})
+	path := fmt.Sprintf("/api/v2/tasks/%d/dependencies", task.ID)
+
+	rec := api.do("GET", path, s.Token, nil)
+	api.expect(rec, http.StatusOK, nil)
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"items":[]}` {
+		t.Errorf("no dependencies = %s", got)
+	}
+
+	api.expect(api.do("PUT", fmt.Sprintf("%s/%d", path, budget.ID), s.Token, nil), http.StatusOK, nil)
+	api.expect(api.do("PUT", fmt.Sprintf("%s/%d", path, design.ID), s.Token, nil), http.StatusOK, nil)
+
+	var list struct {
+		Items []taskResponse `json:"items"`
+	}
+	api.expect(api.do("GET", path, s.Token, nil), http.StatusOK, &list)
+	if len(list.Items) != 2 || list.Items[0].ID != design.ID || list.Items[1].ID != budget.ID {
+		t.Errorf("dependencies = %+v, want tasks %d and %d", list.Items, design.ID, budget.ID)
+	}
+}
+
+func TestCriticalPath(t *testing.T) {
+	api := newTestAPI(t, withOrgSignup)
+	s := api.signUp("alice", "Acme")
+	project := api.createProject(s.Token, "LAUNCH")
+	path := fmt.Sprintf("/api/v2/projects/%d/critical-path", project)
+
+	rec := api.do("GET", path, s.Token, nil)
+	api.expect(rec, http.StatusOK, nil)
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"items":[]}` {
+		t.Errorf("critical path of an empty project = %s", got)
+	}
+
+	launch := api.createTask(s.Token, map[string]any{"title": "Launch", "project_id": project})
+	write := api.createTask(s.Token, map[string]any{"title": "Write", "project_id": project})
+	draft := api.createTask(s.Token, map[string]any{"title": "Draft", "project_id": project})
+	api.createTask(s.Token, map[string]any{"title": "Budget", "project_id": project})
+	api.expect(api.do("PUT", fmt.Sprintf("/api/v2/tasks/%d/dependencies/%d", launch.ID, write.ID), s.Token, nil), http.StatusOK, nil)
+	api.expect(api.do("PUT", fmt.Sprintf("/api/v2/tasks/%d/dependencies/%d", write.ID, draft.ID), s.Token, nil), http.StatusOK, nil)
+
+	var list struct {
+		Items []taskResponse `json:"items"`
+	}
+	api.expect(api.do("GET", path, s.Token, nil), http.StatusOK, &list)
+	var got []int
+	for _, task := range list.Items {
+		got = append(got, task.ID)
+	}
+	if want := []int{draft.ID, write.ID, launch.ID}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("critical path = %v, want %v", got, want)
+	}
+}
+
+func TestCompletionCheckedAgainstStoredTask(t *testing.T) {
+	api := newTestAPI(t)
+	s := api.signUp("alice", "")
+	task := api.createTask(s.Token, map[string]any{"status": "in-progress"})
+	blocker := api.createTask(s.Token, map[string]any{"title": "Budget"})
+	tasks := api.repos.Tasks.ForOrg(s.User.OrgID)
+
+	// The dependency is added after the caller read the task, so only the
+	// check against the stored task sees that it is blocked
+	read, err := tasks.GetTaskByID(task.ID)
+	if err != nil {
+		t.Fatalf("GetTaskByID: %v", err)
+	}
+	api.expect(api.do("PUT", fmt.Sprintf("/api/v2/tasks/%d/dependencies/%d", task.ID, blocker.ID), s.Token, nil), http.StatusOK, nil)
+
+	read.Status = models.StatusDone
+	err = tasks.UpdateTask(read, func(stored models.Task) error {
+		return workflow.Default().CheckCompletion(stored, read.Status)
+	})
+	if !errors.Is(err, workflow.ErrTaskBlocked) {
+		t.Fatalf("UpdateTask of a task blocked since it was read = %v, want %v", err, workflow.ErrTaskBlocked)
+	}
+
+	path := fmt.Sprintf("/api/v2/tasks/%d", task.ID)
+	done := []byte(`{"status":"done"}`)
+	api.expect(api.do("PATCH", path, s.Token, done, "Content-Type", "application/merge-patch+json"), http.StatusConflict, nil)
+	api.expect(api.do("PATCH", fmt.Sprintf("/api/v2/tasks/%d", blocker.ID), s.Token, []byte(`{"status":"in-progress"}`),
+		"Content-Type", "application/merge-patch+json"), http.StatusOK, nil)
+	api.expect(api.do("PATCH", fmt.Sprintf("/api/v2/tasks/%d", blocker.ID), s.Token, done,
+		"Content-Type", "application/merge-patch+json"), http.StatusOK, nil)
+	api.expect(api.do("PATCH", path, s.Token, done, "Content-Type", "application/merge-patch+json"), http.StatusOK, nil)
+}
+
+func TestOrganizationSignup(t *testing.T) {
+	api := newTestAPI(t, withOrgSignup)
+	founder := api.signUp("olga", "Acme")
+	if founder.User.Role != "admin" || founder.User.OrgID == 1 {
+		t.Errorf("founder = %+v, want admin of a new organization", founder.User)
+	}
+
+	closed := newTestAPI(t)
+	body := map[string]string{"username": "mallory", "email": "mallory@example.com", "password": "secret123", "organization": "Evil"}
+	closed.expect(closed.do("POST", "/api/v2/users", "", body), http.StatusForbidden, nil)
+
+	// Nothing was created: the same email can still sign up normally
+	user := closed.signUp("mallory", "")
+	if user.User.Role != "user" || user.User.OrgID != 1 {
+		t.Errorf("user = %+v, want a plain user of the default organization", user.User)
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/naveeshkumar24/internal/models"
+)
+
+// upload attaches a small text file to a task.
+func (a *testAPI) upload(token string, taskID int) int {
+	a.t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "notes.txt")
+	if err != nil {
+		a.t.Fatalf("CreateFormFile: %v", err)
+	}
+	fmt.Fprint(part, "quarterly numbers")
+	form.Close()
+
+	req := httptest.NewRequest("POST", fmt.Sprintf("/api/v2/tasks/%d/attachments", taskID), &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	a.handler.ServeHTTP(rec, req)
+
+	var attachment struct {
+		ID int `json:"id"`
+	}
+	a.expect(rec, http.StatusCreated, &attachment)
+	return attachment.ID
+}
+
+// created posts body to path and returns the ID of the created resource.
+func (a *testAPI) created(token, path string, body any) int {
+	a.t.Helper()
+	var resource struct {
+		ID int `json:"id"`
+	}
+	a.expect(a.do("POST", path, token, body), http.StatusCreated, &resource)
+	return resource.ID
+}
+
+// tenantA holds everything the founder of one organization creates.
+type tenantA struct {
+	owner      session
+	member     session
+	project    int
+	task       int
+	subtask    int
+	blocker    int
+	comment    int
+	attachment int
+	team       int
+	invitation int
+	token      string
+}
+
+func seedTenant(api *testAPI) tenantA {
+	api.t.Helper()
+	var a tenantA
+	a.owner = api.signUp("alice", "Acme")
+	a.project = api.createProject(a.owner.Token, "ACME")
+
+	task := api.createTask(a.owner.Token, map[string]any{"title": "Acme launch plan", "project_id": a.project})
+	a.task = task.ID
+	a.subtask = api.createTask(a.owner.Token, map[string]any{"title": "Acme launch copy", "project_id": a.project, "parent_id": a.task}).ID
+	a.blocker = api.createTask(a.owner.Token, map[string]any{"title": "Acme launch budget", "project_id": a.project}).ID
+	api.expect(api.do("PUT", fmt.Sprintf("/api/v2/tasks/%d/dependencies/%d", a.task, a.blocker), a.owner.Token, nil), http.StatusOK, nil)
+
+	a.comment = api.created(a.owner.Token, fmt.Sprintf("/api/v2/tasks/%d/comments", a.task), map[string]any{"body": "Acme launch moved"})
+	api.created(a.owner.Token, fmt.Sprintf("/api/v2/tasks/%d/comments", a.task), map[string]any{"body": "Noted", "parent_id": a.comment})
+	a.attachment = api.upload(a.owner.Token, a.task)
+
+	a.team = api.created(a.owner.Token, "/api/v2/teams", map[string]string{"name": "Launch"})
+	api.expect(api.do("PUT", fmt.Sprintf("/api/v2/teams/%d/members/%d", a.team, a.owner.User.ID), a.owner.Token, nil), http.StatusNoContent, nil)
+	api.expect(api.do("PUT", fmt.Sprintf("/api/v2/projects/%d/teams/%d", a.project, a.team), a.owner.Token, map[string]string{"role": "user"}), http.StatusOK, nil)
+
+	var invitation struct {
+		Invitation struct {
+			ID int `json:"id"`
+		} `json:"invitation"`
+		Token string `json:"token"`
+	}
+	api.expect(api.do("POST", fmt.Sprintf("/api/v2/projects/%d/invitations", a.project), a.owner.Token, map[string]string{"email": "bob@example.com", "role": "user"}), http.StatusCreated, &invitation)
+	a.invitation, a.token = invitation.Invitation.ID, invitation.Token
+	return a
+}
+
+func TestTenantIsolationByID(t *testing.T) {
+	api := newTestAPI(t, withOrgSignup)
+	a := seedTenant(api)
+	bob := api.signUp("bob", "Beta")
+	if bob.User.OrgID == a.owner.User.OrgID {
+		t.Fatalf("both founders are in organization %d", bob.User.OrgID)
+	}
+
+	task := fmt.Sprintf("/api/v2/tasks/%d", a.task)
+	project := fmt.Sprintf("/api/v2/projects/%d", a.project)
+	team := fmt.Sprintf("/api/v2/teams/%d", a.team)
+	user := fmt.Sprintf("/api/v2/users/%d", a.owner.User.ID)
+	taskBody := map[string]any{"title": "Taken over", "status": "todo", "priority": "low", "version": 1}
+
+	routes := []struct {
+		method, path string
+		body         any
+	}{
+		{"GET", task, nil},
+		{"GET", "/api/v2/tasks/ACME-1", nil},
+		{"PATCH", task, map[string]any{"title": "Taken over"}},
+		{"PUT", task, taskBody},
+		{"DELETE", task, nil},
+		{"GET", task + "/subtasks", nil},
+		{"GET", fmt.Sprintf("/api/v2/tasks/%d/subtree", a.task), nil},
+		{"GET", task + "/dependencies", nil},
+		{"PUT", fmt.Sprintf("%s/dependencies/%d", task, a.subtask), nil},
+		{"DELETE", fmt.Sprintf("%s/dependencies/%d", task, a.blocker), nil},
+		{"GET", task + "/comments", nil},
+		{"POST", task + "/comments", map[string]any{"body": "Hello from Beta"}},
+		{"GET", fmt.Sprintf("/api/v2/comments/%d", a.comment), nil},
+		{"PATCH", fmt.Sprintf("/api/v2/comments/%d", a.comment), map[string]any{"body": "Edited by Beta"}},
+		{"DELETE", fmt.Sprintf("/api/v2/comments/%d", a.comment), nil},
+		{"GET", fmt.Sprintf("/api/v2/comments/%d/replies", a.comment), nil},
+		{"GET", task + "/attachments", nil},
+		{"GET", fmt.Sprintf("%s/attachments/%d", task, a.attachment), nil},
+		{"HEAD", fmt.Sprintf("%s/attachments/%d", task, a.attachment), nil},
+		{"DELETE", fmt.Sprintf("%s/attachments/%d", task, a.attachment), nil},
+		{"GET", project, nil},
+		{"PUT", project, map[string]string{"key": "ACME", "name": "Taken over"}},
+		{"DELETE", project, nil},
+		{"PUT", project + "/archive", nil},
+		{"DELETE", project + "/archive", nil},
+		{"GET", project + "/tasks", nil},
+		{"POST", project + "/tasks", taskBody},
+		{"GET", project + "/dashboard", nil},
+		{"GET", project + "/critical-path", nil},
+		{"GET", project + "/members", nil},
+		{"PUT", fmt.Sprintf("%s/members/%d", project, bob.User.ID), map[string]string{"role": "admin"}},
+		{"DELETE", fmt.Sprintf("%s/members/%d", project, a.owner.User.ID), nil},
+		{"GET", project + "/teams", nil},
+		{"PUT", fmt.Sprintf("%s/teams/%d", project, a.team), map[string]string{"role": "admin"}},
+		{"DELETE", fmt.Sprintf("%s/teams/%d", project, a.team), nil},
+		{"GET", project + "/invitations", nil},
+		{"POST", project + "/invitations", map[string]string{"email": "bob@example.com", "role": "admin"}},
+		{"DELETE", fmt.Sprintf("%s/invitations/%d", project, a.invitation), nil},
+		{"POST", "/api/v2/invitations/accept", map[string]string{"token": a.token}},
+		{"GET", team, nil},
+		{"DELETE", team, nil},
+		{"GET", team + "/members", nil},
+		{"PUT", fmt.Sprintf("%s/members/%d", team, bob.User.ID), nil},
+		{"DELETE", fmt.Sprintf("%s/members/%d", team, a.owner.User.ID), nil},
+		{"GET", user, nil},
+		{"GET", user + "/dashboard", nil},
+		{"PUT", user + "/role", map[string]string{"role": "user"}},
+	}
+	for _, route := range routes {
+		rec := api.do(route.method, route.path, bob.Token, route.body)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s from another organization = %d, want 404; body: %s", route.method, route.path, rec.Code, rec.Body.String())
+		}
+	}
+
+	// Nothing of the first organization changed on the way
+	var stored taskResponse
+	api.expect(api.do("GET", task, a.owner.Token, nil), http.StatusOK, &stored)
+	if stored.Title != "Acme launch plan" || stored.Version != 1 {
+		t.Errorf("task after cross-tenant writes = %+v", stored)
+	}
+	var members struct {
+		Items []struct {
+			UserID int `json:"user_id"`
+		} `json:"items"`
+	}
+	api.expect(api.do("GET", project+"/members", a.owner.Token, nil), http.StatusOK, &members)
+	for _, member := range members.Items {
+		if member.UserID == bob.User.ID {
+			t.Errorf("user of another organization became a project member")
+		}
+	}
+	api.expect(api.do("GET", fmt.Sprintf("%s/attachments/%d", task, a.attachment), a.owner.Token, nil), http.StatusOK, nil)
+}
+
+func TestTenantIsolationLists(t *testing.T) {
+	api := newTestAPI(t, withOrgSignup)
+	a := seedTenant(api)
+	bob := api.signUp("bob", "Beta")
+
+	lists := []string{
+		"/api/v2/tasks",
+		"/api/v2/tasks?project_id=" + fmt.Sprint(a.project),
+		"/api/v2/tasks/search?q=Acme",
+		"/api/v2/tasks/search?mode=fulltext&q=acme%20launch",
+		"/api/v2/projects",
+		"/api/v2/teams",
+	}
+	for _, path := range lists {
+		// The first organization sees its own data, so an empty list for the
+		// second one is not an accident of the query
+		for _, caller := range []session{a.owner, bob} {
+			rec := api.do("GET", path, caller.Token, nil)
+			var page struct {
+				Items []json.RawMessage `json:"items"`
+			}
+			api.expect(rec, http.StatusOK, &page)
+			if own := caller.User.ID == a.owner.User.ID; own != (len(page.Items) > 0) {
+				t.Errorf("GET %s as %d returned %d items", path, caller.User.ID, len(page.Items))
+			}
+		}
+	}
+
+	// Bob's own dashboard and organization only hold his organization
+	if got := api.dashboardTasks(bob.Token, bob.User.ID); len(got) != 0 {
+		t.Errorf("dashboard of another organization's founder = %v", got)
+	}
+	var org struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	api.expect(api.do("GET", "/api/v2/organization", bob.Token, nil), http.StatusOK, &org)
+	if org.ID != bob.User.OrgID || org.Name != "Beta" {
+		t.Errorf("organization = %+v, want Beta (%d)", org, bob.User.OrgID)
+	}
+}
+
+func TestJoinOrganizationByInvitation(t *testing.T) {
+	api := newTestAPI(t, withOrgSignup)
+	a := seedTenant(api)
+
+	var invitation struct {
+		Token string `json:"token"`
+	}
+	api.expect(api.do("POST", fmt.Sprintf("/api/v2/projects/%d/invitations", a.project), a.owner.Token, map[string]string{"email": "carol@example.com", "role": "manager"}), http.StatusCreated, &invitation)
+
+	// Only the invited address can use the token
+	stranger := map[string]string{"username": "mallory", "email": "mallory@example.com", "password": "secret123", "invitation": invitation.Token}
+	api.expect(api.do("POST", "/api/v2/users", "", stranger), http.StatusForbidden, nil)
+	unknown := map[string]string{"username": "carol", "email": "carol@example.com", "password": "secret123", "invitation": "not-a-token"}
+	api.expect(api.do("POST", "/api/v2/users", "", unknown), http.StatusNotFound, nil)
+
+	body := map[string]string{"username": "carol", "email": "carol@example.com", "password": "secret123", "invitation": invitation.Token}
+	var user struct {
+		ID    int    `json:"id"`
+		Role  string `json:"role"`
+		OrgID int    `json:"org_id"`
+	}
+	api.expect(api.do("POST", "/api/v2/users", "", body), http.StatusCreated, &user)
+	if user.OrgID != a.owner.User.OrgID || user.Role != "user" {
+		t.Fatalf("invited user = %+v, want a plain user of organization %d", user, a.owner.User.OrgID)
+	}
+
+	var carol session
+	api.expect(api.do("POST", "/api/v2/auth/login", "", map[string]string{"email": "carol@example.com", "password": "secret123"}), http.StatusOK, &carol)
+	var project struct {
+		Key string `json:"key"`
+	}
+	api.expect(api.do("GET", fmt.Sprintf("/api/v2/projects/%d", a.project), carol.Token, nil), http.StatusOK, &project)
+	if project.Key != "ACME" {
+		t.Errorf("project = %+v, want ACME", project)
+	}
+	var members struct {
+		Items []struct {
+			UserID int    `json:"user_id"`
+			Role   string `json:"role"`
+		} `json:"items"`
+	}
+	api.expect(api.do("GET", fmt.Sprintf("/api/v2/projects/%d/members", a.project), a.owner.Token, nil), http.StatusOK, &members)
+	role := ""
+	for _, member := range members.Items {
+		if member.UserID == user.ID {
+			role = member.Role
+		}
+	}
+	if role != "manager" {
+		t.Errorf("project role of the invited user = %q, want manager", role)
+	}
+
+	// The invitation is used up
+	again := map[string]string{"username": "carol2", "email": "carol@example.com", "password": "secret123", "invitation": invitation.Token}
+	api.expect(api.do("POST", "/api/v2/users", "", again), http.StatusGone, nil)
+}
+
+func TestSignupNeedsOrganizationOrInvitation(t *testing.T) {
+	api := newTestAPI(t, withOrgSignup)
+
+	// A user of the default organization with a task outside any project,
+	// which every member of that organization can see
+	legacy, err := api.repos.Users.ForOrg(models.DefaultOrgID).Register(models.User{Username: "lee", Email: "lee@example.com", Password: "secret123", Role: models.RoleUser, Timezone: "UTC"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	_, err = api.repos.Tasks.ForOrg(models.DefaultOrgID).CreateTask(models.Task{Title: "Legacy payroll run", Status: "todo", Priority: "high", CreatedBy: legacy.ID})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	var lee session
+	api.expect(api.do("POST", "/api/v2/auth/login", "", map[string]string{"email": "lee@example.com", "password": "secret123"}), http.StatusOK, &lee)
+	if got := api.listTitles(lee.Token); len(got) != 1 {
+		t.Fatalf("tasks of the default organization = %v", got)
+	}
+
+	// A sign-up naming neither an organization nor an invitation is turned
+	// away rather than joining the default organization
+	plain := map[string]string{"username": "eve", "email": "eve@example.com", "password": "secret123"}
+	api.expect(api.do("POST", "/api/v2/users", "", plain), http.StatusUnprocessableEntity, nil)
+	api.expect(api.do("POST", "/api/v2/auth/login", "", map[string]string{"email": "eve@example.com", "password": "secret123"}), http.StatusUnauthorized, nil)
+
+	eve := api.signUp("eve", "Gamma")
+	if got := api.listTitles(eve.Token); len(got) != 0 {
+		t.Errorf("fresh sign-up lists tasks of the default organization: %v", got)
+	}
+}
+
+// listTitles returns the titles of the first page of the caller's tasks.
+func (a *testAPI) listTitles(token string) []string {
+	a.t.Helper()
+	var page struct {
+		Items []struct {
+			Title string `json:"title"`
+		} `json:"items"`
+	}
+	a.expect(a.do("GET", "/api/v2/tasks", token, nil), http.StatusOK, &page)
+	titles := []string{}
+	for _, task := range page.Items {
+		titles = append(titles, task.Title)
+	}
+	return titles
+}
@@ -0,0 +1,242 @@
+// Package apierror renders failures as RFC 7807 problem details
+// (application/problem+json) with stable, machine-readable codes.
+package apierror
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/naveeshkumar24/internal/models"
+	"github.com/naveeshkumar24/internal/validation"
+	"github.com/naveeshkumar24/internal/workflow"
+	"github.com/naveeshkumar24/pkg/utils"
+)
+
+// ContentType is the media type of every error response.
+const ContentType = "application/problem+json"
+
+// TypePrefix prefixes the code to form the problem type URI.
+const TypePrefix = "urn:taskmanager:problem:"
+
+// Stable error codes. Clients may switch on these; do not rename them.
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeConflict             = "conflict"
+	CodePreconditionFailed   = "precondition_failed"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeMalformedBody        = "malformed_body"
+	CodeBodyTooLarge         = "body_too_large"
+	CodeUnsupportedFileType  = "unsupported_file_type"
+	CodeValidationFailed     = "validation_failed"
+	CodeDuplicate            = "duplicate"
+	CodeReferenceNotFound    = "reference_not_found"
+	CodeConstraintViolation  = "constraint_violation"
+	CodeInvalidCursor        = "invalid_cursor"
+	CodeRangeNotSatisfiable  = "range_not_satisfiable"
+	CodeTransitionNotAllowed = "transition_not_allowed"
+	CodeTransitionForbidden  = "transition_forbidden"
+	CodeTokenReused          = "token_reused"
+	CodeProjectArchived      = "project_archived"
+	CodeProjectNotEmpty      = "project_not_empty"
+	CodeInvitationInvalid    = "invitation_invalid"
+	CodeLastProjectAdmin     = "last_project_admin"
+	CodeTaskHasSubtasks      = "task_has_subtasks"
+	CodeSubtasksOpen         = "subtasks_open"
+	CodeTaskBlocked          = "task_blocked"
+	CodeInternal             = "internal_error"
+)
+
+// Problem is an RFC 7807 problem details object. Code and Errors are
+// extension members.
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Code     string            `json:"code"`
+	Errors   map[string]string `json:"errors,omitempty"`
+
+	cause error
+}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Code + ": " + p.Detail
+	}
+	return p.Code
+}
+
+func (p *Problem) Unwrap() error { return p.cause }
+
+// New builds a problem with the given status, code and human-readable detail.
+func New(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   TypePrefix + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+func BadRequest(detail string) *Problem {
+	return New(http.StatusBadRequest, CodeInvalidRequest, detail)
+}
+
+func Unauthorized(detail string) *Problem {
+	return New(http.StatusUnauthorized, CodeUnauthorized, detail)
+}
+
+func Forbidden(detail string) *Problem {
+	return New(http.StatusForbidden, CodeForbidden, detail)
+}
+
+func NotFound(detail string) *Problem {
+	return New(http.StatusNotFound, CodeNotFound, detail)
+}
+
+func PreconditionFailed(detail string) *Problem {
+	return New(http.StatusPreconditionFailed, CodePreconditionFailed, detail)
+}
+
+func UnsupportedMediaType(detail string) *Problem {
+	return New(http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, detail)
+}
+
+// Internal hides err from the client behind detail; err is logged by Write.
+func Internal(detail string, err error) *Problem {
+	p := New(http.StatusInternalServerError, CodeInternal, detail)
+	p.cause = err
+	return p
+}
+
+// Validation reports every invalid field at once with 422.
+func Validation(errs map[string]string) *Problem {
+	p := New(http.StatusUnprocessableEntity, CodeValidationFailed, "Validation failed")
+	p.Errors = errs
+	return p
+}
+
+// From maps err to a problem. Errors the API knows about get their own status
+// and code; anything else becomes a 500 carrying fallback as its detail.
+func From(err error, fallback string) *Problem {
+	var problem *Problem
+	if errors.As(err, &problem) {
+		return problem
+	}
+
+	var decodeErr *utils.DecodeError
+	if errors.As(err, &decodeErr) {
+		return fromDecode(decodeErr)
+	}
+
+	var fieldErrs validation.Errors
+	if errors.As(err, &fieldErrs) {
+		return Validation(fieldErrs)
+	}
+
+	var constraint *models.ConstraintError
+	if errors.As(err, &constraint) {
+		return fromConstraint(constraint)
+	}
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return NotFound("Resource not found")
+	case errors.Is(err, models.ErrVersionConflict):
+		return PreconditionFailed("Resource was modified by someone else; reload it and retry")
+	case errors.Is(err, models.ErrInvalidCursor):
+		return New(http.StatusBadRequest, CodeInvalidCursor, "Invalid cursor")
+	case errors.Is(err, models.ErrTokenReused):
+		return New(http.StatusUnauthorized, CodeTokenReused, "Invalid refresh token")
+	case errors.Is(err, models.ErrProjectArchived):
+		return New(http.StatusConflict, CodeProjectArchived, "Project is archived; restore it first")
+	case errors.Is(err, models.ErrProjectNotEmpty):
+		return New(http.StatusConflict, CodeProjectNotEmpty, "Project still has tasks")
+	case errors.Is(err, models.ErrInvitationInvalid):
+		return New(http.StatusGone, CodeInvitationInvalid, "Invitation was already accepted, revoked or has expired")
+	case errors.Is(err, models.ErrLastProjectAdmin):
+		return New(http.StatusConflict, CodeLastProjectAdmin, "Project must keep at least one admin")
+	case errors.Is(err, models.ErrTaskHasSubtasks):
+		return New(http.StatusConflict, CodeTaskHasSubtasks, "Task still has subtasks; delete or move them first")
+	case errors.Is(err, workflow.ErrSubtasksOpen):
+		p := New(http.StatusConflict, CodeSubtasksOpen, err.Error())
+		p.Errors = map[string]string{"status": err.Error()}
+		return p
+	case errors.Is(err, workflow.ErrTaskBlocked):
+		p := New(http.StatusConflict, CodeTaskBlocked, err.Error())
+		p.Errors = map[string]string{"status": err.Error()}
+		return p
+	case errors.Is(err, workflow.ErrTransitionForbidden):
+		return New(http.StatusForbidden, CodeTransitionForbidden, err.Error())
+	case errors.Is(err, workflow.ErrTransitionNotAllowed):
+		p := New(http.StatusUnprocessableEntity, CodeTransitionNotAllowed, err.Error())
+		p.Errors = map[string]string{"status": err.Error()}
+		return p
+	}
+
+	if fallback == "" {
+		fallback = "Internal server error"
+	}
+	return Internal(fallback, err)
+}
+
+func fromDecode(err *utils.DecodeError) *Problem {
+	code := CodeMalformedBody
+	switch err.Status {
+	case http.StatusRequestEntityTooLarge:
+		code = CodeBodyTooLarge
+	case http.StatusUnsupportedMediaType:
+		code = CodeUnsupportedMediaType
+	}
+	p := New(err.Status, code, err.Msg)
+	if err.Field != "" {
+		p.Errors = map[string]string{err.Field: err.Msg}
+	}
+	return p
+}
+
+func fromConstraint(err *models.ConstraintError) *Problem {
+	var p *Problem
+	switch {
+	case errors.Is(err, models.ErrDuplicate):
+		p = New(http.StatusConflict, CodeDuplicate, err.Error())
+	case errors.Is(err, models.ErrReferenceNotFound):
+		p = New(http.StatusUnprocessableEntity, CodeReferenceNotFound, err.Error())
+	default:
+		p = New(http.StatusUnprocessableEntity, CodeConstraintViolation, err.Error())
+	}
+	if err.Field != "" {
+		p.Errors = map[string]string{err.Field: err.Err.Error()}
+	}
+	p.cause = err
+	return p
+}
+
+// Write responds with err as a problem document. Server errors are logged
+// with their cause, which is never sent to the client.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	p := From(err, "")
+	if p.Status >= http.StatusInternalServerError {
+		log.Printf("%s %s failed: %v", r.Method, r.URL.Path, p.cause)
+	}
+
+	body := *p
+	if body.Instance == "" {
+		body.Instance = r.URL.Path
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Failed to encode problem response: %v", err)
+	}
+}
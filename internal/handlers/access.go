@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/naveeshkumar24/internal/apierror"
+	"github.com/naveeshkumar24/internal/middleware"
+	"github.com/naveeshkumar24/internal/models"
+	"github.com/naveeshkumar24/internal/rbac"
+	"github.com/naveeshkumar24/pkg/utils"
+)
+
+// ProjectAccess resolves the role a caller acts with. Outside projects that
+// is the role from the access token; inside a project it is the role the
+// caller holds there, directly or through a team, so a global manager may be
+// a plain user on one project and have no access at all to another. Global
+// admins act as admins everywhere. Everything is looked up within the
+// caller's organization.
+type ProjectAccess struct {
+	taskRepo    models.TaskInterface
+	memberships models.MembershipInterface
+}
+
+func NewProjectAccess(taskRepo models.TaskInterface, memberships models.MembershipInterface) *ProjectAccess {
+	return &ProjectAccess{
+		taskRepo:    taskRepo,
+		memberships: memberships,
+	}
+}
+
+// Role returns the caller's effective role on projectID, or "" when they
+// are not a member. Project 0 means no project.
+func (a *ProjectAccess) Role(claims *utils.Claims, projectID int) (string, error) {
+	if projectID == 0 {
+		return claims.Role, nil
+	}
+	projectRole, err := a.memberships.ForOrg(claims.OrgID).GetProjectRole(projectID, claims.UserID)
+	if err != nil {
+		return "", err
+	}
+	return rbac.EffectiveRole(claims.Role, projectRole), nil
+}
+
+// loadTask fetches a task and the caller's effective role on it, writing the
+// error response itself when that fails.
+func (a *ProjectAccess) loadTask(w http.ResponseWriter, r *http.Request, id int) (models.Task, string, bool) {
+	task, err := a.taskRepo.ForOrg(middleware.OrgID(r)).GetTaskByID(id)
+	if err != nil {
+		log.Printf("Task not found: %v", err)
+		apierror.Write(w, r, apierror.NotFound("Task not found"))
+		return models.Task{}, "", false
+	}
+	role, ok := a.taskRole(w, r, task)
+	return task, role, ok
+}
+
+// taskRole resolves the caller's effective role on task. Tasks in projects
+// the caller does not belong to are reported as not found rather than
+// forbidden, so that their existence is not revealed.
+func (a *ProjectAccess) taskRole(w http.ResponseWriter, r *http.Request, task models.Task) (string, bool) {
+	claims, ok := middleware.GetClaims(r)
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("Unauthorized"))
+		return "", false
+	}
+	role, err := a.Role(claims, task.ProjectID)
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to check project membership"))
+		return "", false
+	}
+	if role == "" {
+		apierror.Write(w, r, apierror.NotFound("Task not found"))
+		return "", false
+	}
+	return role, true
+}
+
+// projectRole resolves the caller's effective role on the {projectID} of a
+// project-scoped route, responding 404 to non-members.
+func (a *ProjectAccess) projectRole(w http.ResponseWriter, r *http.Request, projectID int) (string, bool) {
+	claims, ok := middleware.GetClaims(r)
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("Unauthorized"))
+		return "", false
+	}
+	role, err := a.Role(claims, projectID)
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to check project membership"))
+		return "", false
+	}
+	if role == "" {
+		apierror.Write(w, r, apierror.NotFound("Project not found"))
+		return "", false
+	}
+	return role, true
+}
+
+// canAnywhere reports whether the caller's global role, or the role they
+// hold on any project they belong to, grants action.
+func (a *ProjectAccess) canAnywhere(claims *utils.Claims, action rbac.Action) (bool, error) {
+	if rbac.Can(claims.Role, action) {
+		return true, nil
+	}
+	ids, err := a.memberships.ForOrg(claims.OrgID).ListMemberProjects(claims.UserID)
+	if err != nil {
+		return false, err
+	}
+	for _, id := range ids {
+		role, err := a.Role(claims, id)
+		if err != nil {
+			return false, err
+		}
+		if rbac.Can(role, action) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// roles returns a lookup of the caller's effective role per project that
+// asks storage once per project, for handlers checking many tasks.
+func (a *ProjectAccess) roles(claims *utils.Claims) func(projectID int) (string, error) {
+	cache := make(map[int]string)
+	return func(projectID int) (string, error) {
+		if role, ok := cache[projectID]; ok {
+			return role, nil
+		}
+		role, err := a.Role(claims, projectID)
+		if err != nil {
+			return "", err
+		}
+		cache[projectID] = role
+		return role, nil
+	}
+}
+
+// restrict narrows filter to tasks outside projects and in projects the
+// caller belongs to. Global admins see everything.
+func (a *ProjectAccess) restrict(claims *utils.Claims, filter *models.TaskFilter) error {
+	if claims.Role == models.RoleAdmin {
+		return nil
+	}
+	ids, err := a.memberships.ForOrg(claims.OrgID).ListMemberProjects(claims.UserID)
+	if err != nil {
+		return err
+	}
+	filter.RestrictProjects = true
+	filter.VisibleProjects = ids
+	return nil
+}
@@ -0,0 +1,450 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/naveeshkumar24/internal/apierror"
+	"github.com/naveeshkumar24/internal/middleware"
+	"github.com/naveeshkumar24/internal/models"
+	"github.com/naveeshkumar24/internal/rbac"
+	"github.com/naveeshkumar24/internal/validation"
+	"github.com/naveeshkumar24/pkg/blob"
+	"github.com/naveeshkumar24/pkg/utils"
+)
+
+// AttachmentPathV2 is the URL of an attachment in the v2 API, used for
+// Location headers.
+const AttachmentPathV2 = "/api/v2/tasks/%d/attachments/%d"
+
+// multipartOverhead is allowed on top of the file size for part headers,
+// boundaries and other form fields.
+const multipartOverhead = 64 << 10
+
+// maxFilenameBytes matches the filename column.
+const maxFilenameBytes = 255
+
+type AttachmentHandler struct {
+	attachmentRepo models.AttachmentInterface
+	access         *ProjectAccess
+	blobs          blob.Store
+	maxBytes       int64
+	allowedTypes   map[string]bool
+}
+
+// NewAttachmentHandler limits uploads to maxBytes and to the given media
+// types, which are compared against the sniffed type of the content.
+func NewAttachmentHandler(attachmentRepo models.AttachmentInterface, access *ProjectAccess, blobs blob.Store, maxBytes int64, allowedTypes []string) *AttachmentHandler {
+	allowed := make(map[string]bool, len(allowedTypes))
+	for _, mediaType := range allowedTypes {
+		allowed[mediaType] = true
+	}
+	return &AttachmentHandler{
+		attachmentRepo: attachmentRepo,
+		access:         access,
+		blobs:          blobs,
+		maxBytes:       maxBytes,
+		allowedTypes:   allowed,
+	}
+}
+
+// attachments returns the attachment metadata storage of the caller's organization.
+func (h *AttachmentHandler) attachments(r *http.Request) models.AttachmentInterface {
+	return h.attachmentRepo.ForOrg(middleware.OrgID(r))
+}
+
+// UploadAttachment handles POST /api/v2/tasks/{id}/attachments. The file is
+// read from the "file" part of a multipart/form-data body and spooled to a
+// temporary file, so that its size and SHA-256 are known before it is handed
+// to blob storage.
+func (h *AttachmentHandler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetClaims(r)
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("Unauthorized"))
+		return
+	}
+
+	taskID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid task ID"))
+		return
+	}
+	if _, _, ok := h.access.loadTask(w, r, taskID); !ok {
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		apierror.Write(w, r, apierror.UnsupportedMediaType("Content-Type must be multipart/form-data"))
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxBytes+multipartOverhead)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		apierror.Write(w, r, apierror.New(http.StatusBadRequest, apierror.CodeMalformedBody, "Malformed multipart body"))
+		return
+	}
+
+	var part io.Reader
+	var filename string
+	for {
+		p, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			apierror.Write(w, r, h.readError(err))
+			return
+		}
+		if p.FormName() == "file" {
+			part, filename = p, cleanFilename(p.FileName())
+			break
+		}
+	}
+	if part == nil {
+		apierror.Write(w, r, validation.Errors{"file": "is required"})
+		return
+	}
+	if filename == "" {
+		apierror.Write(w, r, validation.Errors{"file": "must have a filename"})
+		return
+	}
+
+	spool, err := os.CreateTemp("", "attachment-*")
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to store attachment", err))
+		return
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(spool, hash), io.LimitReader(part, h.maxBytes+1))
+	if err != nil {
+		apierror.Write(w, r, h.readError(err))
+		return
+	}
+	if size > h.maxBytes {
+		apierror.Write(w, r, h.tooLarge())
+		return
+	}
+
+	head := make([]byte, 512)
+	n, err := spool.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		apierror.Write(w, r, apierror.Internal("Failed to store attachment", err))
+		return
+	}
+	contentType := http.DetectContentType(head[:n])
+	sniffed, _, _ := mime.ParseMediaType(contentType)
+	if !h.allowedTypes[sniffed] {
+		apierror.Write(w, r, apierror.New(http.StatusUnsupportedMediaType, apierror.CodeUnsupportedFileType,
+			fmt.Sprintf("Files of type %s are not allowed", sniffed)))
+		return
+	}
+
+	key, err := attachmentKey(taskID)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to store attachment", err))
+		return
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to store attachment", err))
+		return
+	}
+
+	attachment := models.Attachment{
+		TaskID:      taskID,
+		Filename:    filename,
+		Size:        size,
+		ContentType: contentType,
+		SHA256:      hex.EncodeToString(hash.Sum(nil)),
+		StorageKey:  key,
+		UploadedBy:  claims.UserID,
+	}
+	err = h.blobs.Put(r.Context(), key, spool, blob.Info{Size: size, ContentType: contentType, SHA256: attachment.SHA256})
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to store attachment", err))
+		return
+	}
+
+	created, err := h.attachments(r).CreateAttachment(attachment)
+	if err != nil {
+		log.Printf("Failed to save attachment metadata: %v", err)
+		if err := h.blobs.Delete(context.Background(), key); err != nil {
+			log.Printf("Failed to remove orphaned blob %s: %v", key, err)
+		}
+		apierror.Write(w, r, apierror.From(err, "Failed to store attachment"))
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf(AttachmentPathV2, taskID, created.ID))
+	w.WriteHeader(http.StatusCreated)
+	utils.Encode(w, created)
+}
+
+// ListAttachments handles GET /api/v2/tasks/{id}/attachments.
+func (h *AttachmentHandler) ListAttachments(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid task ID"))
+		return
+	}
+	if _, _, ok := h.access.loadTask(w, r, taskID); !ok {
+		return
+	}
+
+	attachments, err := h.attachments(r).ListAttachments(taskID)
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to list attachments"))
+		return
+	}
+
+	utils.Encode(w, map[string]interface{}{"items": attachments})
+}
+
+// DownloadAttachment handles GET and HEAD on
+// /api/v2/tasks/{id}/attachments/{attachmentID}. The content is streamed
+// from blob storage; a single byte range may be requested with Range and
+// made conditional with If-Range.
+func (h *AttachmentHandler) DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	attachment, _, _, ok := h.loadAttachment(w, r)
+	if !ok {
+		return
+	}
+
+	etag := `"` + attachment.SHA256 + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Accept-Ranges", "bytes")
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	status := http.StatusOK
+	start, length := int64(0), attachment.Size
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+		if ifRange := r.Header.Get("If-Range"); ifRange == "" || ifRange == etag {
+			rangeStart, rangeLength, err := parseRange(rangeHeader, attachment.Size)
+			switch {
+			case errors.Is(err, errRangeNotSatisfiable):
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", attachment.Size))
+				apierror.Write(w, r, apierror.New(http.StatusRequestedRangeNotSatisfiable, apierror.CodeRangeNotSatisfiable,
+					fmt.Sprintf("Range %q is outside the %d byte file", rangeHeader, attachment.Size)))
+				return
+			case err == nil:
+				status = http.StatusPartialContent
+				start, length = rangeStart, rangeLength
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, start+length-1, attachment.Size))
+			}
+		}
+	}
+
+	var content io.ReadCloser
+	if r.Method != http.MethodHead {
+		var err error
+		content, err = h.blobs.Get(r.Context(), attachment.StorageKey, start, length)
+		if errors.Is(err, blob.ErrNotFound) {
+			log.Printf("Blob %s of attachment %d is missing", attachment.StorageKey, attachment.ID)
+			apierror.Write(w, r, apierror.NotFound("Attachment content not found"))
+			return
+		}
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal("Failed to read attachment", err))
+			return
+		}
+		defer content.Close()
+	}
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})
+	if disposition == "" {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Disposition", disposition)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if content == nil {
+		return
+	}
+	if _, err := io.Copy(w, content); err != nil {
+		log.Printf("Failed to stream attachment %d: %v", attachment.ID, err)
+	}
+}
+
+// DeleteAttachment handles DELETE /api/v2/tasks/{id}/attachments/{attachmentID}.
+// The uploader, the task's creator and roles allowed to delete any attachment
+// may delete it.
+func (h *AttachmentHandler) DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetClaims(r)
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("Unauthorized"))
+		return
+	}
+
+	attachment, task, role, ok := h.loadAttachment(w, r)
+	if !ok {
+		return
+	}
+	if attachment.UploadedBy != claims.UserID && task.CreatedBy != claims.UserID &&
+		!rbac.Can(role, rbac.ActionAttachmentDeleteAny) {
+		apierror.Write(w, r, apierror.Forbidden("You are not allowed to delete this attachment"))
+		return
+	}
+
+	if err := h.attachments(r).DeleteAttachment(attachment.ID); err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to delete attachment"))
+		return
+	}
+	// The metadata is gone, so a leftover blob is unreachable; log and move on
+	if err := h.blobs.Delete(r.Context(), attachment.StorageKey); err != nil {
+		log.Printf("Failed to delete blob %s: %v", attachment.StorageKey, err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadAttachment fetches the attachment named by the route, which must
+// belong to the task in the same route, along with that task and the
+// caller's effective role on it. It writes the error response itself when
+// that fails.
+func (h *AttachmentHandler) loadAttachment(w http.ResponseWriter, r *http.Request) (models.Attachment, models.Task, string, bool) {
+	vars := mux.Vars(r)
+	taskID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid task ID"))
+		return models.Attachment{}, models.Task{}, "", false
+	}
+	id, err := strconv.Atoi(vars["attachmentID"])
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid attachment ID"))
+		return models.Attachment{}, models.Task{}, "", false
+	}
+
+	task, role, ok := h.access.loadTask(w, r, taskID)
+	if !ok {
+		return models.Attachment{}, models.Task{}, "", false
+	}
+
+	attachment, err := h.attachments(r).GetAttachment(id)
+	if err != nil || attachment.TaskID != taskID {
+		apierror.Write(w, r, apierror.NotFound("Attachment not found"))
+		return models.Attachment{}, models.Task{}, "", false
+	}
+	return attachment, task, role, true
+}
+
+// readError maps a failure while reading the upload to a problem.
+func (h *AttachmentHandler) readError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return h.tooLarge()
+	}
+	return apierror.New(http.StatusBadRequest, apierror.CodeMalformedBody, "Malformed multipart body")
+}
+
+func (h *AttachmentHandler) tooLarge() error {
+	return apierror.New(http.StatusRequestEntityTooLarge, apierror.CodeBodyTooLarge,
+		fmt.Sprintf("Attachments may not exceed %d bytes", h.maxBytes))
+}
+
+// attachmentKey returns a fresh, unguessable blob key below the task.
+func attachmentKey(taskID int) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("tasks/%d/%s", taskID, hex.EncodeToString(random)), nil
+}
+
+// cleanFilename keeps the last path element of a client-supplied filename,
+// drops control characters and truncates it to maxFilenameBytes.
+func cleanFilename(name string) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "." || name == ".." {
+		return ""
+	}
+	for len(name) > maxFilenameBytes {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+var (
+	errRangeIgnored        = errors.New("range ignored")
+	errRangeNotSatisfiable = errors.New("range not satisfiable")
+)
+
+// parseRange resolves a single "bytes=" range against size. Headers this
+// server does not honour, such as multiple ranges or other units, return
+// errRangeIgnored and the whole file is served instead.
+func parseRange(header string, size int64) (start, length int64, err error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, errRangeIgnored
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, errRangeIgnored
+	}
+
+	if first == "" {
+		// Suffix range: the last n bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, errRangeIgnored
+		}
+		if n == 0 || size == 0 {
+			return 0, 0, errRangeNotSatisfiable
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, n, nil
+	}
+
+	start, err = strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, errRangeIgnored
+	}
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, errRangeIgnored
+		}
+		if end > size-1 {
+			end = size - 1
+		}
+	}
+	if start >= size {
+		return 0, 0, errRangeNotSatisfiable
+	}
+	return start, end - start + 1, nil
+}
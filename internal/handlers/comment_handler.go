@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/naveeshkumar24/internal/apierror"
+	"github.com/naveeshkumar24/internal/middleware"
+	"github.com/naveeshkumar24/internal/models"
+	"github.com/naveeshkumar24/internal/rbac"
+	"github.com/naveeshkumar24/internal/validation"
+	"github.com/naveeshkumar24/pkg/utils"
+)
+
+// CommentPathV2 is the URL of a comment in the v2 API, used for Location headers.
+const CommentPathV2 = "/api/v2/comments/%d"
+
+type CommentHandler struct {
+	commentRepo models.CommentInterface
+	access      *ProjectAccess
+	validator   *validation.Validator
+}
+
+func NewCommentHandler(commentRepo models.CommentInterface, access *ProjectAccess, validator *validation.Validator) *CommentHandler {
+	return &CommentHandler{
+		commentRepo: commentRepo,
+		access:      access,
+		validator:   validator,
+	}
+}
+
+// comments returns the comment storage of the caller's organization.
+func (h *CommentHandler) comments(r *http.Request) models.CommentInterface {
+	return h.commentRepo.ForOrg(middleware.OrgID(r))
+}
+
+// CreateComment handles POST /api/v2/tasks/{id}/comments. A parent_id turns
+// the comment into a reply; the parent must belong to the same task.
+func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetClaims(r)
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("Unauthorized"))
+		return
+	}
+
+	taskID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid task ID"))
+		return
+	}
+
+	var req struct {
+		Body     string `json:"body"`
+		ParentID int    `json:"parent_id"`
+	}
+	if err := utils.Decode(r, &req); err != nil {
+		log.Printf("Failed to decode comment: %v", err)
+		apierror.Write(w, r, err)
+		return
+	}
+
+	if _, _, ok := h.access.loadTask(w, r, taskID); !ok {
+		return
+	}
+
+	comment := models.Comment{
+		TaskID:   taskID,
+		ParentID: req.ParentID,
+		AuthorID: claims.UserID,
+		Body:     req.Body,
+	}
+	if err := h.validator.Validate(comment); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	created, err := h.comments(r).CreateComment(comment)
+	if err != nil {
+		log.Printf("Failed to create comment: %v", err)
+		apierror.Write(w, r, apierror.From(err, "Failed to create comment"))
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf(CommentPathV2, created.ID))
+	w.WriteHeader(http.StatusCreated)
+	utils.Encode(w, created)
+}
+
+// ListComments handles GET /api/v2/tasks/{id}/comments and returns the
+// top-level comments of a task in posting order.
+func (h *CommentHandler) ListComments(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid task ID"))
+		return
+	}
+
+	if _, _, ok := h.access.loadTask(w, r, taskID); !ok {
+		return
+	}
+
+	h.listComments(w, r, taskID, 0)
+}
+
+// ListReplies handles GET /api/v2/comments/{id}/replies.
+func (h *CommentHandler) ListReplies(w http.ResponseWriter, r *http.Request) {
+	parent, _, ok := h.loadComment(w, r)
+	if !ok {
+		return
+	}
+
+	h.listComments(w, r, parent.TaskID, parent.ID)
+}
+
+func (h *CommentHandler) GetComment(w http.ResponseWriter, r *http.Request) {
+	comment, _, ok := h.loadComment(w, r)
+	if !ok {
+		return
+	}
+
+	utils.Encode(w, comment)
+}
+
+// UpdateComment handles PATCH /api/v2/comments/{id}. Only the author may
+// edit a comment, and only its body can change.
+func (h *CommentHandler) UpdateComment(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetClaims(r)
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("Unauthorized"))
+		return
+	}
+
+	var req struct {
+		Body string `json:"body"`
+	}
+	if err := utils.Decode(r, &req); err != nil {
+		log.Printf("Failed to decode comment update: %v", err)
+		apierror.Write(w, r, err)
+		return
+	}
+
+	existing, _, ok := h.loadComment(w, r)
+	if !ok {
+		return
+	}
+	if existing.AuthorID != claims.UserID {
+		apierror.Write(w, r, apierror.Forbidden("Only the author can edit a comment"))
+		return
+	}
+
+	existing.Body = req.Body
+	if err := h.validator.Validate(existing); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	updated, err := h.comments(r).UpdateComment(existing.ID, req.Body)
+	if err != nil {
+		log.Printf("Failed to update comment: %v", err)
+		apierror.Write(w, r, apierror.From(err, "Failed to update comment"))
+		return
+	}
+
+	utils.Encode(w, updated)
+}
+
+// DeleteComment handles DELETE /api/v2/comments/{id}. Replies are deleted
+// with their parent.
+func (h *CommentHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetClaims(r)
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("Unauthorized"))
+		return
+	}
+
+	existing, role, ok := h.loadComment(w, r)
+	if !ok {
+		return
+	}
+	if existing.AuthorID != claims.UserID && !rbac.Can(role, rbac.ActionCommentDeleteAny) {
+		apierror.Write(w, r, apierror.Forbidden("You are not allowed to delete this comment"))
+		return
+	}
+
+	if err := h.comments(r).DeleteComment(existing.ID); err != nil {
+		log.Printf("Failed to delete comment: %v", err)
+		apierror.Write(w, r, apierror.From(err, "Failed to delete comment"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadComment fetches the comment named by the {id} route variable and the
+// caller's effective role on its task, writing the error response itself
+// when that fails.
+func (h *CommentHandler) loadComment(w http.ResponseWriter, r *http.Request) (models.Comment, string, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid comment ID"))
+		return models.Comment{}, "", false
+	}
+
+	comment, err := h.comments(r).GetComment(id)
+	if err != nil {
+		log.Printf("Comment not found: %v", err)
+		apierror.Write(w, r, apierror.From(err, "Failed to load comment"))
+		return models.Comment{}, "", false
+	}
+
+	_, role, ok := h.access.loadTask(w, r, comment.TaskID)
+	if !ok {
+		return models.Comment{}, "", false
+	}
+	return comment, role, true
+}
+
+func (h *CommentHandler) listComments(w http.ResponseWriter, r *http.Request, taskID, parentID int) {
+	opts, err := parseCommentListOptions(r)
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	page, err := h.comments(r).ListComments(taskID, parentID, opts)
+	if err != nil {
+		log.Printf("Failed to list comments: %v", err)
+		apierror.Write(w, r, apierror.From(err, "Failed to list comments"))
+		return
+	}
+
+	utils.Encode(w, page)
+}
+
+// parseCommentListOptions reads limit and after. Comments are always listed
+// in posting order, so sort and order are not accepted.
+func parseCommentListOptions(r *http.Request) (models.ListOptions, error) {
+	params := r.URL.Query()
+	opts := models.ListOptions{
+		Limit: models.DefaultPageSize,
+		After: params.Get("after"),
+		Sort:  models.CommentSort,
+		Order: models.SortAsc,
+	}
+
+	if limitStr := params.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > models.MaxPageSize {
+			return opts, fmt.Errorf("limit must be between 1 and %d", models.MaxPageSize)
+		}
+		opts.Limit = limit
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,470 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/naveeshkumar24/internal/apierror"
+	"github.com/naveeshkumar24/internal/middleware"
+	"github.com/naveeshkumar24/internal/models"
+	"github.com/naveeshkumar24/internal/rbac"
+	"github.com/naveeshkumar24/internal/validation"
+	"github.com/naveeshkumar24/pkg/utils"
+)
+
+// InvitationTTL is how long a project invitation can be accepted.
+const InvitationTTL = 7 * 24 * time.Hour
+
+// MembershipHandler serves the members, team grants and invitations of a
+// project. Its project routes are wrapped in ProjectHandler.Scoped, so the
+// project exists and the caller is a member by the time they run.
+type MembershipHandler struct {
+	memberships models.MembershipInterface
+	projectRepo models.ProjectInterface
+	access      *ProjectAccess
+	validator   *validation.Validator
+}
+
+func NewMembershipHandler(memberships models.MembershipInterface, projectRepo models.ProjectInterface, access *ProjectAccess, validator *validation.Validator) *MembershipHandler {
+	return &MembershipHandler{
+		memberships: memberships,
+		projectRepo: projectRepo,
+		access:      access,
+		validator:   validator,
+	}
+}
+
+// members returns the membership storage of the caller's organization.
+func (h *MembershipHandler) members(r *http.Request) models.MembershipInterface {
+	return h.memberships.ForOrg(middleware.OrgID(r))
+}
+
+// projects returns the project storage of the caller's organization.
+func (h *MembershipHandler) projects(r *http.Request) models.ProjectInterface {
+	return h.projectRepo.ForOrg(middleware.OrgID(r))
+}
+
+// ListMembers handles GET /api/v2/projects/{projectID}/members. Users who
+// belong through a team are listed once per team, with the team's ID.
+func (h *MembershipHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := routeProjectID(r)
+	if !ok {
+		apierror.Write(w, r, apierror.BadRequest("Invalid project ID"))
+		return
+	}
+
+	members, err := h.members(r).ListProjectMembers(projectID)
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to list members"))
+		return
+	}
+
+	utils.Encode(w, map[string]interface{}{"items": members})
+}
+
+// SetMember handles PUT /api/v2/projects/{projectID}/members/{userID}. It
+// adds the user with the given role or changes their role. Nobody may grant
+// a role above their own or change the role of someone who outranks them.
+func (h *MembershipHandler) SetMember(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := utils.Decode(r, &req); err != nil {
+		log.Printf("Failed to decode project member: %v", err)
+		apierror.Write(w, r, err)
+		return
+	}
+
+	projectID, userID, callerRole, ok := h.memberRoute(w, r)
+	if !ok {
+		return
+	}
+	if !rbac.ValidRole(req.Role) {
+		apierror.Write(w, r, validation.Errors{"role": "must be one of admin, manager, user"})
+		return
+	}
+	if !h.canGrant(w, r, callerRole, req.Role) {
+		return
+	}
+
+	current, ok := h.directRole(w, r, projectID, userID)
+	if !ok {
+		return
+	}
+	if rbac.RoleRank(current) > rbac.RoleRank(callerRole) {
+		apierror.Write(w, r, apierror.Forbidden("Not allowed to change the role of this member"))
+		return
+	}
+	if current == models.RoleAdmin && req.Role != models.RoleAdmin && !h.keepsAdmin(w, r, projectID) {
+		return
+	}
+
+	if err := h.members(r).SetProjectMember(projectID, userID, req.Role); err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to set project member"))
+		return
+	}
+
+	utils.Encode(w, map[string]interface{}{"project_id": projectID, "user_id": userID, "role": req.Role})
+}
+
+// RemoveMember handles DELETE /api/v2/projects/{projectID}/members/{userID}.
+// Members may always remove themselves; removing others takes the right to
+// manage members and at least the role being removed.
+func (h *MembershipHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, callerRole, ok := h.memberRoute(w, r)
+	if !ok {
+		return
+	}
+	claims, _ := middleware.GetClaims(r)
+
+	current, ok := h.directRole(w, r, projectID, userID)
+	if !ok {
+		return
+	}
+	if current == "" {
+		apierror.Write(w, r, apierror.NotFound("Member not found"))
+		return
+	}
+	if userID != claims.UserID {
+		if !rbac.Can(callerRole, rbac.ActionProjectMembers) || rbac.RoleRank(current) > rbac.RoleRank(callerRole) {
+			apierror.Write(w, r, apierror.Forbidden("Not allowed to remove this member"))
+			return
+		}
+	}
+	if current == models.RoleAdmin && !h.keepsAdmin(w, r, projectID) {
+		return
+	}
+
+	if err := h.members(r).RemoveProjectMember(projectID, userID); err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to remove project member"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListTeams handles GET /api/v2/projects/{projectID}/teams.
+func (h *MembershipHandler) ListTeams(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := routeProjectID(r)
+	if !ok {
+		apierror.Write(w, r, apierror.BadRequest("Invalid project ID"))
+		return
+	}
+
+	teams, err := h.members(r).ListProjectTeams(projectID)
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to list project teams"))
+		return
+	}
+
+	utils.Encode(w, map[string]interface{}{"items": teams})
+}
+
+// SetTeam handles PUT /api/v2/projects/{projectID}/teams/{teamID} and gives
+// every member of the team the role on the project.
+func (h *MembershipHandler) SetTeam(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := utils.Decode(r, &req); err != nil {
+		log.Printf("Failed to decode project team: %v", err)
+		apierror.Write(w, r, err)
+		return
+	}
+
+	projectID, teamID, callerRole, ok := h.teamRoute(w, r)
+	if !ok {
+		return
+	}
+	if !rbac.ValidRole(req.Role) {
+		apierror.Write(w, r, validation.Errors{"role": "must be one of admin, manager, user"})
+		return
+	}
+	if !h.canGrant(w, r, callerRole, req.Role) {
+		return
+	}
+
+	if err := h.members(r).SetProjectTeam(projectID, teamID, req.Role); err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to set project team"))
+		return
+	}
+
+	utils.Encode(w, map[string]interface{}{"project_id": projectID, "team_id": teamID, "role": req.Role})
+}
+
+// RemoveTeam handles DELETE /api/v2/projects/{projectID}/teams/{teamID}.
+func (h *MembershipHandler) RemoveTeam(w http.ResponseWriter, r *http.Request) {
+	projectID, teamID, callerRole, ok := h.teamRoute(w, r)
+	if !ok {
+		return
+	}
+	if !h.canManageMembers(w, r, callerRole) {
+		return
+	}
+
+	if err := h.members(r).RemoveProjectTeam(projectID, teamID); err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to remove project team"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateInvitation handles POST /api/v2/projects/{projectID}/invitations.
+// The token is returned only in this response; the invitee accepts it with
+// POST /api/v2/invitations/accept after signing in with the invited email.
+func (h *MembershipHandler) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+	if err := utils.Decode(r, &req); err != nil {
+		log.Printf("Failed to decode invitation: %v", err)
+		apierror.Write(w, r, err)
+		return
+	}
+
+	projectID, ok := routeProjectID(r)
+	if !ok {
+		apierror.Write(w, r, apierror.BadRequest("Invalid project ID"))
+		return
+	}
+	callerRole, ok := h.access.projectRole(w, r, projectID)
+	if !ok {
+		return
+	}
+
+	claims, _ := middleware.GetClaims(r)
+	invitation := models.Invitation{
+		ProjectID: projectID,
+		Email:     strings.ToLower(strings.TrimSpace(req.Email)),
+		Role:      req.Role,
+		InvitedBy: claims.UserID,
+		ExpiresAt: time.Now().Add(InvitationTTL).UTC(),
+	}
+	if err := h.validator.Validate(invitation); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if !h.canGrant(w, r, callerRole, invitation.Role) {
+		return
+	}
+
+	token, hash, err := utils.GenerateInvitationToken()
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("Could not generate invitation", err))
+		return
+	}
+	invitation.TokenHash = hash
+
+	created, err := h.members(r).CreateInvitation(invitation)
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to create invitation"))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	utils.Encode(w, map[string]interface{}{
+		"invitation": created,
+		"token":      token,
+	})
+}
+
+// ListInvitations handles GET /api/v2/projects/{projectID}/invitations and
+// returns the invitations that can still be accepted.
+func (h *MembershipHandler) ListInvitations(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := routeProjectID(r)
+	if !ok {
+		apierror.Write(w, r, apierror.BadRequest("Invalid project ID"))
+		return
+	}
+	callerRole, ok := h.access.projectRole(w, r, projectID)
+	if !ok || !h.canManageMembers(w, r, callerRole) {
+		return
+	}
+
+	invitations, err := h.members(r).ListInvitations(projectID)
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to list invitations"))
+		return
+	}
+
+	utils.Encode(w, map[string]interface{}{"items": invitations})
+}
+
+// DeleteInvitation handles DELETE
+// /api/v2/projects/{projectID}/invitations/{invitationID} and revokes the
+// invitation.
+func (h *MembershipHandler) DeleteInvitation(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := routeProjectID(r)
+	if !ok {
+		apierror.Write(w, r, apierror.BadRequest("Invalid project ID"))
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["invitationID"])
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid invitation ID"))
+		return
+	}
+	callerRole, ok := h.access.projectRole(w, r, projectID)
+	if !ok || !h.canManageMembers(w, r, callerRole) {
+		return
+	}
+
+	if err := h.members(r).DeleteInvitation(projectID, id); err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to revoke invitation"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AcceptInvitation handles POST /api/v2/invitations/accept. The caller must
+// be signed in with the email the invitation was sent to. A role the caller
+// already holds directly on the project is only ever raised, never lowered.
+func (h *MembershipHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetClaims(r)
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("Unauthorized"))
+		return
+	}
+
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := utils.Decode(r, &req); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if req.Token == "" {
+		apierror.Write(w, r, validation.Errors{"token": "is required"})
+		return
+	}
+
+	invitation, err := h.members(r).GetInvitationByToken(utils.HashInvitationToken(req.Token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Write(w, r, apierror.NotFound("Invitation not found"))
+			return
+		}
+		apierror.Write(w, r, apierror.From(err, "Failed to load invitation"))
+		return
+	}
+	if !strings.EqualFold(invitation.Email, claims.Email) {
+		apierror.Write(w, r, apierror.Forbidden("Invitation was sent to a different email address"))
+		return
+	}
+
+	if err := h.members(r).AcceptInvitation(invitation.ID, claims.UserID); err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to accept invitation"))
+		return
+	}
+
+	project, err := h.projects(r).GetProject(invitation.ProjectID)
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to load project"))
+		return
+	}
+
+	utils.Encode(w, project)
+}
+
+// memberRoute reads {projectID} and {userID} and resolves the caller's
+// effective role on the project, writing the error response itself when
+// that fails.
+func (h *MembershipHandler) memberRoute(w http.ResponseWriter, r *http.Request) (projectID, userID int, role string, ok bool) {
+	projectID, ok = routeProjectID(r)
+	if !ok {
+		apierror.Write(w, r, apierror.BadRequest("Invalid project ID"))
+		return 0, 0, "", false
+	}
+	userID, err := strconv.Atoi(mux.Vars(r)["userID"])
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid user ID"))
+		return 0, 0, "", false
+	}
+	role, ok = h.access.projectRole(w, r, projectID)
+	return projectID, userID, role, ok
+}
+
+// teamRoute is memberRoute for {teamID}.
+func (h *MembershipHandler) teamRoute(w http.ResponseWriter, r *http.Request) (projectID, teamID int, role string, ok bool) {
+	projectID, ok = routeProjectID(r)
+	if !ok {
+		apierror.Write(w, r, apierror.BadRequest("Invalid project ID"))
+		return 0, 0, "", false
+	}
+	teamID, err := strconv.Atoi(mux.Vars(r)["teamID"])
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid team ID"))
+		return 0, 0, "", false
+	}
+	role, ok = h.access.projectRole(w, r, projectID)
+	return projectID, teamID, role, ok
+}
+
+// canManageMembers reports whether role may change who belongs to the
+// project, writing a 403 response when it may not.
+func (h *MembershipHandler) canManageMembers(w http.ResponseWriter, r *http.Request, role string) bool {
+	if !rbac.Can(role, rbac.ActionProjectMembers) {
+		apierror.Write(w, r, apierror.Forbidden("Not allowed to manage members of this project"))
+		return false
+	}
+	return true
+}
+
+// canGrant reports whether a caller acting with role may hand out granted,
+// writing a 403 response when they may not.
+func (h *MembershipHandler) canGrant(w http.ResponseWriter, r *http.Request, role, granted string) bool {
+	if !h.canManageMembers(w, r, role) {
+		return false
+	}
+	if rbac.RoleRank(granted) > rbac.RoleRank(role) {
+		apierror.Write(w, r, apierror.Forbidden("Not allowed to grant a role above your own"))
+		return false
+	}
+	return true
+}
+
+// directRole returns the role userID holds directly on the project, or ""
+// if they are not a direct member.
+func (h *MembershipHandler) directRole(w http.ResponseWriter, r *http.Request, projectID, userID int) (string, bool) {
+	members, err := h.members(r).ListProjectMembers(projectID)
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to load project members"))
+		return "", false
+	}
+	for _, member := range members {
+		if member.TeamID == 0 && member.UserID == userID {
+			return member.Role, true
+		}
+	}
+	return "", true
+}
+
+// keepsAdmin responds 409 when the project has a single direct admin, who
+// is about to be demoted or removed.
+func (h *MembershipHandler) keepsAdmin(w http.ResponseWriter, r *http.Request, projectID int) bool {
+	members, err := h.members(r).ListProjectMembers(projectID)
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to load project members"))
+		return false
+	}
+	admins := 0
+	for _, member := range members {
+		if member.TeamID == 0 && member.Role == models.RoleAdmin {
+			admins++
+		}
+	}
+	if admins <= 1 {
+		apierror.Write(w, r, models.ErrLastProjectAdmin)
+		return false
+	}
+	return true
+}
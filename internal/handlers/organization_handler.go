@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/naveeshkumar24/internal/apierror"
+	"github.com/naveeshkumar24/internal/middleware"
+	"github.com/naveeshkumar24/internal/models"
+	"github.com/naveeshkumar24/pkg/utils"
+)
+
+type OrganizationHandler struct {
+	orgRepo models.OrganizationInterface
+}
+
+func NewOrganizationHandler(orgRepo models.OrganizationInterface) *OrganizationHandler {
+	return &OrganizationHandler{
+		orgRepo: orgRepo,
+	}
+}
+
+// GetOrganization handles GET /api/v2/organization, returning the caller's
+// organization.
+func (h *OrganizationHandler) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	org, err := h.orgRepo.GetOrganization(middleware.OrgID(r))
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to load organization"))
+		return
+	}
+
+	utils.Encode(w, org)
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/naveeshkumar24/internal/apierror"
+	"github.com/naveeshkumar24/internal/middleware"
+	"github.com/naveeshkumar24/internal/models"
+	"github.com/naveeshkumar24/internal/rbac"
+	"github.com/naveeshkumar24/internal/validation"
+	"github.com/naveeshkumar24/pkg/utils"
+)
+
+// ProjectPathV2 is the URL of a project in the v2 API, used for Location headers.
+const ProjectPathV2 = "/api/v2/projects/%d"
+
+type ProjectHandler struct {
+	projectRepo models.ProjectInterface
+	memberships models.MembershipInterface
+	access      *ProjectAccess
+	validator   *validation.Validator
+}
+
+func NewProjectHandler(projectRepo models.ProjectInterface, memberships models.MembershipInterface, access *ProjectAccess, validator *validation.Validator) *ProjectHandler {
+	return &ProjectHandler{
+		projectRepo: projectRepo,
+		memberships: memberships,
+		access:      access,
+		validator:   validator,
+	}
+}
+
+// projects returns the project storage of the caller's organization.
+func (h *ProjectHandler) projects(r *http.Request) models.ProjectInterface {
+	return h.projectRepo.ForOrg(middleware.OrgID(r))
+}
+
+// members returns the membership storage of the caller's organization.
+func (h *ProjectHandler) members(r *http.Request) models.MembershipInterface {
+	return h.memberships.ForOrg(middleware.OrgID(r))
+}
+
+// ListProjects handles GET /api/v2/projects and returns the projects the
+// caller is a member of; global admins see all of them. Archived projects
+// are left out unless archived=true.
+func (h *ProjectHandler) ListProjects(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetClaims(r)
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("Unauthorized"))
+		return
+	}
+
+	includeArchived := false
+	if value := r.URL.Query().Get("archived"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			apierror.Write(w, r, apierror.BadRequest("archived must be true or false"))
+			return
+		}
+		includeArchived = parsed
+	}
+
+	projects, err := h.projects(r).ListProjects(includeArchived)
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to list projects"))
+		return
+	}
+
+	if claims.Role != models.RoleAdmin {
+		memberOf, err := h.members(r).ListMemberProjects(claims.UserID)
+		if err != nil {
+			apierror.Write(w, r, apierror.From(err, "Failed to list projects"))
+			return
+		}
+		visible := projects[:0]
+		for _, project := range projects {
+			if slices.Contains(memberOf, project.ID) {
+				visible = append(visible, project)
+			}
+		}
+		projects = visible
+	}
+
+	utils.Encode(w, map[string]interface{}{"items": projects})
+}
+
+// CreateProject handles POST /api/v2/projects. The caller becomes the
+// project's first admin.
+func (h *ProjectHandler) CreateProject(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetClaims(r)
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("Unauthorized"))
+		return
+	}
+
+	var req struct {
+		Key         string `json:"key"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err := utils.Decode(r, &req); err != nil {
+		log.Printf("Failed to decode project: %v", err)
+		apierror.Write(w, r, err)
+		return
+	}
+
+	project := models.Project{
+		Key:         req.Key,
+		Name:        req.Name,
+		Description: req.Description,
+		CreatedBy:   claims.UserID,
+	}
+	if err := h.validator.ForOrg(middleware.OrgID(r)).Validate(project); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	created, err := h.projects(r).CreateProject(project)
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to create project"))
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf(ProjectPathV2, created.ID))
+	w.WriteHeader(http.StatusCreated)
+	utils.Encode(w, created)
+}
+
+func (h *ProjectHandler) GetProject(w http.ResponseWriter, r *http.Request) {
+	project, _, ok := h.loadProject(w, r)
+	if !ok {
+		return
+	}
+
+	utils.Encode(w, project)
+}
+
+// UpdateProject handles PUT /api/v2/projects/{projectID}, replacing the name
+// and description. The key is fixed; a body key, if present, must agree.
+func (h *ProjectHandler) UpdateProject(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Key         string `json:"key"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err := utils.Decode(r, &req); err != nil {
+		log.Printf("Failed to decode project update: %v", err)
+		apierror.Write(w, r, err)
+		return
+	}
+
+	project, role, ok := h.loadProject(w, r)
+	if !ok || !h.canManage(w, r, role) {
+		return
+	}
+	if req.Key != "" && req.Key != project.Key {
+		apierror.Write(w, r, validation.Errors{"key": "cannot be changed"})
+		return
+	}
+
+	project.Name = req.Name
+	project.Description = req.Description
+	if err := h.validator.ForOrg(middleware.OrgID(r)).Validate(project); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	updated, err := h.projects(r).UpdateProject(project)
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to update project"))
+		return
+	}
+
+	utils.Encode(w, updated)
+}
+
+// ArchiveProject handles PUT /api/v2/projects/{projectID}/archive.
+func (h *ProjectHandler) ArchiveProject(w http.ResponseWriter, r *http.Request) {
+	h.setArchived(w, r, true)
+}
+
+// RestoreProject handles DELETE /api/v2/projects/{projectID}/archive.
+func (h *ProjectHandler) RestoreProject(w http.ResponseWriter, r *http.Request) {
+	h.setArchived(w, r, false)
+}
+
+func (h *ProjectHandler) setArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+	existing, role, ok := h.loadProject(w, r)
+	if !ok || !h.canManage(w, r, role) {
+		return
+	}
+
+	project, err := h.projects(r).SetProjectArchived(existing.ID, archived)
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to update project"))
+		return
+	}
+
+	utils.Encode(w, project)
+}
+
+// DeleteProject handles DELETE /api/v2/projects/{projectID}. Only projects
+// without tasks can be deleted; archive the others.
+func (h *ProjectHandler) DeleteProject(w http.ResponseWriter, r *http.Request) {
+	project, role, ok := h.loadProject(w, r)
+	if !ok || !h.canManage(w, r, role) {
+		return
+	}
+
+	if err := h.projects(r).DeleteProject(project.ID); err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to delete project"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Scoped wraps a handler for a /projects/{projectID}/... route and responds
+// 404 when the project does not exist or the caller is not a member.
+func (h *ProjectHandler) Scoped(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := h.loadProject(w, r); !ok {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// canManage reports whether role may edit, archive and delete the project,
+// writing a 403 response when it may not.
+func (h *ProjectHandler) canManage(w http.ResponseWriter, r *http.Request, role string) bool {
+	if !rbac.Can(role, rbac.ActionProjectManage) {
+		apierror.Write(w, r, apierror.Forbidden("Not allowed to manage this project"))
+		return false
+	}
+	return true
+}
+
+// loadProject fetches the project named by the {projectID} route variable
+// and the caller's effective role on it, writing the error response itself
+// when that fails. Non-members get the same 404 as for a missing project.
+func (h *ProjectHandler) loadProject(w http.ResponseWriter, r *http.Request) (models.Project, string, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["projectID"])
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid project ID"))
+		return models.Project{}, "", false
+	}
+
+	project, err := h.projects(r).GetProject(id)
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to load project"))
+		return models.Project{}, "", false
+	}
+
+	role, ok := h.access.projectRole(w, r, project.ID)
+	if !ok {
+		return models.Project{}, "", false
+	}
+	return project, role, true
+}
@@ -1,64 +1,173 @@
 package handlers
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"mime"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/naveeshkumar24/internal/apierror"
+	"github.com/naveeshkumar24/internal/middleware"
 	"github.com/naveeshkumar24/internal/models"
+	"github.com/naveeshkumar24/internal/rbac"
+	"github.com/naveeshkumar24/internal/validation"
+	"github.com/naveeshkumar24/internal/workflow"
+	"github.com/naveeshkumar24/pkg/jsonpatch"
 	"github.com/naveeshkumar24/pkg/utils"
-	"github.com/naveeshkumar24/repository"
 )
 
 type TaskHandler struct {
-	taskRepo *repository.TaskRepository
+	taskRepo  models.TaskInterface
+	userRepo  models.UserInterface
+	access    *ProjectAccess
+	workflow  *workflow.Machine
+	validator *validation.Validator
 }
 
-func NewTaskHandler(taskRepo models.TaskInterface) *TaskHandler {
+func NewTaskHandler(taskRepo models.TaskInterface, userRepo models.UserInterface, access *ProjectAccess, machine *workflow.Machine, validator *validation.Validator) *TaskHandler {
 	return &TaskHandler{
-		taskRepo: taskRepo.(*repository.TaskRepository),
+		taskRepo:  taskRepo,
+		userRepo:  userRepo,
+		access:    access,
+		workflow:  machine,
+		validator: validator,
 	}
 }
 
+// tasks returns the task storage of the caller's organization.
+func (h *TaskHandler) tasks(r *http.Request) models.TaskInterface {
+	return h.taskRepo.ForOrg(middleware.OrgID(r))
+}
+
 func (h *TaskHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.createTask(w, r); !ok {
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	utils.Encode(w, map[string]string{"message": "Task created successfully"})
+}
+
+// createTask decodes and stores a new task, writing the error response itself
+// when that fails.
+func (h *TaskHandler) createTask(w http.ResponseWriter, r *http.Request) (models.Task, bool) {
 	var task models.Task
 	err := utils.Decode(r, &task)
 	if err != nil {
 		log.Printf("Failed to decode task data: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
-		utils.Encode(w, map[string]string{"message": "Invalid request body"})
-		return
+		apierror.Write(w, r, err)
+		return models.Task{}, false
+	}
+
+	claims, _ := middleware.GetClaims(r)
+
+	// The creator is always the authenticated caller, never the request body
+	task.CreatedBy = claims.UserID
+
+	if projectID, ok := routeProjectID(r); ok {
+		if task.ProjectID != 0 && task.ProjectID != projectID {
+			apierror.Write(w, r, apierror.BadRequest("Project ID in the body does not match the URL"))
+			return models.Task{}, false
+		}
+		task.ProjectID = projectID
+	}
+
+	// Subtasks go into the project of their parent unless one is given
+	if task.ParentID != 0 {
+		parent, err := h.tasks(r).GetTaskByID(task.ParentID)
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Write(w, r, &models.ConstraintError{Err: models.ErrReferenceNotFound, Field: "parent_id"})
+			return models.Task{}, false
+		}
+		if err != nil {
+			apierror.Write(w, r, apierror.From(err, "Failed to load parent task"))
+			return models.Task{}, false
+		}
+		if task.ProjectID == 0 {
+			task.ProjectID = parent.ProjectID
+		}
 	}
 
-	err = h.taskRepo.CreateTask(task)
+	role, err := h.access.Role(claims, task.ProjectID)
 	if err != nil {
-		log.Printf("Failed to create task: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		utils.Encode(w, map[string]string{"message": "Failed to create task"})
-		return
+		apierror.Write(w, r, apierror.From(err, "Failed to check project membership"))
+		return models.Task{}, false
+	}
+	if role == "" {
+		// Projects the caller does not belong to look the same as missing ones
+		apierror.Write(w, r, &models.ConstraintError{Err: models.ErrReferenceNotFound, Field: "project_id"})
+		return models.Task{}, false
+	}
+	if !rbac.Can(role, rbac.ActionTaskCreate) {
+		apierror.Write(w, r, apierror.Forbidden("Not allowed to create tasks in this project"))
+		return models.Task{}, false
+	}
+	if !canAssign(claims.UserID, role, task.AssignedTo) {
+		apierror.Write(w, r, apierror.Forbidden("Not allowed to assign tasks to other users"))
+		return models.Task{}, false
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	utils.Encode(w, map[string]string{"message": "Task created successfully"})
+	if task.Status == "" {
+		task.Status = models.StatusTodo
+	}
+	if task.Priority == "" {
+		task.Priority = models.PriorityMedium
+	}
+	if err := h.validator.ForOrg(middleware.OrgID(r)).Validate(task); err != nil {
+		apierror.Write(w, r, err)
+		return models.Task{}, false
+	}
+
+	created, err := h.tasks(r).CreateTask(task)
+	if err != nil {
+		log.Printf("Failed to create task: %v", err)
+		apierror.Write(w, r, apierror.From(err, "Failed to create task"))
+		return models.Task{}, false
+	}
+	return created, true
 }
 
+// GetTask looks a task up by its numeric {id} or, on routes that have one,
+// by its project {key} such as OPS-42.
 func (h *TaskHandler) GetTask(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	idStr := vars["id"]
-	id, err := strconv.Atoi(idStr)
+	var task models.Task
+	var err error
+	if key := vars["key"]; key != "" {
+		task, err = h.tasks(r).GetTaskByKey(key)
+	} else {
+		var id int
+		id, err = strconv.Atoi(vars["id"])
+		if err != nil {
+			log.Printf("Invalid ID format: %v", err)
+			apierror.Write(w, r, apierror.BadRequest("Invalid task ID"))
+			return
+		}
+		task, err = h.tasks(r).GetTaskByID(id)
+	}
 	if err != nil {
-		log.Printf("Invalid ID format: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
-		utils.Encode(w, map[string]string{"message": "Invalid task ID"})
+		log.Printf("Task not found: %v", err)
+		apierror.Write(w, r, apierror.NotFound("Task not found"))
+		return
+	}
+	if _, ok := h.access.taskRole(w, r, task); !ok {
 		return
 	}
 
-	task, err := h.taskRepo.GetTaskByID(id)
-	if err != nil {
-		log.Printf("Task not found: %v", err)
-		w.WriteHeader(http.StatusNotFound)
-		utils.Encode(w, map[string]string{"message": "Task not found"})
+	etag := taskETag(task)
+	w.Header().Set("ETag", etag)
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
@@ -71,16 +180,11 @@ func (h *TaskHandler) UpdateTask(w http.ResponseWriter, r *http.Request) {
 	err := utils.Decode(r, &task)
 	if err != nil {
 		log.Printf("Failed to decode task update: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
-		utils.Encode(w, map[string]string{"message": "Invalid request body"})
+		apierror.Write(w, r, err)
 		return
 	}
 
-	err = h.taskRepo.UpdateTask(task)
-	if err != nil {
-		log.Printf("Failed to update task: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		utils.Encode(w, map[string]string{"message": "Failed to update task"})
+	if _, ok := h.replaceTask(w, r, task); !ok {
 		return
 	}
 
@@ -88,74 +192,620 @@ func (h *TaskHandler) UpdateTask(w http.ResponseWriter, r *http.Request) {
 	utils.Encode(w, map[string]string{"message": "Task updated successfully"})
 }
 
+// replaceTask overwrites the stored task with task and returns the result,
+// writing the error response itself when that fails.
+func (h *TaskHandler) replaceTask(w http.ResponseWriter, r *http.Request, task models.Task) (models.Task, bool) {
+	existing, role, ok := h.access.loadTask(w, r, task.ID)
+	if !ok {
+		return models.Task{}, false
+	}
+	if !checkIfMatch(w, r, existing) {
+		return models.Task{}, false
+	}
+
+	claims, _ := middleware.GetClaims(r)
+	switch rbac.TaskUpdateAccess(role, claims.UserID, existing) {
+	case rbac.TaskAccessNone:
+		apierror.Write(w, r, apierror.Forbidden("Not allowed to update this task"))
+		return models.Task{}, false
+	case rbac.TaskAccessStatus:
+		// Assignees may only move the task along; everything else is kept as is
+		status := task.Status
+		task = existing
+		task.Status = status
+	}
+	if task.ProjectID != 0 && task.ProjectID != existing.ProjectID {
+		apierror.Write(w, r, validation.Errors{"project_id": "cannot be changed"})
+		return models.Task{}, false
+	}
+	task.CreatedBy = existing.CreatedBy
+	task.Version = existing.Version
+	task.ProjectID = existing.ProjectID
+	task.Key = existing.Key
+
+	return h.saveTaskUpdate(w, r, claims.UserID, role, existing, task)
+}
+
+// PatchTask applies a JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902)
+// to a task, chosen by Content-Type, and responds with the updated task.
+func (h *TaskHandler) PatchTask(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("Invalid ID format: %v", err)
+		apierror.Write(w, r, apierror.BadRequest("Invalid task ID"))
+		return
+	}
+
+	var apply func(doc, patch []byte) ([]byte, error)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case jsonpatch.MergePatchType, "application/json":
+		apply = jsonpatch.MergePatch
+	case jsonpatch.JSONPatchType:
+		apply = jsonpatch.Apply
+	default:
+		w.Header().Set("Accept-Patch", jsonpatch.MergePatchType+", "+jsonpatch.JSONPatchType)
+		apierror.Write(w, r, apierror.UnsupportedMediaType("Unsupported patch format"))
+		return
+	}
+
+	patch, err := utils.ReadBody(r)
+	if err != nil {
+		log.Printf("Failed to read task patch: %v", err)
+		apierror.Write(w, r, err)
+		return
+	}
+
+	existing, role, ok := h.access.loadTask(w, r, id)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(w, r, existing) {
+		return
+	}
+
+	task, err := patchTask(existing, patch, apply)
+	if err != nil {
+		log.Printf("Failed to apply task patch: %v", err)
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			apierror.Write(w, r, apierror.New(http.StatusConflict, apierror.CodeConflict, err.Error()))
+			return
+		}
+		apierror.Write(w, r, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	if errs := readOnlyTaskFields(existing, task); len(errs) > 0 {
+		apierror.Write(w, r, errs)
+		return
+	}
+
+	claims, _ := middleware.GetClaims(r)
+	switch rbac.TaskUpdateAccess(role, claims.UserID, existing) {
+	case rbac.TaskAccessNone:
+		apierror.Write(w, r, apierror.Forbidden("Not allowed to update this task"))
+		return
+	case rbac.TaskAccessStatus:
+		changed := existing
+		changed.Status = task.Status
+		if !sameJSON(changed, task) {
+			apierror.Write(w, r, apierror.Forbidden("Assignees may only change the task status"))
+			return
+		}
+	}
+
+	updated, ok := h.saveTaskUpdate(w, r, claims.UserID, role, existing, task)
+	if !ok {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	utils.Encode(w, updated)
+}
+
+// saveTaskUpdate runs the checks shared by full and partial updates, stores
+// the task and returns it as stored with its ETag set, writing the error
+// response itself when one fails. role is the caller's effective role on the
+// task's project.
+func (h *TaskHandler) saveTaskUpdate(w http.ResponseWriter, r *http.Request, userID int, role string, existing, task models.Task) (models.Task, bool) {
+	if task.AssignedTo != existing.AssignedTo && !canAssign(userID, role, task.AssignedTo) {
+		apierror.Write(w, r, apierror.Forbidden("Not allowed to assign tasks to other users"))
+		return models.Task{}, false
+	}
+
+	if err := h.validator.ForOrg(middleware.OrgID(r)).Validate(task); err != nil {
+		apierror.Write(w, r, err)
+		return models.Task{}, false
+	}
+
+	if !h.checkTransition(w, r, existing.Status, task.Status, role) {
+		return models.Task{}, false
+	}
+
+	// Completion is checked by the storage against the task as it is when
+	// written, as its blockers and subtasks may change after existing was read
+	err := h.tasks(r).UpdateTask(task, func(stored models.Task) error {
+		return h.workflow.CheckCompletion(stored, task.Status)
+	})
+	if err != nil {
+		log.Printf("Failed to update task: %v", err)
+		apierror.Write(w, r, apierror.From(err, "Failed to update task"))
+		return models.Task{}, false
+	}
+
+	updated, err := h.tasks(r).GetTaskByID(task.ID)
+	if err != nil {
+		log.Printf("Failed to reload updated task: %v", err)
+		apierror.Write(w, r, apierror.From(err, "Failed to load updated task"))
+		return models.Task{}, false
+	}
+	w.Header().Set("ETag", taskETag(updated))
+	return updated, true
+}
+
+// patchTask applies patch to the JSON form of task. Members the patch
+// removes come back as zero values, so a null in a merge patch clears them.
+func patchTask(task models.Task, patch []byte, apply func(doc, patch []byte) ([]byte, error)) (models.Task, error) {
+	doc, err := json.Marshal(task)
+	if err != nil {
+		return models.Task{}, err
+	}
+	doc, err = apply(doc, patch)
+	if err != nil {
+		return models.Task{}, err
+	}
+
+	var patched models.Task
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&patched); err != nil {
+		return models.Task{}, fmt.Errorf("patched task is invalid: %w", err)
+	}
+	return patched, nil
+}
+
+// sameJSON compares values by their JSON form, which ignores differences such
+// as time zone pointers that do not survive a round trip.
+func sameJSON(a, b interface{}) bool {
+	x, errA := json.Marshal(a)
+	y, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(x, y)
+}
+
+// readOnlyTaskFields reports fields a patch tried to change that are owned
+// by the server.
+func readOnlyTaskFields(existing, patched models.Task) validation.Errors {
+	errs := validation.Errors{}
+	if patched.ID != existing.ID {
+		errs.Add("id", "is read-only")
+	}
+	if patched.CreatedBy != existing.CreatedBy {
+		errs.Add("created_by", "is read-only")
+	}
+	if !patched.CreatedAt.Equal(existing.CreatedAt) {
+		errs.Add("created_at", "is read-only")
+	}
+	if !patched.UpdatedAt.Equal(existing.UpdatedAt) {
+		errs.Add("updated_at", "is read-only")
+	}
+	if patched.Version != existing.Version {
+		errs.Add("version", "is read-only")
+	}
+	if patched.CommentCount != existing.CommentCount {
+		errs.Add("comment_count", "is read-only")
+	}
+	if patched.Progress != existing.Progress {
+		errs.Add("progress", "is read-only")
+	}
+	if !slices.Equal(patched.BlockedBy, existing.BlockedBy) {
+		errs.Add("blocked_by", "is read-only")
+	}
+	if patched.Blocked != existing.Blocked {
+		errs.Add("blocked", "is read-only")
+	}
+	if patched.ProjectID != existing.ProjectID {
+		errs.Add("project_id", "cannot be changed")
+	}
+	if patched.Key != existing.Key {
+		errs.Add("key", "is read-only")
+	}
+	return errs
+}
+
 func (h *TaskHandler) DeleteTask(w http.ResponseWriter, r *http.Request) {
+	if !h.deleteTask(w, r) {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	utils.Encode(w, map[string]string{"message": "Task deleted successfully"})
+}
+
+// deleteTask removes the task named in the URL, writing the error response
+// itself when that fails.
+func (h *TaskHandler) deleteTask(w http.ResponseWriter, r *http.Request) bool {
 	vars := mux.Vars(r)
 	idStr := vars["id"]
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
 		log.Printf("Invalid ID for deletion: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
-		utils.Encode(w, map[string]string{"message": "Invalid task ID"})
-		return
+		apierror.Write(w, r, apierror.BadRequest("Invalid task ID"))
+		return false
+	}
+
+	existing, role, ok := h.access.loadTask(w, r, id)
+	if !ok {
+		return false
+	}
+
+	if !checkIfMatch(w, r, existing) {
+		return false
 	}
 
-	err = h.taskRepo.DeleteTask(id)
+	claims, _ := middleware.GetClaims(r)
+	if !rbac.CanDeleteTask(role, claims.UserID, existing) {
+		apierror.Write(w, r, apierror.Forbidden("Not allowed to delete this task"))
+		return false
+	}
+
+	err = h.tasks(r).DeleteTask(id, existing.Version)
 	if err != nil {
 		log.Printf("Failed to delete task: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		utils.Encode(w, map[string]string{"message": "Failed to delete task"})
+		apierror.Write(w, r, apierror.From(err, "Failed to delete task"))
+		return false
+	}
+	return true
+}
+
+func (h *TaskHandler) ListTasks(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseListOptions(r)
+	if err != nil {
+		log.Printf("Invalid list parameters: %v", err)
+		apierror.Write(w, r, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	// Callers who cannot see every project get the same listing through the
+	// filtered query, narrowed to their projects
+	claims, _ := middleware.GetClaims(r)
+	var filter models.TaskFilter
+	if err := h.access.restrict(claims, &filter); err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to list tasks"))
+		return
+	}
+	var page models.TaskPage
+	if filter.RestrictProjects {
+		page, err = h.tasks(r).SearchAndFilterTasks(filter, opts)
+	} else {
+		page, err = h.tasks(r).ListTasks(opts)
+	}
+	if err != nil {
+		log.Printf("Failed to list tasks: %v", err)
+		apierror.Write(w, r, apierror.From(err, "Failed to list tasks"))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	utils.Encode(w, map[string]string{"message": "Task deleted successfully"})
+	utils.Encode(w, page)
 }
 
-func (h *TaskHandler) ListTasks(w http.ResponseWriter, r *http.Request) {
-	tasks, err := h.taskRepo.ListTasks(r)
+// SearchTasks filters tasks by the query parameters q, title, status,
+// priority, due_before, due_after, assigned_to, created_by and project_id,
+// paginated like ListTasks. With mode=fulltext, q is a full-text query instead of a
+// substring and results are ranked by relevance.
+func (h *TaskHandler) SearchTasks(w http.ResponseWriter, r *http.Request) {
+	filter, errs := parseTaskFilter(r)
+	ruleErrs, err := h.validator.Struct(filter)
 	if err != nil {
-		log.Printf("Failed to list tasks: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		utils.Encode(w, map[string]string{"message": "Failed to list tasks"})
+		apierror.Write(w, r, apierror.From(err, "Failed to validate search parameters"))
+		return
+	}
+	errs.Merge(ruleErrs)
+	if len(errs) > 0 {
+		apierror.Write(w, r, errs)
 		return
 	}
 
-	if len(tasks) == 0 {
-		log.Printf("No tasks found")
-		w.WriteHeader(http.StatusNotFound)
-		utils.Encode(w, map[string]string{"message": "No tasks found"})
+	claims, _ := middleware.GetClaims(r)
+	if err := h.access.restrict(claims, &filter); err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to search tasks"))
+		return
+	}
+
+	opts, err := parseListOptions(r)
+	if err != nil {
+		log.Printf("Invalid list parameters: %v", err)
+		apierror.Write(w, r, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	switch mode := r.URL.Query().Get("mode"); mode {
+	case "", "filter":
+	case "fulltext":
+		h.fullTextSearch(w, r, filter, opts)
+		return
+	default:
+		apierror.Write(w, r, apierror.BadRequest(fmt.Sprintf("unknown search mode %q", mode)))
+		return
+	}
+
+	page, err := h.tasks(r).SearchAndFilterTasks(filter, opts)
+	if err != nil {
+		log.Printf("Failed to search tasks: %v", err)
+		apierror.Write(w, r, apierror.From(err, "Failed to search tasks"))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	utils.Encode(w, page)
+}
+
+func (h *TaskHandler) fullTextSearch(w http.ResponseWriter, r *http.Request, filter models.TaskFilter, opts models.ListOptions) {
+	text := filter.Query
+	if text == "" {
+		apierror.Write(w, r, apierror.BadRequest("q is required for full-text search"))
+		return
+	}
+	filter.Query = ""
+
+	page, err := h.tasks(r).FullTextSearchTasks(text, filter, opts)
+	if err != nil {
+		log.Printf("Failed to run full-text search: %v", err)
+		apierror.Write(w, r, apierror.From(err, "Failed to search tasks"))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	utils.Encode(w, tasks)
+	utils.Encode(w, page)
 }
+
 func (h *TaskHandler) GetDashboard(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userIDStr := vars["userID"]
 	if userIDStr == "" {
 		log.Println("Missing userID in path parameters")
-		w.WriteHeader(http.StatusBadRequest)
-		utils.Encode(w, map[string]string{"message": "Missing userID in path parameter"})
+		apierror.Write(w, r, apierror.BadRequest("Missing userID in path parameter"))
 		return
 	}
 
 	userID, err := strconv.Atoi(userIDStr)
 	if err != nil {
 		log.Printf("Invalid userID: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
-		utils.Encode(w, map[string]string{"message": "Invalid userID"})
+		apierror.Write(w, r, apierror.BadRequest("Invalid userID"))
 		return
 	}
 
-	dashboard, err := h.taskRepo.GetUserDashboard(userID)
+	// Another user's dashboard needs a role that may view it, globally or on
+	// some project, and then only shows the tasks of the projects where the
+	// caller holds such a role. Tasks in projects the caller does not belong
+	// to are left out of every dashboard.
+	claims, _ := middleware.GetClaims(r)
+	self := claims.UserID == userID
+	if !self {
+		allowed, err := h.access.canAnywhere(claims, rbac.ActionDashboardViewAny)
+		if err != nil {
+			apierror.Write(w, r, apierror.From(err, "Failed to check project membership"))
+			return
+		}
+		if !allowed {
+			apierror.Write(w, r, apierror.Forbidden("Not allowed to view this dashboard"))
+			return
+		}
+		// Users of other organizations do not exist for the caller
+		if _, err := h.userRepo.ForOrg(claims.OrgID).GetUserByID(userID); err != nil {
+			log.Printf("User not found: %v", err)
+			apierror.Write(w, r, apierror.From(err, "Failed to get user"))
+			return
+		}
+	}
+
+	dashboard, err := h.tasks(r).GetUserDashboard(userID, middleware.CallerLocation(r))
 	if err != nil {
 		log.Printf("Failed to get dashboard data: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		utils.Encode(w, map[string]string{"message": "Failed to get dashboard data"})
+		apierror.Write(w, r, apierror.From(err, "Failed to get dashboard data"))
+		return
+	}
+
+	roles := h.access.roles(claims)
+	var roleErr error
+	dashboard = dashboard.Filter(func(task models.Task) bool {
+		role, err := roles(task.ProjectID)
+		if err != nil {
+			roleErr = err
+			return false
+		}
+		return role != "" && (self || rbac.Can(role, rbac.ActionDashboardViewAny))
+	})
+	if roleErr != nil {
+		apierror.Write(w, r, apierror.From(roleErr, "Failed to check project membership"))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 	utils.Encode(w, dashboard)
 }
+
+// GetProjectDashboard serves the dashboard of every task in {projectID}.
+func (h *TaskHandler) GetProjectDashboard(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := routeProjectID(r)
+	if !ok {
+		apierror.Write(w, r, apierror.BadRequest("Invalid project ID"))
+		return
+	}
+
+	dashboard, err := h.tasks(r).GetProjectDashboard(projectID, middleware.CallerLocation(r))
+	if err != nil {
+		log.Printf("Failed to get project dashboard: %v", err)
+		apierror.Write(w, r, apierror.From(err, "Failed to get dashboard data"))
+		return
+	}
+
+	utils.Encode(w, dashboard)
+}
+
+// canAssign reports whether the caller, acting with role, may set assignedTo
+// on a task. Anyone may leave a task unassigned or assign it to themselves.
+func canAssign(userID int, role string, assignedTo int) bool {
+	if assignedTo == 0 || assignedTo == userID {
+		return true
+	}
+	return rbac.Can(role, rbac.ActionTaskAssign)
+}
+
+// parseListOptions reads the limit, after, sort and order query parameters.
+func parseListOptions(r *http.Request) (models.ListOptions, error) {
+	params := r.URL.Query()
+	opts := models.ListOptions{
+		Limit: models.DefaultPageSize,
+		After: params.Get("after"),
+		Sort:  "created_at",
+		Order: models.SortAsc,
+	}
+
+	if limitStr := params.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > models.MaxPageSize {
+			return opts, fmt.Errorf("limit must be between 1 and %d", models.MaxPageSize)
+		}
+		opts.Limit = limit
+	}
+
+	if sortField := params.Get("sort"); sortField != "" {
+		if !models.TaskSortFields[sortField] {
+			return opts, fmt.Errorf("cannot sort by %q", sortField)
+		}
+		opts.Sort = sortField
+	}
+
+	if order := strings.ToLower(params.Get("order")); order != "" {
+		if order != models.SortAsc && order != models.SortDesc {
+			return opts, fmt.Errorf("order must be %q or %q", models.SortAsc, models.SortDesc)
+		}
+		opts.Order = order
+	}
+
+	return opts, nil
+}
+
+// parseTaskFilter reads TaskFilter fields from query parameters. status and
+// priority accept comma-separated lists. Values that cannot be parsed are
+// reported in the returned Errors; the rest is left to the validate rules.
+func parseTaskFilter(r *http.Request) (models.TaskFilter, validation.Errors) {
+	params := r.URL.Query()
+	errs := validation.Errors{}
+	filter := models.TaskFilter{
+		Query:     strings.TrimSpace(params.Get("q")),
+		Title:     strings.TrimSpace(params.Get("title")),
+		DueBefore: params.Get("due_before"),
+		DueAfter:  params.Get("due_after"),
+		Location:  middleware.CallerLocation(r),
+	}
+
+	for _, value := range splitList(params["status"]) {
+		filter.Status = append(filter.Status, models.ParseTaskStatus(value))
+	}
+	for _, value := range splitList(params["priority"]) {
+		filter.Priority = append(filter.Priority, models.ParseTaskPriority(value))
+	}
+
+	for name, dest := range map[string]*int{"assigned_to": &filter.AssignedTo, "created_by": &filter.CreatedBy} {
+		value := params.Get(name)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			errs.Add(name, "must be a positive user ID")
+			continue
+		}
+		*dest = id
+	}
+
+	if value := params.Get("project_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			errs.Add("project_id", "must be a positive project ID")
+		}
+		filter.ProjectID = id
+	}
+	// Project-scoped routes fix the project regardless of the query
+	if projectID, ok := routeProjectID(r); ok {
+		filter.ProjectID = projectID
+	}
+
+	return filter, errs
+}
+
+// routeProjectID returns the {projectID} route variable of project-scoped
+// routes.
+func routeProjectID(r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["projectID"])
+	return id, err == nil
+}
+
+// splitList flattens repeated and comma-separated query values.
+func splitList(values []string) []string {
+	var out []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+	}
+	return out
+}
+
+// checkTransition enforces the status workflow and writes the error response
+// when the move is not allowed.
+func (h *TaskHandler) checkTransition(w http.ResponseWriter, r *http.Request, from, to models.TaskStatus, role string) bool {
+	if err := h.workflow.Check(from, to, role); err != nil {
+		apierror.Write(w, r, err)
+		return false
+	}
+	return true
+}
+
+// taskETag is the strong entity tag of a task's representation. It hashes
+// the encoded task rather than quoting Version, so that the fields filled in
+// by reads (comment_count, progress, blocked, blocked_by) change it too;
+// Version only guards concurrent writes in storage.
+func taskETag(task models.Task) string {
+	encoded, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Sprintf(`"%d-%d"`, task.ID, task.Version)
+	}
+	sum := sha256.Sum256(encoded)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether etag is listed in an If-Match or If-None-Match
+// header value. Weak comparison ignores the W/ prefix, as If-None-Match
+// requires; If-Match uses strong comparison.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch enforces an If-Match header against the current task and
+// responds 412 when the client's copy is stale.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, task models.Task) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" || etagMatches(header, taskETag(task), false) {
+		return true
+	}
+	w.Header().Set("ETag", taskETag(task))
+	apierror.Write(w, r, models.ErrVersionConflict)
+	return false
+}
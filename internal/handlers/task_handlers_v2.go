@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/naveeshkumar24/internal/apierror"
+	"github.com/naveeshkumar24/internal/middleware"
+	"github.com/naveeshkumar24/internal/models"
+	"github.com/naveeshkumar24/internal/rbac"
+	"github.com/naveeshkumar24/pkg/utils"
+)
+
+// TaskPathV2 is the URL of a task in the v2 API, used for Location headers.
+const TaskPathV2 = "/api/v2/tasks/%d"
+
+// CreateTaskV2 handles POST /api/v2/tasks and responds 201 with the created
+// task and its Location.
+func (h *TaskHandler) CreateTaskV2(w http.ResponseWriter, r *http.Request) {
+	task, ok := h.createTask(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf(TaskPathV2, task.ID))
+	w.Header().Set("ETag", taskETag(task))
+	w.WriteHeader(http.StatusCreated)
+	utils.Encode(w, task)
+}
+
+// ReplaceTaskV2 handles PUT /api/v2/tasks/{id}. The ID comes from the URL; a
+// body ID, if present, must agree with it.
+func (h *TaskHandler) ReplaceTaskV2(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("Invalid ID format: %v", err)
+		apierror.Write(w, r, apierror.BadRequest("Invalid task ID"))
+		return
+	}
+
+	var task models.Task
+	if err := utils.Decode(r, &task); err != nil {
+		log.Printf("Failed to decode task update: %v", err)
+		apierror.Write(w, r, err)
+		return
+	}
+	if task.ID != 0 && task.ID != id {
+		apierror.Write(w, r, apierror.BadRequest("Task ID in the body does not match the URL"))
+		return
+	}
+	task.ID = id
+
+	updated, ok := h.replaceTask(w, r, task)
+	if !ok {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	utils.Encode(w, updated)
+}
+
+// DeleteTaskV2 handles DELETE /api/v2/tasks/{id} and responds 204.
+func (h *TaskHandler) DeleteTaskV2(w http.ResponseWriter, r *http.Request) {
+	if !h.deleteTask(w, r) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListSubtasksV2 handles GET /api/v2/tasks/{id}/subtasks and responds with
+// the direct subtasks of the task under "items", like the other lists.
+func (h *TaskHandler) ListSubtasksV2(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("Invalid ID format: %v", err)
+		apierror.Write(w, r, apierror.BadRequest("Invalid task ID"))
+		return
+	}
+	if _, _, ok := h.access.loadTask(w, r, id); !ok {
+		return
+	}
+
+	// Subtasks share the project of their parent, so the caller may see them all
+	subtasks, err := h.tasks(r).ListSubtasks(id)
+	if err != nil {
+		log.Printf("Failed to list subtasks: %v", err)
+		apierror.Write(w, r, apierror.From(err, "Failed to list subtasks"))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	utils.Encode(w, map[string]interface{}{"items": subtasks})
+}
+
+// GetSubtreeV2 handles GET /api/v2/tasks/{id}/subtree and responds with the
+// task and its subtasks nested to any depth.
+func (h *TaskHandler) GetSubtreeV2(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("Invalid ID format: %v", err)
+		apierror.Write(w, r, apierror.BadRequest("Invalid task ID"))
+		return
+	}
+	root, _, ok := h.access.loadTask(w, r, id)
+	if !ok {
+		return
+	}
+
+	descendants, err := h.tasks(r).GetSubtree(id)
+	if err != nil {
+		log.Printf("Failed to fetch task subtree: %v", err)
+		apierror.Write(w, r, apierror.From(err, "Failed to fetch subtasks"))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	utils.Encode(w, models.BuildTaskTree(root, descendants))
+}
+
+// ListDependenciesV2 handles GET /api/v2/tasks/{id}/dependencies and
+// responds with the tasks the task waits for under "items".
+func (h *TaskHandler) ListDependenciesV2(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("Invalid ID format: %v", err)
+		apierror.Write(w, r, apierror.BadRequest("Invalid task ID"))
+		return
+	}
+	if _, _, ok := h.access.loadTask(w, r, id); !ok {
+		return
+	}
+
+	dependencies, err := h.tasks(r).ListDependencies(id)
+	if err != nil {
+		log.Printf("Failed to list task dependencies: %v", err)
+		apierror.Write(w, r, apierror.From(err, "Failed to list dependencies"))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	utils.Encode(w, map[string]interface{}{"items": dependencies})
+}
+
+// AddDependencyV2 handles PUT /api/v2/tasks/{id}/dependencies/{dependsOnID}
+// and responds with the task, which from now on waits for the other one.
+func (h *TaskHandler) AddDependencyV2(w http.ResponseWriter, r *http.Request) {
+	id, dependsOnID, ok := h.dependencyRoute(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.tasks(r).AddDependency(id, dependsOnID); err != nil {
+		log.Printf("Failed to add task dependency: %v", err)
+		apierror.Write(w, r, apierror.From(err, "Failed to add dependency"))
+		return
+	}
+
+	task, err := h.tasks(r).GetTaskByID(id)
+	if err != nil {
+		log.Printf("Failed to reload task: %v", err)
+		apierror.Write(w, r, apierror.From(err, "Failed to load updated task"))
+		return
+	}
+
+	w.Header().Set("ETag", taskETag(task))
+	w.WriteHeader(http.StatusOK)
+	utils.Encode(w, task)
+}
+
+// RemoveDependencyV2 handles DELETE
+// /api/v2/tasks/{id}/dependencies/{dependsOnID} and responds 204.
+func (h *TaskHandler) RemoveDependencyV2(w http.ResponseWriter, r *http.Request) {
+	id, dependsOnID, ok := h.dependencyRoute(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.tasks(r).RemoveDependency(id, dependsOnID); err != nil {
+		log.Printf("Failed to remove task dependency: %v", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Write(w, r, apierror.NotFound("Dependency not found"))
+			return
+		}
+		apierror.Write(w, r, apierror.From(err, "Failed to remove dependency"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// dependencyRoute reads {id} and {dependsOnID} and checks that the caller
+// may change the dependencies of the task, which takes full update access,
+// writing the error response itself when that fails.
+func (h *TaskHandler) dependencyRoute(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid task ID"))
+		return 0, 0, false
+	}
+	dependsOnID, err := strconv.Atoi(vars["dependsOnID"])
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid dependency task ID"))
+		return 0, 0, false
+	}
+
+	task, role, ok := h.access.loadTask(w, r, id)
+	if !ok {
+		return 0, 0, false
+	}
+	claims, _ := middleware.GetClaims(r)
+	if rbac.TaskUpdateAccess(role, claims.UserID, task) != rbac.TaskAccessFull {
+		apierror.Write(w, r, apierror.Forbidden("Not allowed to change the dependencies of this task"))
+		return 0, 0, false
+	}
+	return id, dependsOnID, true
+}
+
+// GetCriticalPath handles GET /api/v2/projects/{projectID}/critical-path
+// and responds with the longest chain of open tasks of the project that wait
+// for one another, starting with the task to finish first, as {"items": [...]}.
+func (h *TaskHandler) GetCriticalPath(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := routeProjectID(r)
+	if !ok {
+		apierror.Write(w, r, apierror.BadRequest("Invalid project ID"))
+		return
+	}
+
+	path, err := h.tasks(r).GetCriticalPath(projectID)
+	if err != nil {
+		log.Printf("Failed to get critical path: %v", err)
+		apierror.Write(w, r, apierror.From(err, "Failed to get critical path"))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	utils.Encode(w, map[string]interface{}{"items": path})
+}
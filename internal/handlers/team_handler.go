@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/naveeshkumar24/internal/apierror"
+	"github.com/naveeshkumar24/internal/middleware"
+	"github.com/naveeshkumar24/internal/models"
+	"github.com/naveeshkumar24/internal/validation"
+	"github.com/naveeshkumar24/pkg/utils"
+)
+
+// TeamPathV2 is the URL of a team in the v2 API, used for Location headers.
+const TeamPathV2 = "/api/v2/teams/%d"
+
+type TeamHandler struct {
+	teamRepo  models.TeamInterface
+	validator *validation.Validator
+}
+
+func NewTeamHandler(teamRepo models.TeamInterface, validator *validation.Validator) *TeamHandler {
+	return &TeamHandler{
+		teamRepo:  teamRepo,
+		validator: validator,
+	}
+}
+
+// teams returns the team storage of the caller's organization.
+func (h *TeamHandler) teams(r *http.Request) models.TeamInterface {
+	return h.teamRepo.ForOrg(middleware.OrgID(r))
+}
+
+func (h *TeamHandler) ListTeams(w http.ResponseWriter, r *http.Request) {
+	teams, err := h.teams(r).ListTeams()
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to list teams"))
+		return
+	}
+
+	utils.Encode(w, map[string]interface{}{"items": teams})
+}
+
+// CreateTeam handles POST /api/v2/teams.
+func (h *TeamHandler) CreateTeam(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetClaims(r)
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("Unauthorized"))
+		return
+	}
+
+	var req struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err := utils.Decode(r, &req); err != nil {
+		log.Printf("Failed to decode team: %v", err)
+		apierror.Write(w, r, err)
+		return
+	}
+
+	team := models.Team{
+		Name:        req.Name,
+		Description: req.Description,
+		CreatedBy:   claims.UserID,
+	}
+	if err := h.validator.ForOrg(middleware.OrgID(r)).Validate(team); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	created, err := h.teams(r).CreateTeam(team)
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to create team"))
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf(TeamPathV2, created.ID))
+	w.WriteHeader(http.StatusCreated)
+	utils.Encode(w, created)
+}
+
+func (h *TeamHandler) GetTeam(w http.ResponseWriter, r *http.Request) {
+	id, ok := routeTeamID(w, r)
+	if !ok {
+		return
+	}
+
+	team, err := h.teams(r).GetTeam(id)
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to load team"))
+		return
+	}
+
+	utils.Encode(w, team)
+}
+
+// DeleteTeam handles DELETE /api/v2/teams/{teamID}. Its members lose the
+// project roles they held through the team.
+func (h *TeamHandler) DeleteTeam(w http.ResponseWriter, r *http.Request) {
+	id, ok := routeTeamID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.teams(r).DeleteTeam(id); err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to delete team"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *TeamHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	id, ok := routeTeamID(w, r)
+	if !ok {
+		return
+	}
+	if _, err := h.teams(r).GetTeam(id); err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to load team"))
+		return
+	}
+
+	members, err := h.teams(r).ListTeamMembers(id)
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to list team members"))
+		return
+	}
+
+	utils.Encode(w, map[string]interface{}{"items": members})
+}
+
+// AddMember handles PUT /api/v2/teams/{teamID}/members/{userID}; adding an
+// existing member succeeds without change.
+func (h *TeamHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	teamID, ok := routeTeamID(w, r)
+	if !ok {
+		return
+	}
+	userID, err := strconv.Atoi(mux.Vars(r)["userID"])
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid user ID"))
+		return
+	}
+	if _, err := h.teams(r).GetTeam(teamID); err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to load team"))
+		return
+	}
+
+	if err := h.teams(r).AddTeamMember(teamID, userID); err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to add team member"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveMember handles DELETE /api/v2/teams/{teamID}/members/{userID}.
+func (h *TeamHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	teamID, ok := routeTeamID(w, r)
+	if !ok {
+		return
+	}
+	userID, err := strconv.Atoi(mux.Vars(r)["userID"])
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid user ID"))
+		return
+	}
+
+	if err := h.teams(r).RemoveTeamMember(teamID, userID); err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to remove team member"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// routeTeamID reads the {teamID} route variable, writing a 400 response
+// when it is not a number.
+func routeTeamID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["teamID"])
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid team ID"))
+		return 0, false
+	}
+	return id, true
+}
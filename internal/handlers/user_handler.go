@@ -1,40 +1,204 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/naveeshkumar24/internal/apierror"
+	"github.com/naveeshkumar24/internal/middleware"
 	"github.com/naveeshkumar24/internal/models"
+	"github.com/naveeshkumar24/internal/rbac"
+	"github.com/naveeshkumar24/internal/validation"
 	"github.com/naveeshkumar24/pkg/utils"
-	"github.com/naveeshkumar24/repository"
 )
 
 type UserHandler struct {
-	userRepo *repository.UserRepository
+	userRepo   models.UserInterface
+	orgRepo    models.OrganizationInterface
+	memberRepo models.MembershipInterface
+	tokenRepo  models.TokenInterface
+	validator  *validation.Validator
+
+	// orgSignup lets anyone found an organization by registering
+	orgSignup bool
 }
 
-func NewUserHandler(userRepo models.UserInterface) *UserHandler {
+func NewUserHandler(userRepo models.UserInterface, orgRepo models.OrganizationInterface, memberRepo models.MembershipInterface, tokenRepo models.TokenInterface, validator *validation.Validator, orgSignup bool) *UserHandler {
 	return &UserHandler{
-		userRepo: userRepo.(*repository.UserRepository),
+		userRepo:   userRepo,
+		orgRepo:    orgRepo,
+		memberRepo: memberRepo,
+		tokenRepo:  tokenRepo,
+		validator:  validator,
+		orgSignup:  orgSignup,
 	}
 }
 
+// users returns the user storage of the caller's organization.
+func (h *UserHandler) users(r *http.Request) models.UserInterface {
+	return h.userRepo.ForOrg(middleware.OrgID(r))
+}
+
+// UserPathV2 is the location of a user under the v2 API.
+const UserPathV2 = "/api/v2/users/%d"
+
+// RegisterUser signs up a user into the default organization. Presenting
+// the token of an invitation instead joins the organization of the invited
+// project and accepts the invitation. When organization sign-up is enabled,
+// naming an organization instead creates it with the user as its admin; that
+// is the only way to register with a role other than user. Users then have
+// to name an organization or present an invitation, so that strangers do
+// not end up in the default organization.
 func (h *UserHandler) RegisterUser(w http.ResponseWriter, r *http.Request) {
-	var user models.User
-	if err := utils.Decode(r, &user); err != nil {
-		log.Printf("Register decode error: %v", err)
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	_, org, ok := h.registerUser(w, r)
+	if !ok {
 		return
 	}
 
-	if err := h.userRepo.Register(user); err != nil {
-		http.Error(w, "Registration failed", http.StatusInternalServerError)
+	if org == nil {
+		utils.Encode(w, map[string]string{"message": "Registration successful"})
 		return
 	}
+	w.WriteHeader(http.StatusCreated)
+	utils.Encode(w, map[string]interface{}{
+		"message":      "Registration successful",
+		"organization": org,
+	})
+}
+
+// RegisterUserV2 handles POST /api/v2/users and answers with the created
+// user, whichever organization it joined.
+func (h *UserHandler) RegisterUserV2(w http.ResponseWriter, r *http.Request) {
+	user, _, ok := h.registerUser(w, r)
+	if !ok {
+		return
+	}
+
+	user.Password = ""
+	w.Header().Set("Location", fmt.Sprintf(UserPathV2, user.ID))
+	w.WriteHeader(http.StatusCreated)
+	utils.Encode(w, user)
+}
+
+// registerUser decodes and stores a new user, writing the error response
+// itself when that fails. The organization is only set when the user
+// founded one.
+func (h *UserHandler) registerUser(w http.ResponseWriter, r *http.Request) (models.User, *models.Organization, bool) {
+	var req struct {
+		models.User
+		Organization string `json:"organization"`
+		Invitation   string `json:"invitation"`
+	}
+	if err := utils.Decode(r, &req); err != nil {
+		log.Printf("Register decode error: %v", err)
+		apierror.Write(w, r, err)
+		return models.User{}, nil, false
+	}
+	user := req.User
+
+	if req.Organization != "" && !h.orgSignup {
+		apierror.Write(w, r, apierror.Forbidden("Creating organizations by sign-up is disabled"))
+		return models.User{}, nil, false
+	}
+
+	// Self-registration always creates a plain user; roles are granted by an
+	// admin, except for the founder of a new organization
+	user.Role = models.RoleUser
+	if req.Organization != "" {
+		user.Role = models.RoleAdmin
+	}
+
+	if user.Timezone == "" {
+		user.Timezone = "UTC"
+	}
+	errs, err := h.validator.Struct(user)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return models.User{}, nil, false
+	}
+	org := models.Organization{Name: req.Organization}
+	if req.Organization != "" {
+		orgErrs, err := h.validator.Struct(org)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return models.User{}, nil, false
+		}
+		if msg, ok := orgErrs["name"]; ok {
+			errs.Add("organization", msg)
+		}
+		if req.Invitation != "" {
+			errs.Add("invitation", "cannot be combined with organization")
+		}
+	} else if h.orgSignup && req.Invitation == "" {
+		errs.Add("organization", "is required unless signing up with an invitation")
+	}
+	if err := errs.Err(); err != nil {
+		apierror.Write(w, r, err)
+		return models.User{}, nil, false
+	}
+
+	if req.Invitation != "" {
+		created, ok := h.registerInvited(w, r, user, req.Invitation)
+		return created, nil, ok
+	}
+	if req.Organization == "" {
+		created, err := h.userRepo.ForOrg(models.DefaultOrgID).Register(user)
+		if err != nil {
+			apierror.Write(w, r, apierror.From(err, "Registration failed"))
+			return models.User{}, nil, false
+		}
+		return created, nil, true
+	}
+
+	org, created, err := h.orgRepo.CreateOrganization(org, user)
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Registration failed"))
+		return models.User{}, nil, false
+	}
+	return created, &org, true
+}
+
+// registerInvited registers user into the organization of the invitation
+// with token, which must still be pending and sent to the user's email, and
+// accepts it for them.
+func (h *UserHandler) registerInvited(w http.ResponseWriter, r *http.Request, user models.User, token string) (models.User, bool) {
+	invitation, err := h.memberRepo.GetInvitationByToken(utils.HashInvitationToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Write(w, r, apierror.NotFound("Invitation not found"))
+			return models.User{}, false
+		}
+		apierror.Write(w, r, apierror.From(err, "Failed to load invitation"))
+		return models.User{}, false
+	}
+	if !invitation.Pending(time.Now()) {
+		apierror.Write(w, r, apierror.From(models.ErrInvitationInvalid, "Invitation is no longer valid"))
+		return models.User{}, false
+	}
+	if !strings.EqualFold(invitation.Email, user.Email) {
+		apierror.Write(w, r, apierror.Forbidden("Invitation was sent to a different email address"))
+		return models.User{}, false
+	}
 
-	utils.Encode(w, map[string]string{"message": "Registration successful"})
+	created, err := h.userRepo.ForOrg(invitation.OrgID).Register(user)
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Registration failed"))
+		return models.User{}, false
+	}
+
+	// The account exists either way; an invitation accepted or revoked since
+	// it was read leaves the user in the organization without the project role
+	if err := h.memberRepo.ForOrg(invitation.OrgID).AcceptInvitation(invitation.ID, created.ID); err != nil {
+		log.Printf("Failed to accept invitation %d for new user %d: %v", invitation.ID, created.ID, err)
+	}
+	return created, true
 }
 
 func (h *UserHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
@@ -44,21 +208,33 @@ func (h *UserHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
 	}
 	if err := utils.Decode(r, &creds); err != nil {
 		log.Printf("Login decode error: %v", err)
-		http.Error(w, "Invalid credentials", http.StatusBadRequest)
+		apierror.Write(w, r, err)
 		return
 	}
 
 	// Validate user credentials and get the user object
 	user, err := h.userRepo.Login(creds.Email, creds.Password)
 	if err != nil {
-		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		apierror.Write(w, r, apierror.Unauthorized("Invalid email or password"))
 		return
 	}
 
 	// Generate JWT token
-	token, err := utils.GenerateJWT(user.ID, user.Email, user.Role)
+	token, err := utils.GenerateJWT(user.ID, user.OrgID, user.Email, user.Role, user.Timezone)
 	if err != nil {
-		http.Error(w, "Could not generate token", http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.From(err, "Could not generate token"))
+		return
+	}
+
+	// Start a new refresh token family for this login
+	familyID, err := utils.NewTokenFamily()
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Could not generate token"))
+		return
+	}
+	refreshToken, err := h.issueRefreshToken(user.ID, familyID)
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Could not generate token"))
 		return
 	}
 
@@ -66,8 +242,10 @@ func (h *UserHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
 
 	// 2) wrap into a single response object
 	resp := map[string]interface{}{
-		"user":  user,
-		"token": token,
+		"user":          user,
+		"token":         token,
+		"refresh_token": refreshToken,
+		"expires_in":    int(utils.AccessTokenTTL().Seconds()),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -76,20 +254,234 @@ func (h *UserHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
 	utils.Encode(w, resp)
 }
 
+// RefreshToken exchanges a valid refresh token for a new access token and a
+// new refresh token. The presented token is revoked; presenting it again later
+// is treated as theft and revokes every token in its family.
+func (h *UserHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := utils.Decode(r, &req); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if req.RefreshToken == "" {
+		apierror.Write(w, r, validation.Errors{"refresh_token": "is required"})
+		return
+	}
+
+	stored, err := h.tokenRepo.GetRefreshToken(utils.HashRefreshToken(req.RefreshToken))
+	if err != nil {
+		apierror.Write(w, r, apierror.Unauthorized("Invalid refresh token"))
+		return
+	}
+
+	if stored.RevokedAt != nil {
+		log.Printf("Refresh token reuse detected for user %d, revoking family %s", stored.UserID, stored.FamilyID)
+		h.tokenRepo.RevokeTokenFamily(stored.FamilyID)
+		apierror.Write(w, r, apierror.Unauthorized("Invalid refresh token"))
+		return
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		apierror.Write(w, r, apierror.Unauthorized("Refresh token expired"))
+		return
+	}
+
+	// Reload the user so that role changes take effect on refresh
+	user, err := h.userRepo.GetUserByID(stored.UserID)
+	if err != nil {
+		apierror.Write(w, r, apierror.Unauthorized("Invalid refresh token"))
+		return
+	}
+
+	newToken, newHash, err := utils.GenerateRefreshToken()
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Could not generate token"))
+		return
+	}
+
+	err = h.tokenRepo.RotateRefreshToken(stored.ID, models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: newHash,
+		FamilyID:  stored.FamilyID,
+		ExpiresAt: time.Now().Add(utils.RefreshTokenTTL()),
+	})
+	if errors.Is(err, models.ErrTokenReused) {
+		log.Printf("Concurrent refresh token reuse for user %d, revoking family %s", stored.UserID, stored.FamilyID)
+		h.tokenRepo.RevokeTokenFamily(stored.FamilyID)
+		apierror.Write(w, r, apierror.Unauthorized("Invalid refresh token"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Could not refresh token"))
+		return
+	}
+
+	accessToken, err := utils.GenerateJWT(user.ID, user.OrgID, user.Email, user.Role, user.Timezone)
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "Could not generate token"))
+		return
+	}
+
+	utils.Encode(w, map[string]interface{}{
+		"token":         accessToken,
+		"refresh_token": newToken,
+		"expires_in":    int(utils.AccessTokenTTL().Seconds()),
+	})
+}
+
+// Logout ends the session the given refresh token belongs to.
+func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := utils.Decode(r, &req); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if req.RefreshToken == "" {
+		apierror.Write(w, r, validation.Errors{"refresh_token": "is required"})
+		return
+	}
+
+	stored, err := h.tokenRepo.GetRefreshToken(utils.HashRefreshToken(req.RefreshToken))
+	if err != nil {
+		// Unknown tokens are already logged out
+		utils.Encode(w, map[string]string{"message": "Logged out"})
+		return
+	}
+
+	if err := h.tokenRepo.RevokeTokenFamily(stored.FamilyID); err != nil {
+		apierror.Write(w, r, apierror.From(err, "Logout failed"))
+		return
+	}
+
+	utils.Encode(w, map[string]string{"message": "Logged out"})
+}
+
+// LogoutAll revokes every refresh token of the authenticated user, ending
+// their sessions on all devices once the current access tokens expire.
+func (h *UserHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetClaims(r)
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("Unauthorized"))
+		return
+	}
+
+	if err := h.tokenRepo.RevokeAllUserTokens(claims.UserID); err != nil {
+		apierror.Write(w, r, apierror.From(err, "Logout failed"))
+		return
+	}
+
+	utils.Encode(w, map[string]string{"message": "Logged out from all devices"})
+}
+
+func (h *UserHandler) issueRefreshToken(userID int, familyID string) (string, error) {
+	token, hash, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	err = h.tokenRepo.CreateRefreshToken(models.RefreshToken{
+		UserID:    userID,
+		TokenHash: hash,
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(utils.RefreshTokenTTL()),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
 func (h *UserHandler) GetUserByID(w http.ResponseWriter, r *http.Request) {
 	idStr := mux.Vars(r)["id"]
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.BadRequest("Invalid user ID"))
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r)
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("Unauthorized"))
+		return
+	}
+	if claims.UserID != id && !rbac.Can(claims.Role, rbac.ActionUserViewAny) {
+		apierror.Write(w, r, apierror.Forbidden("Forbidden"))
 		return
 	}
 
-	user, err := h.userRepo.GetUserByID(id)
+	user, err := h.users(r).GetUserByID(id)
 	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+		apierror.Write(w, r, apierror.NotFound("User not found"))
 		return
 	}
 
 	user.Password = ""
 	utils.Encode(w, user)
 }
+
+// UpdateUserRole lets an admin change the role of another user.
+func (h *UserHandler) UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	idStr := mux.Vars(r)["id"]
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid user ID"))
+		return
+	}
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := utils.Decode(r, &req); err != nil {
+		log.Printf("Update role decode error: %v", err)
+		apierror.Write(w, r, err)
+		return
+	}
+	if !rbac.ValidRole(req.Role) {
+		apierror.Write(w, r, apierror.BadRequest("Invalid role"))
+		return
+	}
+
+	if err := h.users(r).UpdateRole(id, req.Role); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Write(w, r, apierror.NotFound("User not found"))
+			return
+		}
+		apierror.Write(w, r, apierror.From(err, "Failed to update role"))
+		return
+	}
+
+	utils.Encode(w, map[string]string{"message": "Role updated successfully"})
+}
+
+// UpdateTimezone sets the caller's time zone preference. It is used for
+// date filters and the dashboard once a new access token has been issued.
+func (h *UserHandler) UpdateTimezone(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetClaims(r)
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("Unauthorized"))
+		return
+	}
+
+	var req struct {
+		Timezone string `json:"timezone"`
+	}
+	if err := utils.Decode(r, &req); err != nil {
+		log.Printf("Update timezone decode error: %v", err)
+		apierror.Write(w, r, err)
+		return
+	}
+	if req.Timezone == "" || !validation.ValidTimezone(req.Timezone) {
+		apierror.Write(w, r, apierror.BadRequest("Invalid timezone"))
+		return
+	}
+
+	if err := h.users(r).UpdateTimezone(claims.UserID, req.Timezone); err != nil {
+		apierror.Write(w, r, apierror.From(err, "Failed to update timezone"))
+		return
+	}
+
+	utils.Encode(w, map[string]string{"message": "Timezone updated successfully"})
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/naveeshkumar24/internal/apierror"
+	"github.com/naveeshkumar24/pkg/utils"
+)
+
+type contextKey string
+
+const claimsKey contextKey = "claims"
+
+// AuthMiddleware rejects requests without a valid bearer token and stores the
+// caller's claims in the request context.
+func AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			log.Println("Missing Authorization header")
+			apierror.Write(w, r, apierror.Unauthorized("Missing authorization token"))
+			return
+		}
+
+		scheme, tokenString, found := strings.Cut(header, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(tokenString) == "" {
+			log.Println("Malformed Authorization header")
+			apierror.Write(w, r, apierror.Unauthorized("Invalid authorization header"))
+			return
+		}
+
+		claims, err := utils.ParseJWT(strings.TrimSpace(tokenString))
+		if err != nil {
+			log.Printf("Token validation failed: %v", err)
+			apierror.Write(w, r, apierror.Unauthorized("Invalid or expired token"))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), claimsKey, claims)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetClaims returns the authenticated caller stored by AuthMiddleware.
+func GetClaims(r *http.Request) (*utils.Claims, bool) {
+	claims, ok := r.Context().Value(claimsKey).(*utils.Claims)
+	return claims, ok
+}
+
+// OrgID returns the organization of the authenticated caller, or 0 outside
+// AuthMiddleware, which matches no tenant data.
+func OrgID(r *http.Request) int {
+	claims, ok := GetClaims(r)
+	if !ok {
+		return 0
+	}
+	return claims.OrgID
+}
+
+// CallerLocation returns the time zone of the authenticated caller, falling
+// back to UTC when none is set or it cannot be loaded.
+func CallerLocation(r *http.Request) *time.Location {
+	claims, ok := GetClaims(r)
+	if !ok || claims.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(claims.Timezone)
+	if err != nil {
+		log.Printf("Unknown timezone %q for user %d: %v", claims.Timezone, claims.UserID, err)
+		return time.UTC
+	}
+	return loc
+}
@@ -29,8 +29,9 @@ func CorsMiddleware(next http.Handler) http.Handler {
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, If-Match, If-None-Match, Range, If-Range")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag, Location, Content-Range, Content-Disposition")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 
 		// Handle preflight requests (OPTIONS)
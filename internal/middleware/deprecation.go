@@ -0,0 +1,13 @@
+package middleware
+
+import "net/http"
+
+// Deprecation marks responses of the v1 routes as deprecated and points
+// clients at the v2 API that replaces them.
+func Deprecation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", `</api/v2>; rel="successor-version"`)
+		next.ServeHTTP(w, r)
+	})
+}
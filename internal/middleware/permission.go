@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/naveeshkumar24/internal/apierror"
+	"github.com/naveeshkumar24/internal/rbac"
+)
+
+// RequirePermission only lets callers whose role grants action through. It
+// must run after AuthMiddleware.
+func RequirePermission(action rbac.Action) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := GetClaims(r)
+			if !ok {
+				apierror.Write(w, r, apierror.Unauthorized("Unauthorized"))
+				return
+			}
+
+			if !rbac.Can(claims.Role, action) {
+				log.Printf("User %d with role %q denied %s", claims.UserID, claims.Role, action)
+				apierror.Write(w, r, apierror.Forbidden("Forbidden"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Attachment is the metadata of a file attached to a task. The content lives
+// in blob storage under StorageKey, which is never exposed to clients.
+type Attachment struct {
+	ID          int       `json:"id"`
+	TaskID      int       `json:"task_id"`
+	Filename    string    `json:"filename"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type"`
+	SHA256      string    `json:"sha256"`
+	StorageKey  string    `json:"-"`
+	UploadedBy  int       `json:"uploaded_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// AttachmentInterface stores attachment metadata. ListAttachments returns
+// the attachments of a task oldest first.
+type AttachmentInterface interface {
+	ForOrg(orgID int) AttachmentInterface
+	CreateAttachment(attachment Attachment) (Attachment, error)
+	GetAttachment(id int) (Attachment, error)
+	ListAttachments(taskID int) ([]Attachment, error)
+	DeleteAttachment(id int) error
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Comment is a message on a task. Replies point at their parent comment;
+// top-level comments have ParentID 0. Mentions lists the usernames of
+// existing users referenced as @username in Body.
+type Comment struct {
+	ID         int       `json:"id"`
+	TaskID     int       `json:"task_id"`
+	ParentID   int       `json:"parent_id,omitempty"`
+	AuthorID   int       `json:"author_id"`
+	Body       string    `json:"body" validate:"required,max=10000"`
+	Mentions   []string  `json:"mentions"`
+	ReplyCount int       `json:"reply_count"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// CommentPage is one page of comments in posting order.
+type CommentPage struct {
+	Items      []Comment `json:"items"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+	Total      int       `json:"total"`
+}
+
+// CommentSort is the only ordering of comment listings: by ID, which follows
+// posting order.
+const CommentSort = "id"
+
+// mentionPattern matches @username where the @ does not follow a word
+// character, so that e-mail addresses are not taken for mentions.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9_.\-]+)`)
+
+// ParseMentions returns the distinct usernames mentioned in body, in order of
+// first appearance. Trailing dots are dropped so that a mention can end a
+// sentence.
+func ParseMentions(body string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		name := strings.TrimRight(match[1], ".")
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// CommentInterface stores comments. ListComments returns the replies to
+// parentID, or the top-level comments of the task when parentID is 0.
+type CommentInterface interface {
+	ForOrg(orgID int) CommentInterface
+	CreateComment(comment Comment) (Comment, error)
+	GetComment(id int) (Comment, error)
+	UpdateComment(id int, body string) (Comment, error)
+	DeleteComment(id int) error
+	ListComments(taskID, parentID int, opts ListOptions) (CommentPage, error)
+}
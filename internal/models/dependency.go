@@ -0,0 +1,71 @@
+package models
+
+import (
+	"errors"
+	"slices"
+)
+
+// Reasons a dependency is rejected, reported as a ConstraintError on
+// depends_on_id.
+var (
+	ErrDependencyCycle   = errors.New("would make the task wait for itself")
+	ErrDependencyProject = errors.New("must be a task of the same project")
+)
+
+// CriticalPath returns the longest chain of open tasks in which every task
+// is blocked by the one before it, given the tasks of a project with
+// BlockedBy filled in. Done tasks, and dependencies on them or on tasks that
+// are not listed, are ignored. Among chains of equal length the one ending
+// with the task listed first wins, and each step prefers the blocker with
+// the lowest ID.
+func CriticalPath(tasks []Task) []Task {
+	byID := make(map[int]Task, len(tasks))
+	for _, task := range tasks {
+		if task.Status != StatusDone {
+			byID[task.ID] = task
+		}
+	}
+
+	// length is the number of tasks in the longest chain ending at a task and
+	// previous the task before it in that chain
+	length := make(map[int]int, len(tasks))
+	previous := make(map[int]int, len(tasks))
+	var visit func(id int) int
+	visit = func(id int) int {
+		if n, ok := length[id]; ok {
+			return n
+		}
+		// Storage keeps the graph acyclic; a cycle would end the chain here
+		length[id] = 0
+		best, bestLength := 0, 0
+		blockers := slices.Clone(byID[id].BlockedBy)
+		slices.Sort(blockers)
+		for _, blocker := range blockers {
+			if _, ok := byID[blocker]; !ok {
+				continue
+			}
+			if n := visit(blocker); n > bestLength {
+				best, bestLength = blocker, n
+			}
+		}
+		previous[id] = best
+		length[id] = bestLength + 1
+		return length[id]
+	}
+
+	end, endLength := 0, 0
+	for _, task := range tasks {
+		if _, ok := byID[task.ID]; !ok {
+			continue
+		}
+		if n := visit(task.ID); n > endLength {
+			end, endLength = task.ID, n
+		}
+	}
+
+	path := make([]Task, endLength)
+	for i, id := endLength-1, end; i >= 0; i, id = i-1, previous[id] {
+		path[i] = byID[id]
+	}
+	return path
+}
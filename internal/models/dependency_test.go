@@ -0,0 +1,73 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+)
+
+// ids lists the IDs of tasks in order.
+func ids(tasks []Task) string {
+	s := make([]int, len(tasks))
+	for i, task := range tasks {
+		s[i] = task.ID
+	}
+	return fmt.Sprint(s)
+}
+
+func TestCriticalPath(t *testing.T) {
+	open := func(id int, blockedBy ...int) Task {
+		return Task{ID: id, Status: StatusTodo, BlockedBy: blockedBy}
+	}
+	done := func(id int, blockedBy ...int) Task {
+		return Task{ID: id, Status: StatusDone, BlockedBy: blockedBy}
+	}
+
+	tests := []struct {
+		name  string
+		tasks []Task
+		want  string
+	}{
+		{"no tasks", nil, "[]"},
+		{"no dependencies", []Task{open(3), open(1), open(2)}, "[3]"},
+		{"single chain", []Task{open(1), open(2, 1), open(3, 2)}, "[1 2 3]"},
+		{
+			"longest of several chains",
+			[]Task{open(1), open(2, 1), open(3), open(4, 3), open(5, 4), open(6, 2, 5)},
+			"[3 4 5 6]",
+		},
+		{
+			"longer branch wins over lower ID",
+			[]Task{open(1), open(2), open(3, 2), open(4, 1, 3)},
+			"[2 3 4]",
+		},
+		{
+			"tied chains end with the task listed first",
+			[]Task{open(1), open(2), open(4, 2), open(3, 1)},
+			"[2 4]",
+		},
+		{
+			"tied blockers prefer the lowest ID",
+			[]Task{open(5), open(2), open(9, 5, 2)},
+			"[2 9]",
+		},
+		{
+			"done tasks are left out",
+			[]Task{done(1), open(2, 1), open(3, 2), open(4), done(5, 4), open(6, 5)},
+			"[2 3]",
+		},
+		{
+			"dependencies on unlisted tasks are ignored",
+			[]Task{open(2, 1), open(3, 2, 99)},
+			"[2 3]",
+		},
+		{"only done tasks", []Task{done(1), done(2, 1)}, "[]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ids(CriticalPath(tt.tasks)); got != tt.want {
+				t.Errorf("CriticalPath() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
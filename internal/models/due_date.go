@@ -0,0 +1,136 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// DueDate is a task deadline. Without a time it is an all-day deadline on a
+// calendar date, which means the same date wherever the reader is; with a time
+// it is a precise instant. All-day dates are stored as midnight UTC.
+//
+// In JSON an all-day date is an RFC 3339 full-date ("2025-03-01") and a timed
+// deadline an RFC 3339 date-time ("2025-03-01T17:00:00+05:30"). The zero
+// value means no deadline and is encoded as null.
+type DueDate struct {
+	Time    time.Time
+	HasTime bool
+}
+
+// IsZero reports whether no deadline is set.
+func (d DueDate) IsZero() bool {
+	return d.Time.IsZero()
+}
+
+// LocalDate returns the calendar date of the deadline as seen from loc.
+func (d DueDate) LocalDate(loc *time.Location) time.Time {
+	if !d.HasTime {
+		y, m, day := d.Time.UTC().Date()
+		return time.Date(y, m, day, 0, 0, 0, 0, loc)
+	}
+	y, m, day := d.Time.In(loc).Date()
+	return time.Date(y, m, day, 0, 0, 0, 0, loc)
+}
+
+// IsOverdue reports whether the deadline has passed at now, as seen from loc.
+// An all-day deadline is only overdue once its whole day is over.
+func (d DueDate) IsOverdue(now time.Time, loc *time.Location) bool {
+	if d.IsZero() {
+		return false
+	}
+	if d.HasTime {
+		return now.After(d.Time)
+	}
+	return d.LocalDate(loc).Before(StartOfDay(now, loc))
+}
+
+// IsDueOn reports whether the deadline falls on the same calendar day as day in loc.
+func (d DueDate) IsDueOn(day time.Time, loc *time.Location) bool {
+	if d.IsZero() {
+		return false
+	}
+	return d.LocalDate(loc).Equal(StartOfDay(day, loc))
+}
+
+// StartOfDay returns midnight of t's date in loc.
+func StartOfDay(t time.Time, loc *time.Location) time.Time {
+	y, m, day := t.In(loc).Date()
+	return time.Date(y, m, day, 0, 0, 0, 0, loc)
+}
+
+// ParseDueDate accepts "YYYY-MM-DD" for all-day deadlines or an RFC 3339
+// date-time. An empty string yields the zero DueDate.
+func ParseDueDate(s string) (DueDate, error) {
+	if s == "" {
+		return DueDate{}, nil
+	}
+	if t, err := time.Parse(dateLayout, s); err == nil {
+		return DueDate{Time: t}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return DueDate{}, fmt.Errorf("due date %q must be YYYY-MM-DD or an RFC 3339 date-time", s)
+	}
+	return DueDate{Time: t.UTC(), HasTime: true}, nil
+}
+
+func (d DueDate) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	if !d.HasTime {
+		return d.Time.UTC().Format(dateLayout)
+	}
+	return d.Time.Format(time.RFC3339)
+}
+
+func (d DueDate) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(d.String())
+}
+
+func (d *DueDate) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*d = DueDate{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseDueDate(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Scan reads the due_date column. HasTime lives in its own column and is
+// scanned separately.
+func (d *DueDate) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		d.Time = time.Time{}
+	case time.Time:
+		d.Time = v.UTC()
+	default:
+		return fmt.Errorf("cannot scan %T into DueDate", src)
+	}
+	return nil
+}
+
+// Value stores the deadline instant, or NULL when unset.
+func (d DueDate) Value() (driver.Value, error) {
+	if d.IsZero() {
+		return nil, nil
+	}
+	return d.Time.UTC(), nil
+}
@@ -0,0 +1,27 @@
+package models
+
+import "errors"
+
+// Kinds of ConstraintError, shared by every storage backend.
+var (
+	ErrDuplicate           = errors.New("already exists")
+	ErrReferenceNotFound   = errors.New("refers to a record that does not exist")
+	ErrConstraintViolation = errors.New("violates a data constraint")
+)
+
+// ConstraintError reports a write rejected by a uniqueness, reference or
+// check rule, naming the offending field when it is known.
+type ConstraintError struct {
+	Err   error // ErrDuplicate, ErrReferenceNotFound or ErrConstraintViolation
+	Field string
+	Cause error // driver error, if any
+}
+
+func (e *ConstraintError) Error() string {
+	if e.Field == "" {
+		return e.Err.Error()
+	}
+	return e.Field + " " + e.Err.Error()
+}
+
+func (e *ConstraintError) Unwrap() error { return e.Err }
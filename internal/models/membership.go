@@ -0,0 +1,113 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Team is a named group of users that can be granted a role on projects as
+// a whole.
+type Team struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name" validate:"required,max=100"`
+	Description string    `json:"description" validate:"max=1000"`
+	CreatedBy   int       `json:"created_by" validate:"user"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	// MemberCount is filled in by reads and ignored by writes
+	MemberCount int `json:"member_count"`
+
+	// OrgID is set by storage from the organization the team is created in
+	OrgID int `json:"-"`
+}
+
+// TeamMember is a user belonging to a team.
+type TeamMember struct {
+	TeamID   int    `json:"team_id"`
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+}
+
+// ProjectMember is a user's role on a project. Direct members have TeamID 0;
+// members through a team grant name the team.
+type ProjectMember struct {
+	ProjectID int    `json:"project_id"`
+	UserID    int    `json:"user_id"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	TeamID    int    `json:"team_id,omitempty"`
+}
+
+// ProjectTeam grants every member of a team a role on a project.
+type ProjectTeam struct {
+	ProjectID int    `json:"project_id"`
+	TeamID    int    `json:"team_id"`
+	TeamName  string `json:"team_name"`
+	Role      string `json:"role"`
+}
+
+// Invitation offers a project role to whoever signs in with Email. Only the
+// hash of its token is stored.
+type Invitation struct {
+	ID         int        `json:"id"`
+	ProjectID  int        `json:"project_id"`
+	Email      string     `json:"email" validate:"required,email,max=255"`
+	Role       string     `json:"role" validate:"required,oneof=admin manager user"`
+	TokenHash  string     `json:"-"`
+	InvitedBy  int        `json:"invited_by"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+
+	// OrgID is set by storage from the organization of the project
+	OrgID int `json:"-"`
+}
+
+// Pending reports whether the invitation can still be accepted at now.
+func (i Invitation) Pending(now time.Time) bool {
+	return i.AcceptedAt == nil && now.Before(i.ExpiresAt)
+}
+
+// ErrInvitationInvalid is returned for invitations that were already
+// accepted, revoked or have expired.
+var ErrInvitationInvalid = errors.New("invitation is no longer valid")
+
+// ErrLastProjectAdmin is returned when a change would leave a project
+// without a direct admin.
+var ErrLastProjectAdmin = errors.New("project must keep at least one admin")
+
+// TeamInterface stores teams and their members.
+type TeamInterface interface {
+	ForOrg(orgID int) TeamInterface
+	CreateTeam(team Team) (Team, error)
+	GetTeam(id int) (Team, error)
+	ListTeams() ([]Team, error)
+	DeleteTeam(id int) error
+	AddTeamMember(teamID, userID int) error
+	RemoveTeamMember(teamID, userID int) error
+	ListTeamMembers(teamID int) ([]TeamMember, error)
+}
+
+// MembershipInterface stores who may work on which project and in what
+// role. GetProjectRole returns the highest role a user holds on a project,
+// directly or through a team, or "" if they hold none. ListMemberProjects
+// returns the IDs of those projects. The unscoped storage finds invitations
+// of every organization by token, for invitees who do not have an account
+// yet.
+type MembershipInterface interface {
+	ForOrg(orgID int) MembershipInterface
+	SetProjectMember(projectID, userID int, role string) error
+	RemoveProjectMember(projectID, userID int) error
+	ListProjectMembers(projectID int) ([]ProjectMember, error)
+	SetProjectTeam(projectID, teamID int, role string) error
+	RemoveProjectTeam(projectID, teamID int) error
+	ListProjectTeams(projectID int) ([]ProjectTeam, error)
+	GetProjectRole(projectID, userID int) (string, error)
+	ListMemberProjects(userID int) ([]int, error)
+
+	CreateInvitation(invitation Invitation) (Invitation, error)
+	GetInvitationByToken(tokenHash string) (Invitation, error)
+	ListInvitations(projectID int) ([]Invitation, error)
+	DeleteInvitation(projectID, id int) error
+	AcceptInvitation(id, userID int) error
+}
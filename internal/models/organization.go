@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Organization is a tenant. Every user, project, task and team belongs to
+// exactly one organization and is invisible to the others.
+type Organization struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name" validate:"required,max=100"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DefaultOrgID is the organization that existed data was moved into and
+// that users join when they register without creating one.
+const DefaultOrgID = 1
+
+// OrganizationInterface stores organizations. CreateOrganization registers
+// admin as the first user of the new organization in the same step and
+// returns both; the password is hashed like Register does.
+type OrganizationInterface interface {
+	CreateOrganization(org Organization, admin User) (Organization, User, error)
+	GetOrganization(id int) (Organization, error)
+}
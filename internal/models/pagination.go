@@ -0,0 +1,64 @@
+package models
+
+import "errors"
+
+// Pagination limits for list endpoints
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+// Sort orders
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// TaskSortFields are the fields tasks can be ordered by.
+var TaskSortFields = map[string]bool{
+	"due_date":   true,
+	"priority":   true,
+	"created_at": true,
+	"updated_at": true,
+	"title":      true,
+}
+
+// ListOptions controls paging and ordering of list queries. After is an
+// opaque keyset cursor taken from a previous page's NextCursor.
+type ListOptions struct {
+	Limit int
+	After string
+	Sort  string
+	Order string
+}
+
+// TaskPage is one page of tasks. NextCursor is empty on the last page and
+// Total counts every matching task, not just this page.
+type TaskPage struct {
+	Items      []Task `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      int    `json:"total"`
+}
+
+// TaskSearchHit is a task matched by full-text search with its relevance and
+// an excerpt in which matching words are wrapped in <mark></mark>.
+type TaskSearchHit struct {
+	Task
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+// TaskSearchPage is one page of full-text search results, most relevant first.
+type TaskSearchPage struct {
+	Items      []TaskSearchHit `json:"items"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+	Total      int             `json:"total"`
+}
+
+// SortRank orders full-text results by relevance; it is not accepted as a
+// sort parameter on plain listings.
+const SortRank = "rank"
+
+// ErrInvalidCursor is returned when a cursor cannot be decoded or was issued
+// for a different sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Project groups tasks. Key prefixes the keys of its tasks (OPS-42) and
+// cannot change once the project exists. Archived projects accept no new
+// tasks.
+type Project struct {
+	ID          int        `json:"id"`
+	Key         string     `json:"key" validate:"required,projectkey"`
+	Name        string     `json:"name" validate:"required,max=255"`
+	Description string     `json:"description" validate:"max=10000"`
+	CreatedBy   int        `json:"created_by" validate:"user"`
+	ArchivedAt  *time.Time `json:"archived_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	// TaskCount is filled in by reads and ignored by writes
+	TaskCount int `json:"task_count"`
+
+	// OrgID is set by storage from the organization the project is created in
+	OrgID int `json:"-"`
+}
+
+// Archived reports whether the project has been archived.
+func (p Project) Archived() bool {
+	return p.ArchivedAt != nil
+}
+
+// ErrProjectArchived is returned when a task is added to an archived project
+// or an archived project is edited.
+var ErrProjectArchived = errors.New("project is archived")
+
+// ErrProjectNotEmpty is returned when deleting a project that still has tasks.
+var ErrProjectNotEmpty = errors.New("project still has tasks")
+
+// ProjectInterface stores projects. UpdateProject changes the name and
+// description only. ListProjects returns projects ordered by key.
+type ProjectInterface interface {
+	ForOrg(orgID int) ProjectInterface
+	CreateProject(project Project) (Project, error)
+	GetProject(id int) (Project, error)
+	ListProjects(includeArchived bool) ([]Project, error)
+	UpdateProject(project Project) (Project, error)
+	SetProjectArchived(id int, archived bool) (Project, error)
+	DeleteProject(id int) error
+}
@@ -0,0 +1,60 @@
+package models
+
+import "errors"
+
+// SubtaskProgress rolls up the subtasks of a task at every depth. Percent is
+// the share of them that is done, rounded down, and 0 without subtasks.
+type SubtaskProgress struct {
+	Total   int `json:"total"`
+	Done    int `json:"done"`
+	Percent int `json:"percent"`
+}
+
+func NewSubtaskProgress(total, done int) SubtaskProgress {
+	progress := SubtaskProgress{Total: total, Done: done}
+	if total > 0 {
+		progress.Percent = done * 100 / total
+	}
+	return progress
+}
+
+// Open returns the number of subtasks that are not done yet.
+func (p SubtaskProgress) Open() int {
+	return p.Total - p.Done
+}
+
+// TaskTree is a task with its subtasks, nested to any depth.
+type TaskTree struct {
+	Task
+	Subtasks []TaskTree `json:"subtasks"`
+}
+
+// BuildTaskTree nests descendants, as returned by GetSubtree, under root.
+// Subtasks keep the order in which descendants lists them.
+func BuildTaskTree(root Task, descendants []Task) TaskTree {
+	children := make(map[int][]Task)
+	for _, task := range descendants {
+		children[task.ParentID] = append(children[task.ParentID], task)
+	}
+
+	var build func(task Task) TaskTree
+	build = func(task Task) TaskTree {
+		tree := TaskTree{Task: task, Subtasks: []TaskTree{}}
+		for _, child := range children[task.ID] {
+			tree.Subtasks = append(tree.Subtasks, build(child))
+		}
+		return tree
+	}
+	return build(root)
+}
+
+// Reasons a parent_id is rejected, reported as a ConstraintError on
+// parent_id.
+var (
+	ErrTaskCycle      = errors.New("would make the task a subtask of itself")
+	ErrSubtaskProject = errors.New("must be a task of the same project")
+)
+
+// ErrTaskHasSubtasks is returned when deleting a task that still has
+// subtasks.
+var ErrTaskHasSubtasks = errors.New("task still has subtasks")
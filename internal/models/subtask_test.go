@@ -0,0 +1,88 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+)
+
+// shape renders a tree as nested IDs, e.g. 1[2[4] 3].
+func shape(tree TaskTree) string {
+	s := fmt.Sprint(tree.ID)
+	if len(tree.Subtasks) == 0 {
+		return s
+	}
+	s += "["
+	for i, subtask := range tree.Subtasks {
+		if i > 0 {
+			s += " "
+		}
+		s += shape(subtask)
+	}
+	return s + "]"
+}
+
+func TestBuildTaskTree(t *testing.T) {
+	tests := []struct {
+		name        string
+		descendants []Task
+		want        string
+	}{
+		{"no subtasks", nil, "1"},
+		{"chain", []Task{{ID: 2, ParentID: 1}, {ID: 3, ParentID: 2}, {ID: 4, ParentID: 3}}, "1[2[3[4]]]"},
+		{
+			"branches keep the order of descendants",
+			[]Task{
+				{ID: 5, ParentID: 1}, {ID: 2, ParentID: 1}, {ID: 3, ParentID: 2},
+				{ID: 7, ParentID: 5}, {ID: 6, ParentID: 5}, {ID: 4, ParentID: 2}, {ID: 8, ParentID: 1},
+			},
+			"1[5[7 6] 2[3 4] 8]",
+		},
+		{"tasks outside the subtree are left out", []Task{{ID: 2, ParentID: 1}, {ID: 9, ParentID: 10}}, "1[2]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := BuildTaskTree(Task{ID: 1}, tt.descendants)
+			if got := shape(tree); got != tt.want {
+				t.Errorf("BuildTaskTree() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildTaskTreeLeavesHaveEmptySubtasks(t *testing.T) {
+	tree := BuildTaskTree(Task{ID: 1, Title: "Launch"}, []Task{{ID: 2, ParentID: 1, Title: "Copy"}})
+	if tree.Title != "Launch" || tree.Subtasks[0].Title != "Copy" {
+		t.Errorf("tasks were not kept: %+v", tree)
+	}
+	// Leaves encode as "subtasks": [] rather than null
+	if leaf := tree.Subtasks[0].Subtasks; leaf == nil || len(leaf) != 0 {
+		t.Errorf("leaf subtasks = %#v, want an empty slice", leaf)
+	}
+}
+
+func TestSubtaskProgress(t *testing.T) {
+	tests := []struct {
+		total, done int
+		percent     int
+		open        int
+	}{
+		{0, 0, 0, 0},
+		{4, 0, 0, 4},
+		{4, 1, 25, 3},
+		{3, 2, 66, 1},
+		{3, 3, 100, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d of %d", tt.done, tt.total), func(t *testing.T) {
+			progress := NewSubtaskProgress(tt.total, tt.done)
+			if progress.Total != tt.total || progress.Done != tt.done || progress.Percent != tt.percent {
+				t.Errorf("NewSubtaskProgress() = %+v, want %d%%", progress, tt.percent)
+			}
+			if got := progress.Open(); got != tt.open {
+				t.Errorf("Open() = %d, want %d", got, tt.open)
+			}
+		})
+	}
+}
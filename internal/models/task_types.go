@@ -0,0 +1,152 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// TaskStatus is the workflow state of a task.
+type TaskStatus string
+
+const (
+	StatusTodo       TaskStatus = "todo"
+	StatusInProgress TaskStatus = "in-progress"
+	StatusDone       TaskStatus = "done"
+)
+
+// TaskStatuses lists every valid status in workflow order.
+var TaskStatuses = []TaskStatus{StatusTodo, StatusInProgress, StatusDone}
+
+// Valid reports whether s is a known status.
+func (s TaskStatus) Valid() bool {
+	for _, status := range TaskStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// UnmarshalJSON normalises case, surrounding whitespace and the common
+// "in_progress"/"in progress" spellings. Unknown values are kept as-is so that
+// validation can report them instead of failing the whole decode.
+func (s *TaskStatus) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*s = ParseTaskStatus(raw)
+	return nil
+}
+
+// ParseTaskStatus normalises a status string; check the result with Valid.
+func ParseTaskStatus(raw string) TaskStatus {
+	normalized := strings.ToLower(strings.TrimSpace(raw))
+	normalized = strings.NewReplacer("_", "-", " ", "-").Replace(normalized)
+	return TaskStatus(normalized)
+}
+
+// Dashboard groups a user's tasks by status and highlights open tasks that
+// are due today or overdue in the user's time zone.
+type Dashboard struct {
+	ByStatus map[TaskStatus][]Task `json:"by_status"`
+	DueToday []Task                `json:"due_today"`
+	Overdue  []Task                `json:"overdue"`
+}
+
+// BuildDashboard arranges tasks into a Dashboard as of now in loc. Every status
+// gets a column, even when empty, so clients always receive the same keys.
+func BuildDashboard(tasks []Task, now time.Time, loc *time.Location) Dashboard {
+	dashboard := Dashboard{
+		ByStatus: make(map[TaskStatus][]Task, len(TaskStatuses)),
+		DueToday: []Task{},
+		Overdue:  []Task{},
+	}
+	for _, status := range TaskStatuses {
+		dashboard.ByStatus[status] = []Task{}
+	}
+
+	for _, task := range tasks {
+		dashboard.ByStatus[task.Status] = append(dashboard.ByStatus[task.Status], task)
+		if task.Status == StatusDone {
+			continue
+		}
+		if task.DueDate.IsOverdue(now, loc) {
+			dashboard.Overdue = append(dashboard.Overdue, task)
+		} else if task.DueDate.IsDueOn(now, loc) {
+			dashboard.DueToday = append(dashboard.DueToday, task)
+		}
+	}
+	return dashboard
+}
+
+// Filter returns the dashboard with only the tasks keep accepts.
+func (d Dashboard) Filter(keep func(Task) bool) Dashboard {
+	filtered := Dashboard{
+		ByStatus: make(map[TaskStatus][]Task, len(d.ByStatus)),
+		DueToday: filterTasks(d.DueToday, keep),
+		Overdue:  filterTasks(d.Overdue, keep),
+	}
+	for status, tasks := range d.ByStatus {
+		filtered.ByStatus[status] = filterTasks(tasks, keep)
+	}
+	return filtered
+}
+
+func filterTasks(tasks []Task, keep func(Task) bool) []Task {
+	kept := []Task{}
+	for _, task := range tasks {
+		if keep(task) {
+			kept = append(kept, task)
+		}
+	}
+	return kept
+}
+
+// TaskPriority is the urgency of a task.
+type TaskPriority string
+
+const (
+	PriorityLow    TaskPriority = "low"
+	PriorityMedium TaskPriority = "medium"
+	PriorityHigh   TaskPriority = "high"
+)
+
+// TaskPriorities lists every valid priority from lowest to highest.
+var TaskPriorities = []TaskPriority{PriorityLow, PriorityMedium, PriorityHigh}
+
+// Valid reports whether p is a known priority.
+func (p TaskPriority) Valid() bool {
+	for _, priority := range TaskPriorities {
+		if p == priority {
+			return true
+		}
+	}
+	return false
+}
+
+// Rank orders priorities from 1 (low) to 3 (high); unknown values rank 0.
+func (p TaskPriority) Rank() int {
+	for i, priority := range TaskPriorities {
+		if p == priority {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// UnmarshalJSON normalises case and surrounding whitespace.
+func (p *TaskPriority) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*p = ParseTaskPriority(raw)
+	return nil
+}
+
+// ParseTaskPriority normalises a priority string; check the result with Valid.
+func ParseTaskPriority(raw string) TaskPriority {
+	return TaskPriority(strings.ToLower(strings.TrimSpace(raw)))
+}
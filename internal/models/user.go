@@ -1,55 +1,160 @@
 package models
 
-import "net/http"
+import (
+	"errors"
+	"time"
+)
 
-// User model for authentication and task assignment
+// Roles a user can hold
+const (
+	RoleAdmin   = "admin"
+	RoleManager = "manager"
+	RoleUser    = "user"
+)
+
+// User model for authentication and task assignment. The validate tags are
+// the rules of the validation package.
 type User struct {
 	ID       int    `json:"id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"` // omit in JSON response
-	Role     string `json:"role"`     // e.g., admin, manager, user
+	Username string `json:"username" validate:"required,min=3,max=100"`
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,password,max=72"` // omit in JSON response
+	Role     string `json:"role" validate:"oneof=admin manager user"`     // e.g., admin, manager, user
+	Timezone string `json:"timezone" validate:"timezone"`                 // IANA name used for due-date logic, e.g. Asia/Kolkata
+	OrgID    int    `json:"org_id"`                                       // set by storage from the organization the user registers in
 }
 
 // Task model representing the core task entity
 type Task struct {
-	ID          int    `json:"id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	DueDate     string `json:"due_date"`
-	Priority    string `json:"priority"` // low, medium, high
-	Status      string `json:"status"`   // todo, in-progress, done
-	CreatedBy   int    `json:"created_by"`
-	AssignedTo  int    `json:"assigned_to"`
-	CreatedAt   string `json:"created_at"`
-	UpdatedAt   string `json:"updated_at"`
+	ID          int          `json:"id"`
+	Title       string       `json:"title" validate:"required,max=255"`
+	Description string       `json:"description" validate:"max=10000"`
+	DueDate     DueDate      `json:"due_date"`
+	Priority    TaskPriority `json:"priority" validate:"required,enum"`
+	Status      TaskStatus   `json:"status" validate:"required,enum"`
+	CreatedBy   int          `json:"created_by" validate:"user"`
+	AssignedTo  int          `json:"assigned_to" validate:"user"` // 0 leaves the task unassigned
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	Version     int          `json:"version"` // incremented on every write; guards concurrent updates
+
+	// ProjectID is fixed when the task is created; 0 means no project. Key
+	// (e.g. OPS-42) is assigned by storage for tasks in a project.
+	ProjectID int    `json:"project_id"`
+	Key       string `json:"key,omitempty"`
+
+	// ParentID makes the task a subtask of another task in the same
+	// project; 0 means a top-level task.
+	ParentID int `json:"parent_id"`
+
+	// OrgID is set by storage from the organization the task is created in
+	OrgID int `json:"-"`
+
+	// CommentCount, Progress, BlockedBy and Blocked are filled in by reads
+	// and ignored by writes. BlockedBy lists the tasks this one depends on;
+	// Blocked is set while the task is open and any of them is not done.
+	CommentCount int             `json:"comment_count"`
+	Progress     SubtaskProgress `json:"progress"`
+	BlockedBy    []int           `json:"blocked_by,omitempty"`
+	Blocked      bool            `json:"blocked"`
 }
 
-// TaskFilter struct for handling filter/search queries
+// TaskFilter struct for handling filter/search queries. Query matches title or
+// description and Title matches the title only, both as case-insensitive
+// substrings. Status and Priority match any of the listed values. DueBefore
+// and DueAfter are inclusive YYYY-MM-DD bounds on the deadline's calendar date
+// in Location; timed deadlines are converted to that zone first.
 type TaskFilter struct {
-	Title      string `json:"title"`
-	Status     string `json:"status"`
-	Priority   string `json:"priority"`
-	DueBefore  string `json:"due_before"`
-	DueAfter   string `json:"due_after"`
-	AssignedTo int    `json:"assigned_to"`
-	CreatedBy  int    `json:"created_by"`
+	Query      string         `json:"q" validate:"max=200"`
+	Title      string         `json:"title" validate:"max=255"`
+	Status     []TaskStatus   `json:"status" validate:"enum"`
+	Priority   []TaskPriority `json:"priority" validate:"enum"`
+	DueBefore  string         `json:"due_before" validate:"date"`
+	DueAfter   string         `json:"due_after" validate:"date"`
+	AssignedTo int            `json:"assigned_to" validate:"min=1"`
+	CreatedBy  int            `json:"created_by" validate:"min=1"`
+	ProjectID  int            `json:"project_id" validate:"min=1"`
+
+	Location *time.Location `json:"-"`
+
+	// When RestrictProjects is set only tasks outside any project or in one
+	// of VisibleProjects match. Handlers set these from the caller's
+	// memberships; clients cannot.
+	RestrictProjects bool  `json:"-"`
+	VisibleProjects  []int `json:"-"`
 }
 
+// RefreshToken is a server-side record of an issued refresh token. Only the
+// hash of the token is stored. Tokens issued from the same login share a
+// FamilyID so that a replayed token can revoke the whole chain.
+type RefreshToken struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	TokenHash  string     `json:"-"`
+	FamilyID   string     `json:"family_id"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	ReplacedBy *int       `json:"replaced_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// ErrTokenReused is returned when a refresh token that was already rotated or
+// revoked is presented again.
+var ErrTokenReused = errors.New("refresh token already used")
+
+// ErrVersionConflict is returned when a task was changed since the version the
+// caller read.
+var ErrVersionConflict = errors.New("task was modified concurrently")
+
 // Interfaces
 
+// The interfaces of tenant data have ForOrg, which returns the same storage
+// confined to one organization: reads never return rows of another
+// organization and writes create rows in that organization. The unscoped
+// storage handed to constructors only serves sign-in and token refresh.
+
 type UserInterface interface {
-	Register(user User) error
+	ForOrg(orgID int) UserInterface
+	Register(user User) (User, error)
 	Login(email, password string) (User, error)
 	GetUserByID(id int) (User, error)
+	UpdateRole(id int, role string) error
+	UpdateTimezone(id int, timezone string) error
 }
 
+// TaskInterface stores tasks. UpdateTask writes the task only if check
+// accepts it as stored, with its derived fields, and no other write can
+// change what check saw until the update is done. ListSubtasks returns the
+// direct subtasks of a task and GetSubtree every task below it, both ordered
+// by ID. DeleteTask
+// fails with ErrTaskHasSubtasks while the task has subtasks. AddDependency
+// makes taskID wait for dependsOnID and does nothing if it already does;
+// ListDependencies returns the tasks taskID waits for, ordered by ID.
+// GetCriticalPath returns the CriticalPath of the open tasks of a project.
 type TaskInterface interface {
-	CreateTask(task Task) error
+	ForOrg(orgID int) TaskInterface
+	CreateTask(task Task) (Task, error)
 	GetTaskByID(id int) (Task, error)
-	UpdateTask(task Task) error
-	DeleteTask(id int) error
-	ListTasks(r *http.Request) ([]Task, error)
-	SearchAndFilterTasks(filter TaskFilter) ([]Task, error)
-	GetUserDashboard(userID int) (map[string][]Task, error)
+	GetTaskByKey(key string) (Task, error)
+	UpdateTask(task Task, check func(stored Task) error) error
+	DeleteTask(id, version int) error
+	ListTasks(opts ListOptions) (TaskPage, error)
+	SearchAndFilterTasks(filter TaskFilter, opts ListOptions) (TaskPage, error)
+	FullTextSearchTasks(text string, filter TaskFilter, opts ListOptions) (TaskSearchPage, error)
+	GetUserDashboard(userID int, loc *time.Location) (Dashboard, error)
+	GetProjectDashboard(projectID int, loc *time.Location) (Dashboard, error)
+	ListSubtasks(parentID int) ([]Task, error)
+	GetSubtree(rootID int) ([]Task, error)
+	AddDependency(taskID, dependsOnID int) error
+	RemoveDependency(taskID, dependsOnID int) error
+	ListDependencies(taskID int) ([]Task, error)
+	GetCriticalPath(projectID int) ([]Task, error)
+}
+
+type TokenInterface interface {
+	CreateRefreshToken(token RefreshToken) error
+	GetRefreshToken(tokenHash string) (RefreshToken, error)
+	RotateRefreshToken(oldID int, next RefreshToken) error
+	RevokeTokenFamily(familyID string) error
+	RevokeAllUserTokens(userID int) error
 }
@@ -0,0 +1,37 @@
+package rbac
+
+import "github.com/naveeshkumar24/internal/models"
+
+// TaskAccess describes how much of a task a caller may modify.
+type TaskAccess int
+
+const (
+	TaskAccessNone   TaskAccess = iota
+	TaskAccessStatus            // assignees may only move the task through its workflow
+	TaskAccessFull
+)
+
+// TaskUpdateAccess resolves what the caller may change on task. The creator
+// and anyone allowed to update any task get full access; the assignee may
+// only change the status.
+func TaskUpdateAccess(role string, userID int, task models.Task) TaskAccess {
+	if !Can(role, ActionTaskUpdate) {
+		return TaskAccessNone
+	}
+	if task.CreatedBy == userID || Can(role, ActionTaskUpdateAny) {
+		return TaskAccessFull
+	}
+	if task.AssignedTo == userID {
+		return TaskAccessStatus
+	}
+	return TaskAccessNone
+}
+
+// CanDeleteTask reports whether the caller may delete task: only its creator
+// or a role allowed to delete any task.
+func CanDeleteTask(role string, userID int, task models.Task) bool {
+	if !Can(role, ActionTaskDelete) {
+		return false
+	}
+	return task.CreatedBy == userID || Can(role, ActionTaskDeleteAny)
+}
@@ -0,0 +1,95 @@
+package rbac
+
+import "github.com/naveeshkumar24/internal/models"
+
+// Action is something a caller may be allowed to do.
+type Action string
+
+const (
+	ActionTaskCreate          Action = "task:create"
+	ActionTaskAssign          Action = "task:assign" // assign tasks to someone other than yourself
+	ActionTaskUpdate          Action = "task:update"
+	ActionTaskUpdateAny       Action = "task:update_any" // update tasks you neither created nor were assigned
+	ActionTaskDelete          Action = "task:delete"
+	ActionTaskDeleteAny       Action = "task:delete_any" // delete tasks created by someone else
+	ActionUserViewAny         Action = "user:view_any"
+	ActionDashboardViewAny    Action = "dashboard:view_any"
+	ActionRoleManage          Action = "user:manage_roles"
+	ActionCommentDeleteAny    Action = "comment:delete_any"    // delete comments written by someone else
+	ActionAttachmentDeleteAny Action = "attachment:delete_any" // delete files uploaded by someone else
+	ActionProjectManage       Action = "project:manage"        // create, edit, archive and delete projects
+	ActionProjectMembers      Action = "project:members"       // add, remove and invite project members
+	ActionTeamManage          Action = "team:manage"           // create and delete teams and change their members
+)
+
+var userActions = []Action{
+	ActionTaskCreate,
+	ActionTaskUpdate,
+	ActionTaskDelete,
+}
+
+var managerActions = append([]Action{
+	ActionTaskAssign,
+	ActionTaskUpdateAny,
+	ActionUserViewAny,
+	ActionDashboardViewAny,
+	ActionCommentDeleteAny,
+	ActionAttachmentDeleteAny,
+	ActionProjectManage,
+	ActionProjectMembers,
+}, userActions...)
+
+var adminActions = append([]Action{
+	ActionTaskDeleteAny,
+	ActionRoleManage,
+	ActionTeamManage, // team members inherit the team's project roles
+}, managerActions...)
+
+// permissions maps each role to the set of actions it may perform.
+var permissions = map[string]map[Action]bool{
+	models.RoleUser:    toSet(userActions),
+	models.RoleManager: toSet(managerActions),
+	models.RoleAdmin:   toSet(adminActions),
+}
+
+// Can reports whether the given role may perform the action. Unknown roles
+// may do nothing.
+func Can(role string, action Action) bool {
+	return permissions[role][action]
+}
+
+// ValidRole reports whether role is one of the known roles.
+func ValidRole(role string) bool {
+	_, ok := permissions[role]
+	return ok
+}
+
+func toSet(actions []Action) map[Action]bool {
+	set := make(map[Action]bool, len(actions))
+	for _, action := range actions {
+		set[action] = true
+	}
+	return set
+}
+
+// roleRanks orders the roles from least to most privileged.
+var roleRanks = map[string]int{
+	models.RoleUser:    1,
+	models.RoleManager: 2,
+	models.RoleAdmin:   3,
+}
+
+// RoleRank returns how privileged role is; unknown roles and "" rank 0.
+func RoleRank(role string) int {
+	return roleRanks[role]
+}
+
+// EffectiveRole combines the global role from the token with the role held
+// on a project. Global admins act as admins everywhere; everyone else gets
+// their project role, and "" means no access to the project at all.
+func EffectiveRole(globalRole, projectRole string) string {
+	if globalRole == models.RoleAdmin {
+		return models.RoleAdmin
+	}
+	return projectRole
+}
@@ -0,0 +1,323 @@
+// Package validation checks models against declarative rules given in
+// `validate` struct tags and reports every violation at once.
+//
+// Rules are separated by commas:
+//
+//	required      non-zero value; strings must contain non-space characters
+//	min=N, max=N  length of strings and slices, or value of integers
+//	email         address of the form local@domain.tld
+//	password      at least 8 characters with a letter and a digit
+//	oneof=a b c   one of the space-separated values
+//	enum          a value whose type reports Valid(); checked per element for slices
+//	timezone      IANA time zone name
+//	date          YYYY-MM-DD
+//	user          ID of an existing user; 0 means none
+//	projectkey    2-10 upper-case letters and digits, starting with a letter
+//
+// Zero values skip every rule but required, so optional fields only need
+// checking when they are set.
+package validation
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/naveeshkumar24/internal/models"
+)
+
+// Errors maps a JSON field name to what is wrong with it.
+type Errors map[string]string
+
+func (e Errors) Error() string {
+	fields := make([]string, 0, len(e))
+	for field, msg := range e {
+		fields = append(fields, field+": "+msg)
+	}
+	sort.Strings(fields)
+	return "validation failed: " + strings.Join(fields, "; ")
+}
+
+// Add records a problem with field, keeping the first message per field.
+func (e Errors) Add(field, msg string) {
+	if _, exists := e[field]; !exists {
+		e[field] = msg
+	}
+}
+
+// Merge adds every problem recorded in other.
+func (e Errors) Merge(other Errors) {
+	for field, msg := range other {
+		e.Add(field, msg)
+	}
+}
+
+// Err returns e as an error, or nil if nothing was recorded.
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// UserLookup is the part of the user repository the user rule needs.
+type UserLookup interface {
+	GetUserByID(id int) (models.User, error)
+	ForOrg(orgID int) models.UserInterface
+}
+
+// Validator evaluates struct tag rules. Rules that need storage use the
+// lookups it was built with.
+type Validator struct {
+	users UserLookup
+}
+
+func New(users UserLookup) *Validator {
+	return &Validator{users: users}
+}
+
+// ForOrg returns a Validator whose user rule only accepts users of the
+// organization orgID.
+func (v *Validator) ForOrg(orgID int) *Validator {
+	if v.users == nil {
+		return v
+	}
+	return &Validator{users: v.users.ForOrg(orgID)}
+}
+
+// Validate checks s and returns its violations as Errors, nil if there are
+// none, or another error if a rule could not be evaluated.
+func (v *Validator) Validate(s interface{}) error {
+	errs, err := v.Struct(s)
+	if err != nil {
+		return err
+	}
+	return errs.Err()
+}
+
+// Struct checks every tagged field of s, a struct or pointer to one.
+func (v *Validator) Struct(s interface{}) (Errors, error) {
+	errs := Errors{}
+	value := reflect.Indirect(reflect.ValueOf(s))
+	if value.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("validation: %T is not a struct", s)
+	}
+
+	fields := value.Type()
+	for i := 0; i < fields.NumField(); i++ {
+		field := fields.Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" || !field.IsExported() {
+			continue
+		}
+		name := jsonName(field)
+		for _, rule := range strings.Split(tag, ",") {
+			ruleName, param, _ := strings.Cut(rule, "=")
+			msg, err := v.check(ruleName, param, value.Field(i))
+			if err != nil {
+				return nil, fmt.Errorf("validation: %s %s: %w", name, ruleName, err)
+			}
+			if msg != "" {
+				errs.Add(name, msg)
+				break
+			}
+		}
+	}
+	return errs, nil
+}
+
+var emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+var projectKeyPattern = regexp.MustCompile(`^[A-Z][A-Z0-9]{1,9}$`)
+
+// check evaluates one rule and returns the violation message, if any.
+func (v *Validator) check(rule, param string, field reflect.Value) (string, error) {
+	if rule == "required" {
+		if isBlank(field) {
+			return "is required", nil
+		}
+		return "", nil
+	}
+	if field.IsZero() {
+		return "", nil
+	}
+
+	switch rule {
+	case "min", "max":
+		limit, err := strconv.Atoi(param)
+		if err != nil {
+			return "", fmt.Errorf("bad limit %q", param)
+		}
+		return checkLimit(rule, limit, field), nil
+
+	case "email":
+		if !emailPattern.MatchString(field.String()) {
+			return "must be a valid email address", nil
+		}
+
+	case "password":
+		if !strongPassword(field.String()) {
+			return "must be at least 8 characters and contain a letter and a digit", nil
+		}
+
+	case "oneof":
+		allowed := strings.Fields(param)
+		for _, a := range allowed {
+			if field.String() == a {
+				return "", nil
+			}
+		}
+		return "must be one of " + strings.Join(allowed, ", "), nil
+
+	case "enum":
+		return checkEnum(field), nil
+
+	case "timezone":
+		if !ValidTimezone(field.String()) {
+			return "must be a valid IANA time zone", nil
+		}
+
+	case "date":
+		if _, err := time.Parse("2006-01-02", field.String()); err != nil {
+			return "must be a date in YYYY-MM-DD format", nil
+		}
+
+	case "user":
+		return v.checkUser(int(field.Int()))
+
+	case "projectkey":
+		if !projectKeyPattern.MatchString(field.String()) {
+			return "must be 2-10 upper-case letters and digits, starting with a letter", nil
+		}
+
+	default:
+		return "", errors.New("unknown rule")
+	}
+	return "", nil
+}
+
+func isBlank(field reflect.Value) bool {
+	if field.Kind() == reflect.String {
+		return strings.TrimSpace(field.String()) == ""
+	}
+	return field.IsZero()
+}
+
+func checkLimit(rule string, limit int, field reflect.Value) string {
+	var n int
+	unit := " characters"
+	switch field.Kind() {
+	case reflect.String:
+		n = utf8.RuneCountInString(field.String())
+	case reflect.Slice:
+		n, unit = field.Len(), " values"
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		n, unit = int(field.Int()), ""
+	}
+
+	switch {
+	case rule == "min" && n < limit:
+		if unit == "" {
+			return fmt.Sprintf("must be at least %d", limit)
+		}
+		return fmt.Sprintf("must be at least %d%s", limit, unit)
+	case rule == "max" && n > limit:
+		if unit == "" {
+			return fmt.Sprintf("must be at most %d", limit)
+		}
+		return fmt.Sprintf("must be at most %d%s", limit, unit)
+	}
+	return ""
+}
+
+func strongPassword(password string) bool {
+	if utf8.RuneCountInString(password) < 8 {
+		return false
+	}
+	var letter, digit bool
+	for _, r := range password {
+		letter = letter || unicode.IsLetter(r)
+		digit = digit || unicode.IsDigit(r)
+	}
+	return letter && digit
+}
+
+type enum interface {
+	Valid() bool
+}
+
+// enumValues lists the allowed values of each enum type for messages.
+var enumValues = map[reflect.Type]string{
+	reflect.TypeOf(models.TaskStatus("")):   joinValues(models.TaskStatuses),
+	reflect.TypeOf(models.TaskPriority("")): joinValues(models.TaskPriorities),
+}
+
+func checkEnum(field reflect.Value) string {
+	values := []reflect.Value{field}
+	if field.Kind() == reflect.Slice {
+		values = values[:0]
+		for i := 0; i < field.Len(); i++ {
+			values = append(values, field.Index(i))
+		}
+	}
+
+	for _, value := range values {
+		e, ok := value.Interface().(enum)
+		if ok && e.Valid() {
+			continue
+		}
+		msg := fmt.Sprintf("unknown value %q", value.String())
+		if allowed, ok := enumValues[value.Type()]; ok {
+			msg += "; must be one of " + allowed
+		}
+		return msg
+	}
+	return ""
+}
+
+func joinValues[T ~string](values []T) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = string(v)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (v *Validator) checkUser(id int) (string, error) {
+	if id < 0 {
+		return "must be a positive user ID", nil
+	}
+	if v.users == nil {
+		return "", errors.New("no user lookup configured")
+	}
+	_, err := v.users.GetUserByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "refers to a user that does not exist", nil
+	}
+	return "", err
+}
+
+// ValidTimezone reports whether tz is an IANA zone name known to this binary.
+func ValidTimezone(tz string) bool {
+	if tz == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(tz)
+	return err == nil
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
@@ -0,0 +1,121 @@
+package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/naveeshkumar24/internal/models"
+)
+
+// Transition allows moving a task from one status to another. An empty Roles
+// list means every role may make the move.
+type Transition struct {
+	From  models.TaskStatus `json:"from"`
+	To    models.TaskStatus `json:"to"`
+	Roles []string          `json:"roles,omitempty"`
+}
+
+// DefaultTransitions lets anyone move work forward or back between todo and
+// in-progress and finish it, but only managers and admins may reopen done
+// tasks.
+var DefaultTransitions = []Transition{
+	{From: models.StatusTodo, To: models.StatusInProgress},
+	{From: models.StatusTodo, To: models.StatusDone},
+	{From: models.StatusInProgress, To: models.StatusTodo},
+	{From: models.StatusInProgress, To: models.StatusDone},
+	{From: models.StatusDone, To: models.StatusInProgress, Roles: []string{models.RoleManager, models.RoleAdmin}},
+	{From: models.StatusDone, To: models.StatusTodo, Roles: []string{models.RoleManager, models.RoleAdmin}},
+}
+
+var (
+	// ErrTransitionNotAllowed means the workflow has no edge between the statuses.
+	ErrTransitionNotAllowed = errors.New("status transition not allowed")
+	// ErrTransitionForbidden means the edge exists but not for the caller's role.
+	ErrTransitionForbidden = errors.New("status transition not permitted for role")
+	// ErrSubtasksOpen means a task cannot be done before its subtasks are.
+	ErrSubtasksOpen = errors.New("task has open subtasks")
+	// ErrTaskBlocked means a task cannot be done before the tasks it depends on are.
+	ErrTaskBlocked = errors.New("task is blocked by open tasks")
+)
+
+// Machine is the state machine of allowed status transitions.
+type Machine struct {
+	transitions map[models.TaskStatus]map[models.TaskStatus][]string
+
+	// RequireSubtasksDone keeps tasks from moving to done while any of
+	// their subtasks is still open.
+	RequireSubtasksDone bool
+}
+
+func NewMachine(transitions []Transition) (*Machine, error) {
+	m := &Machine{transitions: make(map[models.TaskStatus]map[models.TaskStatus][]string)}
+	for _, t := range transitions {
+		if !t.From.Valid() || !t.To.Valid() {
+			return nil, fmt.Errorf("invalid transition %q -> %q", t.From, t.To)
+		}
+		if m.transitions[t.From] == nil {
+			m.transitions[t.From] = make(map[models.TaskStatus][]string)
+		}
+		m.transitions[t.From][t.To] = t.Roles
+	}
+	return m, nil
+}
+
+// Default returns the machine built from DefaultTransitions.
+func Default() *Machine {
+	m, _ := NewMachine(DefaultTransitions)
+	return m
+}
+
+// Load reads transitions from a JSON file containing an array of
+// {"from", "to", "roles"} objects. An empty path yields the default machine.
+func Load(path string) (*Machine, error) {
+	if path == "" {
+		return Default(), nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var transitions []Transition
+	if err := json.Unmarshal(raw, &transitions); err != nil {
+		return nil, fmt.Errorf("parse workflow %s: %w", path, err)
+	}
+	return NewMachine(transitions)
+}
+
+// Check reports whether role may move a task from one status to another.
+// Staying in the same status is always allowed.
+func (m *Machine) Check(from, to models.TaskStatus, role string) error {
+	if from == to {
+		return nil
+	}
+	roles, ok := m.transitions[from][to]
+	if !ok {
+		return fmt.Errorf("%w: %s -> %s", ErrTransitionNotAllowed, from, to)
+	}
+	if len(roles) > 0 && !slices.Contains(roles, role) {
+		return fmt.Errorf("%w: %s -> %s requires one of %v", ErrTransitionForbidden, from, to, roles)
+	}
+	return nil
+}
+
+// CheckCompletion reports whether task, as currently stored, may move to
+// status to given the state of its dependencies and subtasks. Blocked tasks
+// can never be done.
+func (m *Machine) CheckCompletion(task models.Task, to models.TaskStatus) error {
+	if to != models.StatusDone || task.Status == models.StatusDone {
+		return nil
+	}
+	if task.Blocked {
+		return fmt.Errorf("%w (depends on tasks %v)", ErrTaskBlocked, task.BlockedBy)
+	}
+	if m.RequireSubtasksDone && task.Progress.Open() > 0 {
+		return fmt.Errorf("%w: %d of %d not done", ErrSubtasksOpen, task.Progress.Open(), task.Progress.Total)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package workflow
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/naveeshkumar24/internal/models"
+)
+
+func TestDefaultTransitions(t *testing.T) {
+	const (
+		todo       = models.StatusTodo
+		inProgress = models.StatusInProgress
+		done       = models.StatusDone
+	)
+	tests := []struct {
+		from, to models.TaskStatus
+		role     string
+		want     error
+	}{
+		{todo, todo, models.RoleUser, nil},
+		{todo, inProgress, models.RoleUser, nil},
+		{todo, done, models.RoleUser, nil},
+		{inProgress, todo, models.RoleUser, nil},
+		{inProgress, done, models.RoleUser, nil},
+		{done, done, models.RoleUser, nil},
+
+		// Only managers and admins may reopen finished work
+		{done, inProgress, models.RoleUser, ErrTransitionForbidden},
+		{done, todo, models.RoleUser, ErrTransitionForbidden},
+		{done, todo, "", ErrTransitionForbidden},
+		{done, inProgress, models.RoleManager, nil},
+		{done, todo, models.RoleManager, nil},
+		{done, inProgress, models.RoleAdmin, nil},
+		{done, todo, models.RoleAdmin, nil},
+
+		// Unknown statuses have no edges at all
+		{todo, "archived", models.RoleAdmin, ErrTransitionNotAllowed},
+		{"archived", todo, models.RoleAdmin, ErrTransitionNotAllowed},
+	}
+
+	machine := Default()
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to)+" as "+tt.role, func(t *testing.T) {
+			err := machine.Check(tt.from, tt.to, tt.role)
+			if !errors.Is(err, tt.want) || (tt.want == nil) != (err == nil) {
+				t.Errorf("Check() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCustomTransitions(t *testing.T) {
+	machine, err := NewMachine([]Transition{
+		{From: models.StatusTodo, To: models.StatusInProgress},
+		{From: models.StatusInProgress, To: models.StatusDone, Roles: []string{models.RoleManager}},
+	})
+	if err != nil {
+		t.Fatalf("NewMachine: %v", err)
+	}
+	if err := machine.Check(models.StatusTodo, models.StatusDone, models.RoleAdmin); !errors.Is(err, ErrTransitionNotAllowed) {
+		t.Errorf("todo->done: got %v, want %v", err, ErrTransitionNotAllowed)
+	}
+	if err := machine.Check(models.StatusInProgress, models.StatusDone, models.RoleAdmin); !errors.Is(err, ErrTransitionForbidden) {
+		t.Errorf("in-progress->done as admin: got %v, want %v", err, ErrTransitionForbidden)
+	}
+	if err := machine.Check(models.StatusInProgress, models.StatusDone, models.RoleManager); err != nil {
+		t.Errorf("in-progress->done as manager: %v", err)
+	}
+
+	if _, err := NewMachine([]Transition{{From: models.StatusTodo, To: "later"}}); err == nil {
+		t.Error("NewMachine accepted an unknown status")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	machine, err := Load("")
+	if err != nil || machine.Check(models.StatusTodo, models.StatusDone, models.RoleUser) != nil {
+		t.Fatalf("Load(\"\") did not return the default workflow: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "workflow.json")
+	if err := os.WriteFile(path, []byte(`[{"from":"todo","to":"done","roles":["admin"]}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	machine, err = Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := machine.Check(models.StatusTodo, models.StatusDone, models.RoleUser); !errors.Is(err, ErrTransitionForbidden) {
+		t.Errorf("loaded workflow: got %v, want %v", err, ErrTransitionForbidden)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"from":"todo"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Load accepted a malformed file")
+	}
+}
+
+func TestCheckCompletion(t *testing.T) {
+	open := models.Task{Status: models.StatusInProgress}
+	blocked := models.Task{Status: models.StatusTodo, Blocked: true, BlockedBy: []int{3}}
+	withSubtasks := models.Task{Status: models.StatusTodo, Progress: models.NewSubtaskProgress(3, 1)}
+
+	tests := []struct {
+		name            string
+		task            models.Task
+		to              models.TaskStatus
+		requireSubtasks bool
+		want            error
+	}{
+		{"open task", open, models.StatusDone, true, nil},
+		{"blocked task", blocked, models.StatusDone, false, ErrTaskBlocked},
+		{"blocked task not finishing", blocked, models.StatusInProgress, false, nil},
+		{"open subtasks", withSubtasks, models.StatusDone, true, ErrSubtasksOpen},
+		{"open subtasks allowed", withSubtasks, models.StatusDone, false, nil},
+		{"already done", models.Task{Status: models.StatusDone, Blocked: true}, models.StatusDone, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machine := Default()
+			machine.RequireSubtasksDone = tt.requireSubtasks
+			err := machine.CheckCompletion(tt.task, tt.to)
+			if !errors.Is(err, tt.want) || (tt.want == nil) != (err == nil) {
+				t.Errorf("CheckCompletion() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+// Package blob stores opaque binary objects, such as task attachments, under
+// string keys. Metadata lives elsewhere; a Store only moves bytes.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrNotFound is returned when no object is stored under a key.
+var ErrNotFound = errors.New("blob not found")
+
+// ErrInvalidKey is returned for keys that are empty, absolute or contain
+// "." or ".." segments.
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// Info describes an object being stored. Size must be exact; SHA256 is the
+// hex-encoded digest of the content and may be used by a Store to sign or
+// verify the upload.
+type Info struct {
+	Size        int64
+	ContentType string
+	SHA256      string
+}
+
+// Store is a blob storage backend.
+type Store interface {
+	// Put stores exactly info.Size bytes from body under key, replacing any
+	// existing object.
+	Put(ctx context.Context, key string, body io.Reader, info Info) error
+
+	// Get streams length bytes of the object starting at offset. A negative
+	// length reads to the end of the object.
+	Get(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+
+	// Delete removes the object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// checkKey rejects keys that could escape the store's namespace.
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return ErrInvalidKey
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PlaceholderSecret is the development-only signing key. It must never be used
+// outside dev mode.
+const PlaceholderSecret = "your-secret-key"
+
+// Storage backends selectable through STORAGE_BACKEND
+const (
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory"
+)
+
+// Blob backends selectable through BLOB_BACKEND
+const (
+	BlobLocal = "local"
+	BlobS3    = "s3"
+)
+
+type Config struct {
+	Env     string
+	Port    string
+	DB      string
+	Storage string
+	JWT     JWTConfig
+
+	// MigrateOnStart applies pending schema migrations before serving.
+	MigrateOnStart bool
+
+	// RowLevelSecurity makes organization-scoped queries set app.org_id so
+	// that the Postgres row-level security policies enforce tenant isolation
+	// as well. It pins a connection per repository call. When it is off the
+	// service connects with app.bypass_rls on, since the policies deny every
+	// row by default.
+	RowLevelSecurity bool
+
+	// WorkflowFile optionally points to a JSON list of allowed task status
+	// transitions; the built-in workflow is used when empty.
+	WorkflowFile string
+
+	// RequireSubtasksDone keeps tasks from being marked done while they
+	// have open subtasks.
+	RequireSubtasksDone bool
+
+	// AllowOrgSignup lets anonymous callers found a new organization, and
+	// become its admin, by naming it when they register; everyone else then
+	// needs an invitation. Otherwise everyone without one signs up into the
+	// default organization as a plain user.
+	AllowOrgSignup bool
+
+	// MaxBodyBytes caps the size of JSON request bodies.
+	MaxBodyBytes int64
+
+	Blob        BlobConfig
+	Attachments AttachmentConfig
+}
+
+// DefaultMaxBodyBytes is used when MAX_BODY_BYTES is not set.
+const DefaultMaxBodyBytes = 1 << 20
+
+// BlobConfig selects where attachment content is stored. Dir is used by the
+// local backend, the S3 fields by the s3 backend; S3Endpoint may point at any
+// S3-compatible service such as a local MinIO.
+type BlobConfig struct {
+	Backend           string
+	Dir               string
+	S3Endpoint        string
+	S3Region          string
+	S3Bucket          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3PathStyle       bool
+}
+
+// AttachmentConfig limits uploads. AllowedTypes holds media types without
+// parameters, matched against the sniffed type of the content.
+type AttachmentConfig struct {
+	MaxBytes     int64
+	AllowedTypes []string
+}
+
+// DefaultMaxAttachmentBytes is used when ATTACHMENT_MAX_BYTES is not set.
+const DefaultMaxAttachmentBytes = 25 << 20
+
+// DefaultAttachmentTypes is used when ATTACHMENT_TYPES is not set: images,
+// plain text (which covers logs, JSON and Markdown), PDFs and archives.
+var DefaultAttachmentTypes = []string{
+	"image/png", "image/jpeg", "image/gif", "image/webp",
+	"text/plain", "text/csv", "application/pdf",
+	"application/zip", "application/x-gzip",
+}
+
+// JWTConfig describes how access tokens are signed and verified. Keys maps a
+// key ID (sent as the "kid" header) to its HMAC secret; ActiveKID selects the
+// key used for signing new tokens while the others remain valid for
+// verification until they are removed.
+type JWTConfig struct {
+	Keys       map[string][]byte
+	ActiveKID  string
+	TTL        time.Duration
+	RefreshTTL time.Duration
+	Issuer     string
+	Audience   string
+}
+
+// Load reads configuration from the environment (after .env has been loaded).
+func Load() (*Config, error) {
+	cfg := &Config{
+		Env:  strings.ToLower(getEnv("APP_ENV", "production")),
+		Port: os.Getenv("PORT"),
+		DB:   os.Getenv("DB_URL"),
+
+		Storage: strings.ToLower(getEnv("STORAGE_BACKEND", StoragePostgres)),
+
+		MigrateOnStart:   getEnv("MIGRATE_ON_START", "true") == "true",
+		RowLevelSecurity: getEnv("DB_ROW_LEVEL_SECURITY", "false") == "true",
+		WorkflowFile:     os.Getenv("TASK_WORKFLOW_FILE"),
+
+		RequireSubtasksDone: getEnv("TASK_REQUIRE_SUBTASKS_DONE", "false") == "true",
+		AllowOrgSignup:      getEnv("ALLOW_ORG_SIGNUP", "false") == "true",
+	}
+	maxBody, err := strconv.ParseInt(getEnv("MAX_BODY_BYTES", strconv.Itoa(DefaultMaxBodyBytes)), 10, 64)
+	if err != nil || maxBody <= 0 {
+		return nil, fmt.Errorf("invalid MAX_BODY_BYTES: %q", os.Getenv("MAX_BODY_BYTES"))
+	}
+	cfg.MaxBodyBytes = maxBody
+
+	blobCfg, err := loadBlob()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Blob = blobCfg
+
+	attachmentCfg, err := loadAttachments()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Attachments = attachmentCfg
+
+	if cfg.Storage != StoragePostgres && cfg.Storage != StorageMemory {
+		return nil, fmt.Errorf("invalid STORAGE_BACKEND %q: expected %q or %q", cfg.Storage, StoragePostgres, StorageMemory)
+	}
+
+	jwtCfg, err := loadJWT()
+	if err != nil {
+		return nil, err
+	}
+	cfg.JWT = jwtCfg
+
+	return cfg, nil
+}
+
+// IsDev reports whether the service runs in development mode.
+func (c *Config) IsDev() bool {
+	return c.Env == "dev" || c.Env == "development" || c.Env == "local"
+}
+
+// Validate refuses insecure settings outside dev mode.
+func (c *Config) Validate() error {
+	if c.IsDev() {
+		return nil
+	}
+	for kid, key := range c.JWT.Keys {
+		if string(key) == PlaceholderSecret {
+			return fmt.Errorf("JWT key %q uses the placeholder secret; set JWT_SECRET or JWT_KEYS, or run with APP_ENV=dev", kid)
+		}
+		if len(key) < 32 {
+			return fmt.Errorf("JWT key %q is too short: need at least 32 bytes", kid)
+		}
+	}
+	return nil
+}
+
+func loadJWT() (JWTConfig, error) {
+	cfg := JWTConfig{
+		Keys:     map[string][]byte{},
+		Issuer:   os.Getenv("JWT_ISSUER"),
+		Audience: os.Getenv("JWT_AUDIENCE"),
+	}
+
+	ttl, err := time.ParseDuration(getEnv("JWT_TTL", "15m"))
+	if err != nil || ttl <= 0 {
+		return cfg, fmt.Errorf("invalid JWT_TTL: %q", os.Getenv("JWT_TTL"))
+	}
+	cfg.TTL = ttl
+
+	refreshTTL, err := time.ParseDuration(getEnv("REFRESH_TOKEN_TTL", "720h"))
+	if err != nil || refreshTTL <= 0 {
+		return cfg, fmt.Errorf("invalid REFRESH_TOKEN_TTL: %q", os.Getenv("REFRESH_TOKEN_TTL"))
+	}
+	cfg.RefreshTTL = refreshTTL
+
+	// JWT_KEYS holds rotated keys as "kid1:secret1,kid2:secret2"
+	if raw := os.Getenv("JWT_KEYS"); raw != "" {
+		for _, pair := range strings.Split(raw, ",") {
+			kid, secret, found := strings.Cut(strings.TrimSpace(pair), ":")
+			if !found || kid == "" || secret == "" {
+				return cfg, fmt.Errorf("invalid JWT_KEYS entry %q: expected kid:secret", pair)
+			}
+			cfg.Keys[kid] = []byte(secret)
+		}
+		cfg.ActiveKID = os.Getenv("JWT_ACTIVE_KID")
+		if cfg.ActiveKID == "" {
+			return cfg, fmt.Errorf("JWT_ACTIVE_KID is required when JWT_KEYS is set")
+		}
+		if _, ok := cfg.Keys[cfg.ActiveKID]; !ok {
+			return cfg, fmt.Errorf("JWT_ACTIVE_KID %q is not present in JWT_KEYS", cfg.ActiveKID)
+		}
+		return cfg, nil
+	}
+
+	// Single-key setup
+	cfg.ActiveKID = getEnv("JWT_ACTIVE_KID", "default")
+	cfg.Keys[cfg.ActiveKID] = []byte(getEnv("JWT_SECRET", PlaceholderSecret))
+	return cfg, nil
+}
+
+func loadBlob() (BlobConfig, error) {
+	cfg := BlobConfig{
+		Backend:           strings.ToLower(getEnv("BLOB_BACKEND", BlobLocal)),
+		Dir:               getEnv("BLOB_DIR", "data/attachments"),
+		S3Endpoint:        os.Getenv("S3_ENDPOINT"),
+		S3Region:          getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:          os.Getenv("S3_BUCKET"),
+		S3AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+		S3SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		S3PathStyle:       getEnv("S3_PATH_STYLE", "false") == "true",
+	}
+	switch cfg.Backend {
+	case BlobLocal:
+	case BlobS3:
+		if cfg.S3Bucket == "" || cfg.S3AccessKeyID == "" || cfg.S3SecretAccessKey == "" {
+			return cfg, fmt.Errorf("BLOB_BACKEND=s3 requires S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY")
+		}
+	default:
+		return cfg, fmt.Errorf("invalid BLOB_BACKEND %q: expected %q or %q", cfg.Backend, BlobLocal, BlobS3)
+	}
+	return cfg, nil
+}
+
+func loadAttachments() (AttachmentConfig, error) {
+	cfg := AttachmentConfig{AllowedTypes: DefaultAttachmentTypes}
+
+	maxBytes, err := strconv.ParseInt(getEnv("ATTACHMENT_MAX_BYTES", strconv.Itoa(DefaultMaxAttachmentBytes)), 10, 64)
+	if err != nil || maxBytes <= 0 {
+		return cfg, fmt.Errorf("invalid ATTACHMENT_MAX_BYTES: %q", os.Getenv("ATTACHMENT_MAX_BYTES"))
+	}
+	cfg.MaxBytes = maxBytes
+
+	if raw := os.Getenv("ATTACHMENT_TYPES"); raw != "" {
+		cfg.AllowedTypes = nil
+		for _, mediaType := range strings.Split(raw, ",") {
+			if mediaType = strings.ToLower(strings.TrimSpace(mediaType)); mediaType != "" {
+				cfg.AllowedTypes = append(cfg.AllowedTypes, mediaType)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
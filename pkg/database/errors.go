@@ -0,0 +1,55 @@
+package database
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/naveeshkumar24/internal/models"
+)
+
+// Postgres error codes mapped by translateError.
+const (
+	pqUniqueViolation     = "23505"
+	pqForeignKeyViolation = "23503"
+	pqCheckViolation      = "23514"
+	pqNotNullViolation    = "23502"
+)
+
+// keyDetail extracts the columns from details like
+// `Key (email)=(a@example.com) already exists.` Keys scoped to an
+// organization name org_id first, e.g. `Key (org_id, key)=(2, OPS)`.
+var keyDetail = regexp.MustCompile(`^Key \(([^)]+)\)=`)
+
+// translateError turns constraint violations reported by Postgres into a
+// models.ConstraintError, so that callers never need to know about pq.
+// Other errors are returned unchanged.
+func translateError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	var kind error
+	switch pqErr.Code {
+	case pqUniqueViolation:
+		kind = models.ErrDuplicate
+	case pqForeignKeyViolation:
+		kind = models.ErrReferenceNotFound
+	case pqCheckViolation, pqNotNullViolation:
+		kind = models.ErrConstraintViolation
+	default:
+		return err
+	}
+
+	field := pqErr.Column
+	if m := keyDetail.FindStringSubmatch(pqErr.Detail); m != nil {
+		columns := strings.Split(m[1], ", ")
+		field = columns[len(columns)-1]
+	}
+	if field == "" {
+		field = pqErr.Constraint
+	}
+	return &models.ConstraintError{Err: kind, Field: field, Cause: err}
+}
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS users (
+	id SERIAL PRIMARY KEY,
+	username VARCHAR(100) NOT NULL UNIQUE,
+	email VARCHAR(255) NOT NULL UNIQUE,
+	password TEXT NOT NULL,
+	role VARCHAR(50) NOT NULL DEFAULT 'user'
+);
+
+CREATE TABLE IF NOT EXISTS tasks (
+	id SERIAL PRIMARY KEY,
+	title VARCHAR(255) NOT NULL,
+	description TEXT,
+	due_date DATE,
+	priority VARCHAR(50),
+	status VARCHAR(50),
+	created_by INT REFERENCES users(id),
+	assigned_to INT REFERENCES users(id),
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
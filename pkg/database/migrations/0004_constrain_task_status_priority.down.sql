@@ -0,0 +1,7 @@
+ALTER TABLE tasks
+	DROP CONSTRAINT IF EXISTS tasks_status_check,
+	DROP CONSTRAINT IF EXISTS tasks_priority_check,
+	ALTER COLUMN status DROP NOT NULL,
+	ALTER COLUMN status DROP DEFAULT,
+	ALTER COLUMN priority DROP NOT NULL,
+	ALTER COLUMN priority DROP DEFAULT;
@@ -0,0 +1,15 @@
+-- Fold legacy spellings into the canonical values before constraining them
+UPDATE tasks SET status = lower(trim(status)) WHERE status IS NOT NULL;
+UPDATE tasks SET status = 'in-progress' WHERE status IN ('in_progress', 'in progress', 'inprogress');
+UPDATE tasks SET status = 'todo' WHERE status IS NULL OR status NOT IN ('todo', 'in-progress', 'done');
+
+UPDATE tasks SET priority = lower(trim(priority)) WHERE priority IS NOT NULL;
+UPDATE tasks SET priority = 'medium' WHERE priority IS NULL OR priority NOT IN ('low', 'medium', 'high');
+
+ALTER TABLE tasks
+	ALTER COLUMN status SET DEFAULT 'todo',
+	ALTER COLUMN status SET NOT NULL,
+	ALTER COLUMN priority SET DEFAULT 'medium',
+	ALTER COLUMN priority SET NOT NULL,
+	ADD CONSTRAINT tasks_status_check CHECK (status IN ('todo', 'in-progress', 'done')),
+	ADD CONSTRAINT tasks_priority_check CHECK (priority IN ('low', 'medium', 'high'));
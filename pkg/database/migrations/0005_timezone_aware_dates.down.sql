@@ -0,0 +1,9 @@
+ALTER TABLE users DROP COLUMN IF EXISTS timezone;
+
+ALTER TABLE tasks
+	DROP COLUMN IF EXISTS due_has_time,
+	ALTER COLUMN created_at DROP NOT NULL,
+	ALTER COLUMN updated_at DROP NOT NULL,
+	ALTER COLUMN due_date TYPE DATE USING (due_date AT TIME ZONE 'UTC')::date,
+	ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC',
+	ALTER COLUMN updated_at TYPE TIMESTAMP USING updated_at AT TIME ZONE 'UTC';
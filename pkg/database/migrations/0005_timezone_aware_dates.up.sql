@@ -0,0 +1,15 @@
+-- Existing timestamps were written in the database session zone; treat them as UTC
+ALTER TABLE tasks
+	ALTER COLUMN due_date TYPE TIMESTAMPTZ USING due_date::timestamp AT TIME ZONE 'UTC',
+	ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+	ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC',
+	ADD COLUMN IF NOT EXISTS due_has_time BOOLEAN NOT NULL DEFAULT false;
+
+UPDATE tasks SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
+UPDATE tasks SET updated_at = created_at WHERE updated_at IS NULL;
+
+ALTER TABLE tasks
+	ALTER COLUMN created_at SET NOT NULL,
+	ALTER COLUMN updated_at SET NOT NULL;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
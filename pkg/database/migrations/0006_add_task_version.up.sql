@@ -0,0 +1,2 @@
+-- Incremented on every write so that clients can detect concurrent edits
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
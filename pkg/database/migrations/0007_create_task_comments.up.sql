@@ -0,0 +1,19 @@
+-- Deleting a task or a comment removes its replies and mentions with it
+CREATE TABLE IF NOT EXISTS task_comments (
+	id SERIAL PRIMARY KEY,
+	task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+	parent_id INT REFERENCES task_comments(id) ON DELETE CASCADE,
+	author_id INT NOT NULL REFERENCES users(id),
+	body TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS task_comments_task_idx ON task_comments (task_id, parent_id, id);
+CREATE INDEX IF NOT EXISTS task_comments_parent_idx ON task_comments (parent_id, id);
+
+CREATE TABLE IF NOT EXISTS comment_mentions (
+	comment_id INT NOT NULL REFERENCES task_comments(id) ON DELETE CASCADE,
+	user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	PRIMARY KEY (comment_id, user_id)
+);
@@ -0,0 +1,15 @@
+-- Attachment content lives in blob storage; this table only holds metadata.
+-- Deleting a task removes its rows but leaves the blobs behind.
+CREATE TABLE IF NOT EXISTS task_attachments (
+	id SERIAL PRIMARY KEY,
+	task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+	filename VARCHAR(255) NOT NULL,
+	size BIGINT NOT NULL CHECK (size >= 0),
+	content_type VARCHAR(255) NOT NULL,
+	sha256 CHAR(64) NOT NULL,
+	storage_key VARCHAR(512) NOT NULL UNIQUE,
+	uploaded_by INT NOT NULL REFERENCES users(id),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS task_attachments_task_idx ON task_attachments (task_id, id);
@@ -0,0 +1,19 @@
+-- task_counter hands out the numbers of task keys; it is incremented in the
+-- same transaction that inserts the task, so keys are gapless and unique.
+CREATE TABLE IF NOT EXISTS projects (
+	id SERIAL PRIMARY KEY,
+	key VARCHAR(10) NOT NULL UNIQUE,
+	name VARCHAR(255) NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	created_by INT REFERENCES users(id),
+	task_counter INT NOT NULL DEFAULT 0,
+	archived_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Existing tasks stay outside any project
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS project_id INT REFERENCES projects(id);
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS task_key VARCHAR(32) UNIQUE;
+
+CREATE INDEX IF NOT EXISTS tasks_project_idx ON tasks (project_id);
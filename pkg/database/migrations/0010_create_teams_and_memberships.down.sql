@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS project_invitations;
+DROP TABLE IF EXISTS project_teams;
+DROP TABLE IF EXISTS project_members;
+DROP TABLE IF EXISTS team_members;
+DROP TABLE IF EXISTS teams;
@@ -0,0 +1,50 @@
+CREATE TABLE IF NOT EXISTS teams (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(100) NOT NULL UNIQUE,
+	description TEXT NOT NULL DEFAULT '',
+	created_by INT REFERENCES users(id),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS team_members (
+	team_id INT NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+	user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	PRIMARY KEY (team_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS team_members_user_idx ON team_members (user_id);
+
+CREATE TABLE IF NOT EXISTS project_members (
+	project_id INT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+	user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	role VARCHAR(20) NOT NULL CHECK (role IN ('admin', 'manager', 'user')),
+	PRIMARY KEY (project_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS project_members_user_idx ON project_members (user_id);
+
+CREATE TABLE IF NOT EXISTS project_teams (
+	project_id INT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+	team_id INT NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+	role VARCHAR(20) NOT NULL CHECK (role IN ('admin', 'manager', 'user')),
+	PRIMARY KEY (project_id, team_id)
+);
+
+CREATE TABLE IF NOT EXISTS project_invitations (
+	id SERIAL PRIMARY KEY,
+	project_id INT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+	email VARCHAR(255) NOT NULL,
+	role VARCHAR(20) NOT NULL CHECK (role IN ('admin', 'manager', 'user')),
+	token_hash CHAR(64) NOT NULL UNIQUE,
+	invited_by INT REFERENCES users(id),
+	expires_at TIMESTAMPTZ NOT NULL,
+	accepted_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS project_invitations_project_idx ON project_invitations (project_id);
+
+-- Creators of existing projects become their first admins
+INSERT INTO project_members (project_id, user_id, role)
+SELECT id, created_by, 'admin' FROM projects WHERE created_by IS NOT NULL
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,40 @@
+DROP POLICY IF EXISTS org_isolation ON team_members;
+DROP POLICY IF EXISTS org_isolation ON project_invitations;
+DROP POLICY IF EXISTS org_isolation ON project_teams;
+DROP POLICY IF EXISTS org_isolation ON project_members;
+DROP POLICY IF EXISTS org_isolation ON task_attachments;
+DROP POLICY IF EXISTS org_isolation ON task_comments;
+DROP POLICY IF EXISTS org_isolation ON teams;
+DROP POLICY IF EXISTS org_isolation ON tasks;
+DROP POLICY IF EXISTS org_isolation ON projects;
+DROP POLICY IF EXISTS org_isolation ON users;
+
+ALTER TABLE team_members NO FORCE ROW LEVEL SECURITY, DISABLE ROW LEVEL SECURITY;
+ALTER TABLE project_invitations NO FORCE ROW LEVEL SECURITY, DISABLE ROW LEVEL SECURITY;
+ALTER TABLE project_teams NO FORCE ROW LEVEL SECURITY, DISABLE ROW LEVEL SECURITY;
+ALTER TABLE project_members NO FORCE ROW LEVEL SECURITY, DISABLE ROW LEVEL SECURITY;
+ALTER TABLE task_attachments NO FORCE ROW LEVEL SECURITY, DISABLE ROW LEVEL SECURITY;
+ALTER TABLE task_comments NO FORCE ROW LEVEL SECURITY, DISABLE ROW LEVEL SECURITY;
+ALTER TABLE teams NO FORCE ROW LEVEL SECURITY, DISABLE ROW LEVEL SECURITY;
+ALTER TABLE tasks NO FORCE ROW LEVEL SECURITY, DISABLE ROW LEVEL SECURITY;
+ALTER TABLE projects NO FORCE ROW LEVEL SECURITY, DISABLE ROW LEVEL SECURITY;
+ALTER TABLE users NO FORCE ROW LEVEL SECURITY, DISABLE ROW LEVEL SECURITY;
+
+DROP FUNCTION IF EXISTS current_org_id();
+
+-- Keys and names become globally unique again; this fails if two
+-- organizations have since reused one
+ALTER TABLE teams DROP CONSTRAINT IF EXISTS teams_org_id_name_key;
+ALTER TABLE teams ADD CONSTRAINT teams_name_key UNIQUE (name);
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_org_id_task_key_key;
+ALTER TABLE tasks ADD CONSTRAINT tasks_task_key_key UNIQUE (task_key);
+ALTER TABLE projects DROP CONSTRAINT IF EXISTS projects_org_id_key_key;
+ALTER TABLE projects ADD CONSTRAINT projects_key_key UNIQUE (key);
+
+-- Dropping org_id also drops the composite keys and indexes built on it
+ALTER TABLE teams DROP COLUMN IF EXISTS org_id;
+ALTER TABLE tasks DROP COLUMN IF EXISTS org_id;
+ALTER TABLE projects DROP COLUMN IF EXISTS org_id;
+ALTER TABLE users DROP COLUMN IF EXISTS org_id;
+
+DROP TABLE IF EXISTS organizations;
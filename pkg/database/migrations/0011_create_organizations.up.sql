@@ -0,0 +1,109 @@
+-- Every user, project, task and team belongs to exactly one organization.
+-- Existing rows move into organization 1.
+CREATE TABLE IF NOT EXISTS organizations (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO organizations (id, name) VALUES (1, 'Default') ON CONFLICT (id) DO NOTHING;
+SELECT setval(pg_get_serial_sequence('organizations', 'id'), (SELECT MAX(id) FROM organizations));
+
+ALTER TABLE users ADD COLUMN org_id INT NOT NULL DEFAULT 1 REFERENCES organizations(id);
+ALTER TABLE projects ADD COLUMN org_id INT NOT NULL DEFAULT 1 REFERENCES organizations(id);
+ALTER TABLE tasks ADD COLUMN org_id INT NOT NULL DEFAULT 1 REFERENCES organizations(id);
+ALTER TABLE teams ADD COLUMN org_id INT NOT NULL DEFAULT 1 REFERENCES organizations(id);
+
+ALTER TABLE users ALTER COLUMN org_id DROP DEFAULT;
+ALTER TABLE projects ALTER COLUMN org_id DROP DEFAULT;
+ALTER TABLE tasks ALTER COLUMN org_id DROP DEFAULT;
+ALTER TABLE teams ALTER COLUMN org_id DROP DEFAULT;
+
+CREATE INDEX IF NOT EXISTS users_org_idx ON users (org_id);
+CREATE INDEX IF NOT EXISTS tasks_org_idx ON tasks (org_id);
+
+-- Keys and names only need to be unique within an organization
+ALTER TABLE projects DROP CONSTRAINT projects_key_key;
+ALTER TABLE projects ADD CONSTRAINT projects_org_id_key_key UNIQUE (org_id, key);
+ALTER TABLE tasks DROP CONSTRAINT tasks_task_key_key;
+ALTER TABLE tasks ADD CONSTRAINT tasks_org_id_task_key_key UNIQUE (org_id, task_key);
+ALTER TABLE teams DROP CONSTRAINT teams_name_key;
+ALTER TABLE teams ADD CONSTRAINT teams_org_id_name_key UNIQUE (org_id, name);
+
+-- Composite keys let rows refer only to users and projects of their own
+-- organization. NULL references are not checked, as before.
+ALTER TABLE users ADD CONSTRAINT users_org_id_id_key UNIQUE (org_id, id);
+ALTER TABLE projects ADD CONSTRAINT projects_org_id_id_key UNIQUE (org_id, id);
+
+ALTER TABLE projects ADD CONSTRAINT projects_org_created_by_fkey
+	FOREIGN KEY (org_id, created_by) REFERENCES users (org_id, id);
+ALTER TABLE tasks ADD CONSTRAINT tasks_org_created_by_fkey
+	FOREIGN KEY (org_id, created_by) REFERENCES users (org_id, id);
+ALTER TABLE tasks ADD CONSTRAINT tasks_org_assigned_to_fkey
+	FOREIGN KEY (org_id, assigned_to) REFERENCES users (org_id, id);
+ALTER TABLE tasks ADD CONSTRAINT tasks_org_project_id_fkey
+	FOREIGN KEY (org_id, project_id) REFERENCES projects (org_id, id);
+ALTER TABLE teams ADD CONSTRAINT teams_org_created_by_fkey
+	FOREIGN KEY (org_id, created_by) REFERENCES users (org_id, id);
+
+-- Row-level security backs up the org_id conditions of the queries. The
+-- policies compare rows with the app.org_id setting, which the service sets
+-- on its connection for organization-scoped queries when
+-- DB_ROW_LEVEL_SECURITY is enabled. Sessions that leave it unset, such as
+-- migrations and sign-in, see every row.
+CREATE OR REPLACE FUNCTION current_org_id() RETURNS INT
+LANGUAGE sql STABLE AS $$
+	SELECT NULLIF(current_setting('app.org_id', true), '')::int
+$$;
+
+ALTER TABLE users ENABLE ROW LEVEL SECURITY;
+ALTER TABLE users FORCE ROW LEVEL SECURITY;
+CREATE POLICY org_isolation ON users
+	USING (current_org_id() IS NULL OR org_id = current_org_id());
+
+ALTER TABLE projects ENABLE ROW LEVEL SECURITY;
+ALTER TABLE projects FORCE ROW LEVEL SECURITY;
+CREATE POLICY org_isolation ON projects
+	USING (current_org_id() IS NULL OR org_id = current_org_id());
+
+ALTER TABLE tasks ENABLE ROW LEVEL SECURITY;
+ALTER TABLE tasks FORCE ROW LEVEL SECURITY;
+CREATE POLICY org_isolation ON tasks
+	USING (current_org_id() IS NULL OR org_id = current_org_id());
+
+ALTER TABLE teams ENABLE ROW LEVEL SECURITY;
+ALTER TABLE teams FORCE ROW LEVEL SECURITY;
+CREATE POLICY org_isolation ON teams
+	USING (current_org_id() IS NULL OR org_id = current_org_id());
+
+-- Tables without org_id follow the row they belong to; the subqueries are
+-- themselves filtered by the policies above.
+ALTER TABLE task_comments ENABLE ROW LEVEL SECURITY;
+ALTER TABLE task_comments FORCE ROW LEVEL SECURITY;
+CREATE POLICY org_isolation ON task_comments
+	USING (EXISTS (SELECT 1 FROM tasks t WHERE t.id = task_id));
+
+ALTER TABLE task_attachments ENABLE ROW LEVEL SECURITY;
+ALTER TABLE task_attachments FORCE ROW LEVEL SECURITY;
+CREATE POLICY org_isolation ON task_attachments
+	USING (EXISTS (SELECT 1 FROM tasks t WHERE t.id = task_id));
+
+ALTER TABLE project_members ENABLE ROW LEVEL SECURITY;
+ALTER TABLE project_members FORCE ROW LEVEL SECURITY;
+CREATE POLICY org_isolation ON project_members
+	USING (EXISTS (SELECT 1 FROM projects p WHERE p.id = project_id));
+
+ALTER TABLE project_teams ENABLE ROW LEVEL SECURITY;
+ALTER TABLE project_teams FORCE ROW LEVEL SECURITY;
+CREATE POLICY org_isolation ON project_teams
+	USING (EXISTS (SELECT 1 FROM projects p WHERE p.id = project_id));
+
+ALTER TABLE project_invitations ENABLE ROW LEVEL SECURITY;
+ALTER TABLE project_invitations FORCE ROW LEVEL SECURITY;
+CREATE POLICY org_isolation ON project_invitations
+	USING (EXISTS (SELECT 1 FROM projects p WHERE p.id = project_id));
+
+ALTER TABLE team_members ENABLE ROW LEVEL SECURITY;
+ALTER TABLE team_members FORCE ROW LEVEL SECURITY;
+CREATE POLICY org_isolation ON team_members
+	USING (EXISTS (SELECT 1 FROM teams t WHERE t.id = team_id));
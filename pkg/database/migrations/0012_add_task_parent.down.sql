@@ -0,0 +1,5 @@
+DROP FUNCTION IF EXISTS task_descendants(INT);
+
+-- Dropping the column also drops its foreign key, check and index
+ALTER TABLE tasks DROP COLUMN IF EXISTS parent_id;
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_org_id_id_key;
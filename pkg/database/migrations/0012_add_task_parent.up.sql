@@ -0,0 +1,24 @@
+-- A task may be a subtask of another task of its organization. The service
+-- keeps subtasks in the project of their parent and the hierarchy free of
+-- cycles; a task cannot be deleted while it has subtasks.
+ALTER TABLE tasks ADD CONSTRAINT tasks_org_id_id_key UNIQUE (org_id, id);
+
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS parent_id INT;
+ALTER TABLE tasks ADD CONSTRAINT tasks_org_parent_id_fkey
+	FOREIGN KEY (org_id, parent_id) REFERENCES tasks (org_id, id);
+ALTER TABLE tasks ADD CONSTRAINT tasks_parent_id_check CHECK (parent_id <> id);
+
+CREATE INDEX IF NOT EXISTS tasks_parent_idx ON tasks (parent_id);
+
+-- task_descendants returns every task below root with its depth, 1 for the
+-- direct subtasks. Reads use it to roll up progress.
+CREATE OR REPLACE FUNCTION task_descendants(root INT)
+RETURNS TABLE (id INT, status VARCHAR, depth INT)
+LANGUAGE sql STABLE AS $$
+	WITH RECURSIVE subtree AS (
+		SELECT t.id, t.status, 1 AS depth FROM tasks t WHERE t.parent_id = root
+		UNION ALL
+		SELECT t.id, t.status, s.depth + 1 FROM tasks t JOIN subtree s ON t.parent_id = s.id
+	)
+	SELECT s.id, s.status, s.depth FROM subtree s
+$$;
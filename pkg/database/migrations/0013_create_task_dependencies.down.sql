@@ -0,0 +1,2 @@
+DROP FUNCTION IF EXISTS task_blockers(INT);
+DROP TABLE IF EXISTS task_dependencies;
@@ -0,0 +1,33 @@
+-- A row means task_id is blocked by depends_on_id. Both tasks belong to the
+-- organization of the row; the service keeps them in one project and the
+-- graph free of cycles. Deleting either task removes the dependency.
+CREATE TABLE IF NOT EXISTS task_dependencies (
+	task_id INT NOT NULL,
+	depends_on_id INT NOT NULL,
+	org_id INT NOT NULL REFERENCES organizations(id),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (task_id, depends_on_id),
+	CHECK (task_id <> depends_on_id),
+	FOREIGN KEY (org_id, task_id) REFERENCES tasks (org_id, id) ON DELETE CASCADE,
+	FOREIGN KEY (org_id, depends_on_id) REFERENCES tasks (org_id, id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS task_dependencies_depends_on_idx ON task_dependencies (depends_on_id);
+
+ALTER TABLE task_dependencies ENABLE ROW LEVEL SECURITY;
+ALTER TABLE task_dependencies FORCE ROW LEVEL SECURITY;
+CREATE POLICY org_isolation ON task_dependencies
+	USING (current_org_id() IS NULL OR org_id = current_org_id());
+
+-- task_blockers returns every task root waits for, directly or through
+-- other tasks.
+CREATE OR REPLACE FUNCTION task_blockers(root INT)
+RETURNS TABLE (id INT)
+LANGUAGE sql STABLE AS $$
+	WITH RECURSIVE blockers AS (
+		SELECT d.depends_on_id AS id FROM task_dependencies d WHERE d.task_id = root
+		UNION
+		SELECT d.depends_on_id FROM task_dependencies d JOIN blockers b ON d.task_id = b.id
+	)
+	SELECT b.id FROM blockers b
+$$;
@@ -0,0 +1,21 @@
+DROP POLICY IF EXISTS org_isolation ON task_dependencies;
+CREATE POLICY org_isolation ON task_dependencies
+	USING (current_org_id() IS NULL OR org_id = current_org_id());
+
+DROP POLICY IF EXISTS org_isolation ON teams;
+CREATE POLICY org_isolation ON teams
+	USING (current_org_id() IS NULL OR org_id = current_org_id());
+
+DROP POLICY IF EXISTS org_isolation ON tasks;
+CREATE POLICY org_isolation ON tasks
+	USING (current_org_id() IS NULL OR org_id = current_org_id());
+
+DROP POLICY IF EXISTS org_isolation ON projects;
+CREATE POLICY org_isolation ON projects
+	USING (current_org_id() IS NULL OR org_id = current_org_id());
+
+DROP POLICY IF EXISTS org_isolation ON users;
+CREATE POLICY org_isolation ON users
+	USING (current_org_id() IS NULL OR org_id = current_org_id());
+
+DROP FUNCTION IF EXISTS rls_bypassed();
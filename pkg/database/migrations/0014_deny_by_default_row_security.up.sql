@@ -0,0 +1,32 @@
+-- Row-level security denies by default: a session sees the rows of the
+-- organization in app.org_id and nothing else. Sessions that legitimately
+-- work across organizations, such as migrations, sign-in and token refresh,
+-- and every connection of a service running without DB_ROW_LEVEL_SECURITY,
+-- have to say so by setting app.bypass_rls to on.
+CREATE OR REPLACE FUNCTION rls_bypassed() RETURNS BOOLEAN
+LANGUAGE sql STABLE AS $$
+	SELECT COALESCE(current_setting('app.bypass_rls', true), '') = 'on'
+$$;
+
+DROP POLICY IF EXISTS org_isolation ON users;
+CREATE POLICY org_isolation ON users
+	USING (org_id = current_org_id() OR rls_bypassed());
+
+DROP POLICY IF EXISTS org_isolation ON projects;
+CREATE POLICY org_isolation ON projects
+	USING (org_id = current_org_id() OR rls_bypassed());
+
+DROP POLICY IF EXISTS org_isolation ON tasks;
+CREATE POLICY org_isolation ON tasks
+	USING (org_id = current_org_id() OR rls_bypassed());
+
+DROP POLICY IF EXISTS org_isolation ON teams;
+CREATE POLICY org_isolation ON teams
+	USING (org_id = current_org_id() OR rls_bypassed());
+
+DROP POLICY IF EXISTS org_isolation ON task_dependencies;
+CREATE POLICY org_isolation ON task_dependencies
+	USING (org_id = current_org_id() OR rls_bypassed());
+
+-- The policies of tables without org_id follow their parent row and need no
+-- change.
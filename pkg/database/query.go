@@ -5,95 +5,152 @@ import (
 	"fmt"
 	"log"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/naveeshkumar24/internal/models"
+	"github.com/naveeshkumar24/pkg/utils"
 )
 
+// Query runs the storage statements. A Query scoped to an organization
+// only reads and writes rows of that organization; the unscoped Query of
+// NewQuery (organization 0) sees no tenant rows at all, except that user
+// lookups by email and ID search every organization so that sign-in and
+// token refresh can learn which one a user belongs to.
 type Query struct {
-	db   *sql.DB
-	Time *time.Location
+	db    dbtx
+	orgID int
 }
 
+// NewQuery returns a Query across organizations, for sign-in, token refresh
+// and creating organizations. When row-level security is enabled it holds a
+// pooled connection that bypasses the policies from its first statement
+// until Close, which callers must always call.
 func NewQuery(db *sql.DB) *Query {
-	loc, err := time.LoadLocation("Asia/Kolkata")
+	if rowLevelSecurity {
+		return &Query{db: bypassSession(db)}
+	}
+	return &Query{db: pool{db}}
+}
+
+// NewOrgQuery returns a Query scoped to orgID. When row-level security is
+// enabled it holds a pooled connection from its first statement until
+// Close, which callers must always call.
+func NewOrgQuery(db *sql.DB, orgID int) *Query {
+	if orgID == 0 {
+		return NewQuery(db)
+	}
+	if rowLevelSecurity {
+		return &Query{db: orgSession(db, orgID), orgID: orgID}
+	}
+	return &Query{db: pool{db}, orgID: orgID}
+}
+
+// Close releases the connection of a Query running with row-level security
+// and does nothing otherwise.
+func (q *Query) Close() error {
+	if session, ok := q.db.(*session); ok {
+		return session.Close()
+	}
+	return nil
+}
+
+// inOrg is a condition matching rows whose column refers to a row of table
+// in the organization given by placeholder $arg.
+func inOrg(column, table string, arg int) string {
+	return fmt.Sprintf("%s IN (SELECT id FROM %s WHERE org_id = $%d)", column, table, arg)
+}
+
+// checkReference reports a ConstraintError on field unless table has a row
+// id in the Query's organization.
+func (q *Query) checkReference(table, field string, id int) error {
+	var exists bool
+	err := q.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM `+table+` WHERE id = $1 AND org_id = $2)`, id, q.orgID).Scan(&exists)
 	if err != nil {
-		log.Fatalf("Failed to load time zone: %v", err)
+		log.Printf("Failed to check %s reference: %v", field, err)
+		return err
 	}
-	return &Query{
-		db:   db,
-		Time: loc,
+	if !exists {
+		return &models.ConstraintError{Err: models.ErrReferenceNotFound, Field: field}
 	}
+	return nil
+}
+
+// ======================== Organization Functions ========================
+
+func (q *Query) GetOrganization(id int) (models.Organization, error) {
+	var org models.Organization
+	err := q.db.QueryRow(`SELECT id, name, created_at FROM organizations WHERE id = $1`, id).
+		Scan(&org.ID, &org.Name, &org.CreatedAt)
+	return org, err
 }
 
-func (q *Query) CreateTaskTables() error {
+// CreateOrganization creates an organization together with its first user,
+// who should be an admin, so that no organization is left without one.
+func (q *Query) CreateOrganization(org models.Organization, admin models.User) (models.Organization, models.User, error) {
 	tx, err := q.db.Begin()
 	if err != nil {
-		return err
+		return models.Organization{}, models.User{}, err
 	}
 	defer tx.Rollback()
 
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS users (
-			id SERIAL PRIMARY KEY,
-			username VARCHAR(100) NOT NULL UNIQUE,
-			email VARCHAR(255) NOT NULL UNIQUE,
-			password TEXT NOT NULL,
-			role VARCHAR(50) NOT NULL DEFAULT 'user'
-		)`,
-		`CREATE TABLE IF NOT EXISTS tasks (
-			id SERIAL PRIMARY KEY,
-			title VARCHAR(255) NOT NULL,
-			description TEXT,
-			due_date DATE,
-			priority VARCHAR(50),
-			status VARCHAR(50),
-			created_by INT REFERENCES users(id),
-			assigned_to INT REFERENCES users(id),
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)`,
-	}
-
-	for _, query := range queries {
-		if _, err := tx.Exec(query); err != nil {
-			log.Printf("Failed to execute query: %s", query)
-			return err
-		}
+	var created models.Organization
+	err = tx.QueryRow(`INSERT INTO organizations (name) VALUES ($1) RETURNING id, name, created_at`, org.Name).
+		Scan(&created.ID, &created.Name, &created.CreatedAt)
+	if err != nil {
+		log.Printf("Failed to create organization %s: %v", org.Name, err)
+		return models.Organization{}, models.User{}, translateError(err)
 	}
 
-	if err = tx.Commit(); err != nil {
-		return err
+	admin.OrgID = created.ID
+	err = tx.QueryRow(`
+		INSERT INTO users (username, email, password, role, timezone, org_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, admin.Username, admin.Email, admin.Password, admin.Role, admin.Timezone, created.ID).Scan(&admin.ID)
+	if err != nil {
+		log.Printf("Failed to register admin of organization %s: %v", org.Name, err)
+		return models.Organization{}, models.User{}, fmt.Errorf("error registering user: %w", translateError(err))
 	}
-	log.Println("User and Task tables created successfully.")
-	return nil
+
+	if err := tx.Commit(); err != nil {
+		return models.Organization{}, models.User{}, err
+	}
+	return created, admin, nil
 }
 
 // ======================== User Functions ========================
 
-func (q *Query) RegisterUser(user models.User) error {
+func (q *Query) RegisterUser(user models.User) (models.User, error) {
 	// Check if the username already exists
 
 	// Proceed to insert the new user if no duplicates were found
-	_, err := q.db.Exec(`
-        INSERT INTO users (username, email, password, role) 
-        VALUES ($1, $2, $3, $4)
-    `, user.Username, user.Email, user.Password, user.Role)
+	user.OrgID = q.orgID
+	err := q.db.QueryRow(`
+        INSERT INTO users (username, email, password, role, timezone, org_id)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id
+    `, user.Username, user.Email, user.Password, user.Role, user.Timezone, q.orgID).Scan(&user.ID)
 
 	if err != nil {
 		log.Printf("Failed to register user: %v", err)
-		return fmt.Errorf("error registering user: %w", err)
+		return models.User{}, fmt.Errorf("error registering user: %w", translateError(err))
 	}
 
-	return nil
+	return user, nil
 }
 
+// userColumns is the column list of the user lookups; the condition on $2
+// lets the unscoped Query find users of every organization.
+const userColumns = `id, username, email, password, role, timezone, org_id`
+
 func (q *Query) GetUserByEmail(email string) (models.User, error) {
 	var user models.User
 
 	err := q.db.QueryRow(`
-		SELECT id, username, email, password, role FROM users WHERE email = $1
-	`, email).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role)
+		SELECT `+userColumns+` FROM users WHERE email = $1 AND ($2 = 0 OR org_id = $2)
+	`, email, q.orgID).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role, &user.Timezone, &user.OrgID)
 
 	return user, err
 }
@@ -102,49 +159,350 @@ func (q *Query) GetUserByID(id int) (models.User, error) {
 	var user models.User
 
 	err := q.db.QueryRow(`
-		SELECT id, username, email, password, role FROM users WHERE id = $1
-	`, id).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role)
+		SELECT `+userColumns+` FROM users WHERE id = $1 AND ($2 = 0 OR org_id = $2)
+	`, id, q.orgID).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role, &user.Timezone, &user.OrgID)
 
 	return user, err
 }
 
+func (q *Query) UpdateUserRole(id int, role string) error {
+	res, err := q.db.Exec(`UPDATE users SET role = $1 WHERE id = $2 AND org_id = $3`, role, id, q.orgID)
+	if err != nil {
+		log.Printf("Failed to update role for user %d: %v", id, err)
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	log.Printf("User ID %d role changed to %s", id, role)
+	return nil
+}
+
+func (q *Query) UpdateUserTimezone(id int, timezone string) error {
+	res, err := q.db.Exec(`UPDATE users SET timezone = $1 WHERE id = $2 AND org_id = $3`, timezone, id, q.orgID)
+	if err != nil {
+		log.Printf("Failed to update timezone for user %d: %v", id, err)
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ======================== Refresh Token Functions ========================
+
+func (q *Query) CreateRefreshToken(token models.RefreshToken) error {
+	_, err := q.db.Exec(`
+		INSERT INTO refresh_tokens (user_id, token_hash, family_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`, token.UserID, token.TokenHash, token.FamilyID, token.ExpiresAt)
+	if err != nil {
+		log.Printf("Failed to store refresh token for user %d: %v", token.UserID, err)
+		return translateError(err)
+	}
+	return nil
+}
+
+func (q *Query) GetRefreshToken(tokenHash string) (models.RefreshToken, error) {
+	var token models.RefreshToken
+	var replacedBy sql.NullInt64
+	var revokedAt sql.NullTime
+
+	err := q.db.QueryRow(`
+		SELECT id, user_id, token_hash, family_id, expires_at, revoked_at, replaced_by, created_at
+		FROM refresh_tokens WHERE token_hash = $1
+	`, tokenHash).Scan(&token.ID, &token.UserID, &token.TokenHash, &token.FamilyID, &token.ExpiresAt,
+		&revokedAt, &replacedBy, &token.CreatedAt)
+	if err != nil {
+		return token, err
+	}
+
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	if replacedBy.Valid {
+		id := int(replacedBy.Int64)
+		token.ReplacedBy = &id
+	}
+	return token, nil
+}
+
+// RotateRefreshToken revokes the token identified by oldID and stores next in
+// its place. It fails with models.ErrTokenReused if oldID was already revoked,
+// which also covers two concurrent refreshes racing on the same token.
+func (q *Query) RotateRefreshToken(oldID int, next models.RefreshToken) error {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND revoked_at IS NULL
+	`, oldID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return models.ErrTokenReused
+	}
+
+	var nextID int
+	err = tx.QueryRow(`
+		INSERT INTO refresh_tokens (user_id, token_hash, family_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, next.UserID, next.TokenHash, next.FamilyID, next.ExpiresAt).Scan(&nextID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE refresh_tokens SET replaced_by = $1 WHERE id = $2`, nextID, oldID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (q *Query) RevokeTokenFamily(familyID string) error {
+	_, err := q.db.Exec(`
+		UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+		WHERE family_id = $1 AND revoked_at IS NULL
+	`, familyID)
+	if err != nil {
+		log.Printf("Failed to revoke token family %s: %v", familyID, err)
+		return err
+	}
+	return nil
+}
+
+func (q *Query) RevokeAllUserTokens(userID int) error {
+	_, err := q.db.Exec(`
+		UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID)
+	if err != nil {
+		log.Printf("Failed to revoke tokens for user %d: %v", userID, err)
+		return err
+	}
+	log.Printf("All refresh tokens revoked for user ID %d", userID)
+	return nil
+}
+
 // ======================== Task Functions ========================
 
-func (q *Query) CreateTask(task models.Task) error {
+// taskColumns is the column list scanned by scanTask.
+const taskColumns = `id, title, COALESCE(description, ''), due_date, due_has_time, priority, status,
+	COALESCE(created_by, 0), COALESCE(assigned_to, 0), created_at, updated_at, version,
+	COALESCE(project_id, 0), COALESCE(task_key, ''), COALESCE(parent_id, 0), org_id`
+
+// derivedTaskColumns computes the read-only fields of the task row of
+// table: its comment count, its subtasks, all and done, the tasks it depends
+// on and whether it is blocked. Reads select them after taskColumns and scan
+// them with scanDerivedTask. Both subtask counts come from one walk of the
+// subtree.
+func derivedTaskColumns(table string) string {
+	return fmt.Sprintf(`(SELECT COUNT(*) FROM task_comments tc WHERE tc.task_id = %[1]s.id),
+		(SELECT ARRAY[COUNT(*), COUNT(*) FILTER (WHERE d.status = 'done')] FROM task_descendants(%[1]s.id) d),
+		ARRAY(SELECT td.depends_on_id FROM task_dependencies td WHERE td.task_id = %[1]s.id ORDER BY td.depends_on_id),
+		%[1]s.status <> 'done' AND EXISTS (
+			SELECT 1 FROM task_dependencies td JOIN tasks b ON b.id = td.depends_on_id
+			WHERE td.task_id = %[1]s.id AND b.status <> 'done')`, table)
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTask scans taskColumns followed by any extra columns into task and extra.
+func scanTask(row rowScanner, task *models.Task, extra ...interface{}) error {
+	dest := []interface{}{&task.ID, &task.Title, &task.Description, &task.DueDate, &task.DueDate.HasTime,
+		&task.Priority, &task.Status, &task.CreatedBy, &task.AssignedTo, &task.CreatedAt, &task.UpdatedAt,
+		&task.Version, &task.ProjectID, &task.Key, &task.ParentID, &task.OrgID}
+	return row.Scan(append(dest, extra...)...)
+}
+
+// scanDerivedTask scans taskColumns, derivedTaskColumns and any extra
+// columns.
+func scanDerivedTask(row rowScanner, task *models.Task, extra ...interface{}) error {
+	var subtasks, blockedBy pq.Int64Array
+	derived := []interface{}{&task.CommentCount, &subtasks, &blockedBy, &task.Blocked}
+	if err := scanTask(row, task, append(derived, extra...)...); err != nil {
+		return err
+	}
+	var total, done int
+	if len(subtasks) == 2 {
+		total, done = int(subtasks[0]), int(subtasks[1])
+	}
+	task.Progress = models.NewSubtaskProgress(total, done)
+	task.BlockedBy = nil
+	for _, id := range blockedBy {
+		task.BlockedBy = append(task.BlockedBy, int(id))
+	}
+	return nil
+}
+
+// nullableID stores 0 as NULL so that optional user references do not
+// violate their foreign keys.
+func nullableID(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// CreateTask inserts the task and returns it as stored, with its generated
+// ID, timestamps and version. A task in a project takes the next number of
+// the project's counter for its key; the counter row stays locked until the
+// insert commits, so concurrent creates never share a key.
+func (q *Query) CreateTask(task models.Task) (models.Task, error) {
 	// Check if the user exists
-	var userCount int
-	err := q.db.QueryRow("SELECT COUNT(*) FROM users WHERE id = $1", task.CreatedBy).Scan(&userCount)
+	if err := q.checkReference("users", "created_by", task.CreatedBy); err != nil {
+		return models.Task{}, err
+	}
+
+	tx, err := q.db.Begin()
 	if err != nil {
-		log.Printf("Failed to check user existence: %v", err)
-		return err
+		return models.Task{}, err
 	}
+	defer tx.Rollback()
 
-	if userCount == 0 {
-		return fmt.Errorf("user with ID %d does not exist", task.CreatedBy)
+	var taskKey interface{}
+	if task.ProjectID != 0 {
+		key, err := nextTaskKey(tx, q.orgID, task.ProjectID)
+		if err != nil {
+			return models.Task{}, err
+		}
+		taskKey = key
+	}
+	if task.ParentID != 0 {
+		// A new subtask changes the progress of its parent
+		if err := lockTaskGraph(tx, q.orgID); err != nil {
+			return models.Task{}, err
+		}
+	}
+	if err := checkParent(tx, q.orgID, 0, task.ParentID, task.ProjectID); err != nil {
+		return models.Task{}, err
 	}
 
 	// Proceed with task insertion
-	_, err = q.db.Exec(`
-        INSERT INTO tasks (title, description, due_date, priority, status, created_by, assigned_to)
-        VALUES ($1, $2, $3, $4, $5, $6, $7)
-    `, task.Title, task.Description, task.DueDate, task.Priority, task.Status, task.CreatedBy, task.AssignedTo)
+	var created models.Task
+	err = scanTask(tx.QueryRow(`
+        INSERT INTO tasks (title, description, due_date, due_has_time, priority, status, created_by, assigned_to,
+            project_id, task_key, parent_id, org_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+        RETURNING `+taskColumns,
+		task.Title, task.Description, task.DueDate, task.DueDate.HasTime, task.Priority, task.Status,
+		task.CreatedBy, nullableID(task.AssignedTo), nullableID(task.ProjectID), taskKey, nullableID(task.ParentID), q.orgID), &created)
 
 	if err != nil {
 		log.Printf("Failed to create task: %v", err)
-		return err
+		return models.Task{}, translateError(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Task{}, err
 	}
 	log.Printf("Task %s created successfully.", task.Title)
+	return created, nil
+}
+
+// nextTaskKey advances the task counter of an unarchived project of the
+// organization and returns the resulting key, e.g. OPS-42.
+func nextTaskKey(tx *sql.Tx, orgID, projectID int) (string, error) {
+	var prefix string
+	var number int
+	err := tx.QueryRow(`
+		UPDATE projects SET task_counter = task_counter + 1
+		WHERE id = $1 AND org_id = $2 AND archived_at IS NULL
+		RETURNING key, task_counter
+	`, projectID, orgID).Scan(&prefix, &number)
+	if err == nil {
+		return fmt.Sprintf("%s-%d", prefix, number), nil
+	}
+	if err != sql.ErrNoRows {
+		return "", err
+	}
+
+	var exists bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM projects WHERE id = $1 AND org_id = $2)`, projectID, orgID).Scan(&exists); err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", &models.ConstraintError{Err: models.ErrReferenceNotFound, Field: "project_id"}
+	}
+	return "", models.ErrProjectArchived
+}
+
+// taskGraphLock is the first key of the transaction-level advisory lock that
+// serializes changes to the subtask hierarchy, the dependencies and the
+// statuses of an organization's tasks, the second key being the
+// organization ID.
+const taskGraphLock = 7271
+
+// lockTaskGraph takes the task graph lock of the organization until tx ends.
+// Every write that changes whether a task is blocked or has open subtasks
+// holds it, so that a task checked under the lock stays as checked until
+// the check's transaction commits.
+func lockTaskGraph(tx *sql.Tx, orgID int) error {
+	_, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, $2)`, taskGraphLock, orgID)
+	return err
+}
+
+// checkParent reports a ConstraintError on parent_id unless parentID is 0 or
+// a task of the organization in projectID that is neither task id itself nor
+// one of its subtasks. id is 0 for a task being created. Callers hold the
+// task graph lock, so that two concurrent moves cannot close a cycle between
+// them.
+func checkParent(tx *sql.Tx, orgID, id, parentID, projectID int) error {
+	if parentID == 0 {
+		return nil
+	}
+
+	var parentProject int
+	err := tx.QueryRow(`SELECT COALESCE(project_id, 0) FROM tasks WHERE id = $1 AND org_id = $2`, parentID, orgID).Scan(&parentProject)
+	if err == sql.ErrNoRows {
+		return &models.ConstraintError{Err: models.ErrReferenceNotFound, Field: "parent_id"}
+	}
+	if err != nil {
+		return err
+	}
+	if parentProject != projectID {
+		return &models.ConstraintError{Err: models.ErrSubtaskProject, Field: "parent_id"}
+	}
+	if id == 0 {
+		return nil
+	}
+
+	var cycle bool
+	err = tx.QueryRow(`SELECT $1::int = $2::int OR $2 IN (SELECT id FROM task_descendants($1))`, id, parentID).Scan(&cycle)
+	if err != nil {
+		return err
+	}
+	if cycle {
+		return &models.ConstraintError{Err: models.ErrTaskCycle, Field: "parent_id"}
+	}
 	return nil
 }
 
 func (q *Query) GetTaskByID(id int) (models.Task, error) {
 	var task models.Task
 
-	err := q.db.QueryRow(`
-		SELECT id, title, description, due_date, priority, status, created_by, assigned_to, created_at, updated_at
-		FROM tasks WHERE id = $1
-	`, id).Scan(&task.ID, &task.Title, &task.Description, &task.DueDate, &task.Priority, &task.Status, &task.CreatedBy, &task.AssignedTo, &task.CreatedAt, &task.UpdatedAt)
-
+	err := scanDerivedTask(q.db.QueryRow(`SELECT `+taskColumns+`, `+derivedTaskColumns("tasks")+` FROM tasks WHERE id = $1 AND org_id = $2`, id, q.orgID),
+		&task)
 	if err != nil {
 		log.Printf("Failed to fetch task by ID: %v", err)
 		return task, err
@@ -153,16 +511,70 @@ func (q *Query) GetTaskByID(id int) (models.Task, error) {
 	return task, nil
 }
 
-func (q *Query) UpdateTask(task models.Task) error {
-	_, err := q.db.Exec(`
+func (q *Query) GetTaskByKey(key string) (models.Task, error) {
+	var task models.Task
+
+	err := scanDerivedTask(q.db.QueryRow(`SELECT `+taskColumns+`, `+derivedTaskColumns("tasks")+` FROM tasks WHERE task_key = $1 AND org_id = $2`, key, q.orgID),
+		&task)
+	if err != nil {
+		log.Printf("Failed to fetch task by key: %v", err)
+		return task, err
+	}
+
+	return task, nil
+}
+
+// UpdateTask overwrites the task only if it is still at task.Version and
+// check accepts the task as stored. task.ProjectID must be the stored
+// project, which the parent is checked against. A change of status or
+// parent takes the task graph lock before the stored task is read for
+// check, so that its blockers and subtasks cannot change before the update
+// commits.
+func (q *Query) UpdateTask(task models.Task, check func(stored models.Task) error) error {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stored, err := storedTask(tx, q.orgID, task.ID)
+	if err != nil {
+		return err
+	}
+	if stored.Status != task.Status || stored.ParentID != task.ParentID {
+		if err := lockTaskGraph(tx, q.orgID); err != nil {
+			return err
+		}
+		if stored, err = storedTask(tx, q.orgID, task.ID); err != nil {
+			return err
+		}
+	}
+	if stored.Version != task.Version {
+		return models.ErrVersionConflict
+	}
+	if err := check(stored); err != nil {
+		return err
+	}
+
+	if err := checkParent(tx, q.orgID, task.ID, task.ParentID, task.ProjectID); err != nil {
+		return err
+	}
+	result, err := tx.Exec(`
 		UPDATE tasks SET
-			title = $1, description = $2, due_date = $3, priority = $4, status = $5,
-			assigned_to = $6, updated_at = CURRENT_TIMESTAMP
-		WHERE id = $7
-	`, task.Title, task.Description, task.DueDate, task.Priority, task.Status, task.AssignedTo, task.ID)
+			title = $1, description = $2, due_date = $3, due_has_time = $4, priority = $5, status = $6,
+			assigned_to = $7, parent_id = $8, updated_at = CURRENT_TIMESTAMP, version = version + 1
+		WHERE id = $9 AND version = $10 AND org_id = $11
+	`, task.Title, task.Description, task.DueDate, task.DueDate.HasTime, task.Priority, task.Status,
+		nullableID(task.AssignedTo), nullableID(task.ParentID), task.ID, task.Version, q.orgID)
 
 	if err != nil {
 		log.Printf("Failed to update task ID %d: %v", task.ID, err)
+		return translateError(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if err := q.checkTaskWritten(result, task.ID); err != nil {
 		return err
 	}
 
@@ -170,111 +582,1278 @@ func (q *Query) UpdateTask(task models.Task) error {
 	return nil
 }
 
-func (q *Query) DeleteTask(id int) error {
-	_, err := q.db.Exec("DELETE FROM tasks WHERE id = $1", id)
+// storedTask reads the task with its derived fields within tx.
+func storedTask(tx *sql.Tx, orgID, id int) (models.Task, error) {
+	var task models.Task
+	err := scanDerivedTask(tx.QueryRow(`SELECT `+taskColumns+`, `+derivedTaskColumns("tasks")+` FROM tasks WHERE id = $1 AND org_id = $2`, id, orgID),
+		&task)
+	return task, err
+}
+
+// DeleteTask removes the task only if it is still at version and has no
+// subtasks. A subtask added concurrently makes it report a version conflict.
+func (q *Query) DeleteTask(id, version int) error {
+	var hasSubtasks bool
+	err := q.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM tasks WHERE parent_id = $1 AND org_id = $2)`, id, q.orgID).Scan(&hasSubtasks)
+	if err != nil {
+		return err
+	}
+	if hasSubtasks {
+		return models.ErrTaskHasSubtasks
+	}
+
+	result, err := q.db.Exec(`
+		DELETE FROM tasks t
+		WHERE t.id = $1 AND t.version = $2 AND t.org_id = $3 AND NOT EXISTS (SELECT 1 FROM tasks c WHERE c.parent_id = t.id)
+	`, id, version, q.orgID)
 	if err != nil {
 		log.Printf("Failed to delete task ID %d: %v", id, err)
 		return err
 	}
+	if err := q.checkTaskWritten(result, id); err != nil {
+		return err
+	}
 	log.Printf("Task ID %d deleted successfully.", id)
 	return nil
 }
 
-func (q *Query) ListTasks() ([]models.Task, error) {
-	var tasks []models.Task
-
-	rows, err := q.db.Query(`
-		SELECT id, title, description, due_date, priority, status, created_by, assigned_to, created_at, updated_at
-		FROM tasks
-	`)
+// checkTaskWritten tells apart the two reasons a versioned write can match
+// no rows: the task is gone, or someone else changed it first.
+func (q *Query) checkTaskWritten(result sql.Result, id int) error {
+	affected, err := result.RowsAffected()
 	if err != nil {
-		log.Printf("Failed to list tasks: %v", err)
-		return nil, err
+		return err
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var task models.Task
-		err := rows.Scan(&task.ID, &task.Title, &task.Description, &task.DueDate, &task.Priority, &task.Status,
-			&task.CreatedBy, &task.AssignedTo, &task.CreatedAt, &task.UpdatedAt)
-		if err != nil {
-			log.Printf("Failed to scan task row: %v", err)
-			return nil, err
-		}
-		tasks = append(tasks, task)
+	if affected > 0 {
+		return nil
 	}
 
-	return tasks, nil
-}
-func (q *Query) GetUserDashboard(userID int) (map[string][]models.Task, error) {
-	dashboard := make(map[string][]models.Task)
-
-	rows, err := q.db.Query(`
-		SELECT id, title, description, due_date, priority, status, created_by, assigned_to, created_at, updated_at
-		FROM tasks
-		WHERE created_by = $1 OR assigned_to = $1
-		ORDER BY status
-	`, userID)
+	var exists bool
+	err = q.db.QueryRow("SELECT EXISTS (SELECT 1 FROM tasks WHERE id = $1 AND org_id = $2)", id, q.orgID).Scan(&exists)
 	if err != nil {
-		log.Printf("Failed to get dashboard data for user %d: %v", userID, err)
-		return nil, err
+		return err
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var task models.Task
-		if err := rows.Scan(&task.ID, &task.Title, &task.Description, &task.DueDate, &task.Priority,
-			&task.Status, &task.CreatedBy, &task.AssignedTo, &task.CreatedAt, &task.UpdatedAt); err != nil {
-			log.Printf("Failed to scan dashboard task row: %v", err)
-			return nil, err
-		}
-
-		status := task.Status
-		dashboard[status] = append(dashboard[status], task)
+	if !exists {
+		return sql.ErrNoRows
 	}
+	return models.ErrVersionConflict
+}
 
-	log.Printf("Dashboard data fetched successfully for user ID %d", userID)
-	return dashboard, nil
+// taskSortKeys maps each sortable field to the SQL expression it orders by
+// and the type its cursor value is cast back to. NULLs are coalesced so that
+// keyset comparisons never see them.
+var taskSortKeys = map[string]struct {
+	expr string
+	cast string
+}{
+	"due_date":   {"COALESCE(due_date, TIMESTAMPTZ '9999-12-31 00:00:00+00')", "timestamptz"},
+	"priority":   {"CASE priority WHEN 'low' THEN 1 WHEN 'medium' THEN 2 WHEN 'high' THEN 3 ELSE 0 END", "int"},
+	"created_at": {"created_at", "timestamptz"},
+	"updated_at": {"updated_at", "timestamptz"},
+	"title":      {"title", "text"},
 }
-func (q *Query) SearchAndFilterTasks(filter models.TaskFilter) ([]models.Task, error) {
-	var tasks []models.Task
 
-	query := `SELECT id, title, description, due_date, priority, status, created_by, assigned_to, created_at, updated_at
-	          FROM tasks WHERE 1=1`
-	args := []interface{}{}
-	argID := 1
+func (q *Query) ListTasks(opts models.ListOptions) (models.TaskPage, error) {
+	where, args := taskFilterConditions(models.TaskFilter{}, q.orgID)
+	return q.listTasksPage(where, args, opts)
+}
 
-	if filter.Status != "" {
-		query += " AND status = $" + strconv.Itoa(argID)
-		args = append(args, filter.Status)
-		argID++
+// listTasksPage returns one page of tasks matching the where conditions,
+// ordered by opts.Sort with the task ID as tie-breaker. Conditions use
+// placeholders $1..$len(args).
+func (q *Query) listTasksPage(where []string, args []interface{}, opts models.ListOptions) (models.TaskPage, error) {
+	page := models.TaskPage{Items: []models.Task{}}
+
+	key, ok := taskSortKeys[opts.Sort]
+	if !ok {
+		return page, fmt.Errorf("unsupported sort field %q", opts.Sort)
 	}
-	if filter.Priority != "" {
-		query += " AND priority = $" + strconv.Itoa(argID)
-		args = append(args, filter.Priority)
-		argID++
+	direction, cmp := "ASC", ">"
+	if opts.Order == models.SortDesc {
+		direction, cmp = "DESC", "<"
 	}
-	if filter.AssignedTo != 0 {
-		query += " AND assigned_to = $" + strconv.Itoa(argID)
-		args = append(args, filter.AssignedTo)
-		argID++
+
+	filter := ""
+	if len(where) > 0 {
+		filter = " WHERE " + strings.Join(where, " AND ")
 	}
 
-	rows, err := q.db.Query(query, args...)
+	err := q.db.QueryRow(`SELECT COUNT(*) FROM tasks`+filter, args...).Scan(&page.Total)
 	if err != nil {
-		return nil, err
+		log.Printf("Failed to count tasks: %v", err)
+		return page, err
+	}
+
+	if opts.After != "" {
+		cursor, err := utils.DecodeCursor(opts.After, opts.Sort, opts.Order)
+		if err != nil {
+			return page, err
+		}
+		argID := len(args) + 1
+		where = append(where, fmt.Sprintf("(%s, id) %s ($%d::%s, $%d)", key.expr, cmp, argID, key.cast, argID+1))
+		args = append(args, cursor.Value, cursor.ID)
+		filter = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	// Fetch one extra row to know whether another page follows
+	args = append(args, opts.Limit+1)
+	rows, err := q.db.Query(fmt.Sprintf(`
+		SELECT %s, %s, (%s)::text
+		FROM tasks%s
+		ORDER BY %s %s, id %s
+		LIMIT $%d
+	`, taskColumns, derivedTaskColumns("tasks"), key.expr, filter, key.expr, direction, direction, len(args)), args...)
+	if err != nil {
+		log.Printf("Failed to list tasks: %v", err)
+		return page, err
 	}
 	defer rows.Close()
 
+	var lastKey string
 	for rows.Next() {
 		var task models.Task
-		err := rows.Scan(&task.ID, &task.Title, &task.Description, &task.DueDate, &task.Priority,
-			&task.Status, &task.CreatedBy, &task.AssignedTo, &task.CreatedAt, &task.UpdatedAt)
+		var sortKey string
+		err := scanDerivedTask(rows, &task, &sortKey)
 		if err != nil {
-			return nil, err
+			log.Printf("Failed to scan task row: %v", err)
+			return page, err
 		}
-		tasks = append(tasks, task)
+		if len(page.Items) == opts.Limit {
+			page.NextCursor = utils.EncodeCursor(utils.Cursor{
+				Sort:  opts.Sort,
+				Order: opts.Order,
+				Value: lastKey,
+				ID:    page.Items[len(page.Items)-1].ID,
+			})
+			break
+		}
+		page.Items = append(page.Items, task)
+		lastKey = sortKey
 	}
 
+	return page, rows.Err()
+}
+
+func (q *Query) GetUserDashboard(userID int, loc *time.Location) (models.Dashboard, error) {
+	dashboard, err := q.dashboard("created_by = $1 OR assigned_to = $1", userID, loc)
+	if err != nil {
+		log.Printf("Failed to get dashboard data for user %d: %v", userID, err)
+		return models.Dashboard{}, err
+	}
+	log.Printf("Dashboard data fetched successfully for user ID %d", userID)
+	return dashboard, nil
+}
+
+func (q *Query) GetProjectDashboard(projectID int, loc *time.Location) (models.Dashboard, error) {
+	dashboard, err := q.dashboard("project_id = $1", projectID, loc)
+	if err != nil {
+		log.Printf("Failed to get dashboard data for project %d: %v", projectID, err)
+		return models.Dashboard{}, err
+	}
+	return dashboard, nil
+}
+
+// dashboard builds a Dashboard from the tasks of the organization matching
+// condition, which uses $1 for arg.
+func (q *Query) dashboard(condition string, arg interface{}, loc *time.Location) (models.Dashboard, error) {
+	tasks, err := q.tasksWhere(condition, arg, "status, id")
+	if err != nil {
+		return models.Dashboard{}, err
+	}
+	return models.BuildDashboard(tasks, time.Now(), loc), nil
+}
+
+// tasksWhere returns the tasks of the organization matching condition, which
+// uses $1 for arg, sorted by orderBy.
+func (q *Query) tasksWhere(condition string, arg interface{}, orderBy string) ([]models.Task, error) {
+	rows, err := q.db.Query(`
+		SELECT `+taskColumns+`, `+derivedTaskColumns("tasks")+`
+		FROM tasks
+		WHERE org_id = $2 AND (`+condition+`)
+		ORDER BY `+orderBy, arg, q.orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []models.Task{}
+	for rows.Next() {
+		var task models.Task
+		if err := scanDerivedTask(rows, &task); err != nil {
+			log.Printf("Failed to scan task row: %v", err)
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
+
+func (q *Query) ListSubtasks(parentID int) ([]models.Task, error) {
+	tasks, err := q.tasksWhere("parent_id = $1", parentID, "id")
+	if err != nil {
+		log.Printf("Failed to list subtasks of task %d: %v", parentID, err)
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (q *Query) GetSubtree(rootID int) ([]models.Task, error) {
+	tasks, err := q.tasksWhere("id IN (SELECT id FROM task_descendants($1))", rootID, "id")
+	if err != nil {
+		log.Printf("Failed to fetch subtree of task %d: %v", rootID, err)
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (q *Query) SearchAndFilterTasks(filter models.TaskFilter, opts models.ListOptions) (models.TaskPage, error) {
+	where, args := taskFilterConditions(filter, q.orgID)
+	return q.listTasksPage(where, args, opts)
+}
+
+// FullTextSearchTasks matches text (websearch_to_tsquery syntax) against the
+// generated search_vector column, narrowed by filter, and returns results by
+// descending ts_rank with a highlighted excerpt.
+func (q *Query) FullTextSearchTasks(text string, filter models.TaskFilter, opts models.ListOptions) (models.TaskSearchPage, error) {
+	page := models.TaskSearchPage{Items: []models.TaskSearchHit{}}
+
+	where, args := taskFilterConditions(filter, q.orgID)
+	args = append(args, text)
+	tsQueryArg := len(args)
+	where = append(where, "search_vector @@ tsq")
+
+	ranked := fmt.Sprintf(`
+		SELECT t.*, ts_rank(t.search_vector, tsq) AS rank, tsq
+		FROM tasks t, websearch_to_tsquery('english', $%d) tsq
+		WHERE %s
+	`, tsQueryArg, strings.Join(where, " AND "))
+
+	err := q.db.QueryRow(`SELECT COUNT(*) FROM (`+ranked+`) ranked`, args...).Scan(&page.Total)
+	if err != nil {
+		log.Printf("Failed to count search results: %v", err)
+		return page, err
+	}
+
+	after := ""
+	if opts.After != "" {
+		cursor, err := utils.DecodeCursor(opts.After, models.SortRank, models.SortDesc)
+		if err != nil {
+			return page, err
+		}
+		after = fmt.Sprintf("WHERE (rank, id) < ($%d::real, $%d)", len(args)+1, len(args)+2)
+		args = append(args, cursor.Value, cursor.ID)
+	}
+
+	args = append(args, opts.Limit+1)
+	rows, err := q.db.Query(fmt.Sprintf(`
+		SELECT %s, %s, rank, rank::text,
+			ts_headline('english', coalesce(title, '') || ' ' || coalesce(description, ''), tsq,
+				'StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15')
+		FROM (%s) ranked
+		%s
+		ORDER BY rank DESC, id DESC
+		LIMIT $%d
+	`, taskColumns, derivedTaskColumns("ranked"), ranked, after, len(args)), args...)
+	if err != nil {
+		log.Printf("Failed to search tasks: %v", err)
+		return page, err
+	}
+	defer rows.Close()
+
+	var lastKey string
+	for rows.Next() {
+		var hit models.TaskSearchHit
+		var rankKey string
+		err := scanDerivedTask(rows, &hit.Task, &hit.Rank, &rankKey, &hit.Snippet)
+		if err != nil {
+			log.Printf("Failed to scan search result row: %v", err)
+			return page, err
+		}
+		if len(page.Items) == opts.Limit {
+			page.NextCursor = utils.EncodeCursor(utils.Cursor{
+				Sort:  models.SortRank,
+				Order: models.SortDesc,
+				Value: lastKey,
+				ID:    page.Items[len(page.Items)-1].ID,
+			})
+			break
+		}
+		page.Items = append(page.Items, hit)
+		lastKey = rankKey
+	}
+
+	return page, rows.Err()
+}
+
+// taskFilterConditions turns a TaskFilter into SQL conditions on the tasks
+// of organization orgID with placeholders numbered from $1.
+func taskFilterConditions(filter models.TaskFilter, orgID int) ([]string, []interface{}) {
+	var where []string
+	args := []interface{}{}
+
+	// add numbers each $? in condition in order, consuming one arg per $?
+	add := func(condition string, conditionArgs ...interface{}) {
+		for _, arg := range conditionArgs {
+			args = append(args, arg)
+			condition = strings.Replace(condition, "$?", "$"+strconv.Itoa(len(args)), 1)
+		}
+		where = append(where, condition)
+	}
+
+	add("org_id = $?", orgID)
+
+	if filter.Query != "" {
+		pattern := likePattern(filter.Query)
+		add(`(title ILIKE $? ESCAPE '\' OR description ILIKE $? ESCAPE '\')`, pattern, pattern)
+	}
+	if filter.Title != "" {
+		add(`title ILIKE $? ESCAPE '\'`, likePattern(filter.Title))
+	}
+	if len(filter.Status) > 0 {
+		statuses := make([]string, len(filter.Status))
+		for i, status := range filter.Status {
+			statuses[i] = string(status)
+		}
+		add("status = ANY($?)", pq.Array(statuses))
+	}
+	if len(filter.Priority) > 0 {
+		priorities := make([]string, len(filter.Priority))
+		for i, priority := range filter.Priority {
+			priorities[i] = string(priority)
+		}
+		add("priority = ANY($?)", pq.Array(priorities))
+	}
+
+	// All-day deadlines are stored as midnight UTC and keep their date; timed
+	// deadlines are converted to the caller's zone before taking the date.
+	zone := "UTC"
+	if filter.Location != nil {
+		zone = filter.Location.String()
+	}
+	localDueDate := `(CASE WHEN due_has_time THEN (due_date AT TIME ZONE $?)::date ELSE (due_date AT TIME ZONE 'UTC')::date END)`
+	if filter.DueBefore != "" {
+		add(localDueDate+" <= $?::date", zone, filter.DueBefore)
+	}
+	if filter.DueAfter != "" {
+		add(localDueDate+" >= $?::date", zone, filter.DueAfter)
+	}
+
+	if filter.AssignedTo != 0 {
+		add("assigned_to = $?", filter.AssignedTo)
+	}
+	if filter.CreatedBy != 0 {
+		add("created_by = $?", filter.CreatedBy)
+	}
+	if filter.ProjectID != 0 {
+		add("project_id = $?", filter.ProjectID)
+	}
+	if filter.RestrictProjects {
+		add("(project_id IS NULL OR project_id = ANY($?))", pq.Array(filter.VisibleProjects))
+	}
+
+	return where, args
+}
+
+// likePattern builds a substring ILIKE pattern, escaping the LIKE wildcards
+// in the user's input.
+func likePattern(s string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+	return "%" + escaped + "%"
+}
+
+// ======================== Dependency Functions ========================
+
+// AddDependency makes taskID wait for dependsOnID. Both must be tasks of the
+// organization in the same project, and dependsOnID must not already wait
+// for taskID, directly or through other tasks. The check and the insert run
+// under the task graph lock, so that concurrent inserts cannot close a cycle.
+func (q *Query) AddDependency(taskID, dependsOnID int) error {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockTaskGraph(tx, q.orgID); err != nil {
+		return err
+	}
+
+	const projectOf = `SELECT COALESCE(project_id, 0) FROM tasks WHERE id = $1 AND org_id = $2`
+	var projectID, dependsOnProject int
+	if err := tx.QueryRow(projectOf, taskID, q.orgID).Scan(&projectID); err != nil {
+		return err
+	}
+	err = tx.QueryRow(projectOf, dependsOnID, q.orgID).Scan(&dependsOnProject)
+	if err == sql.ErrNoRows {
+		return &models.ConstraintError{Err: models.ErrReferenceNotFound, Field: "depends_on_id"}
+	}
+	if err != nil {
+		return err
+	}
+	if dependsOnProject != projectID {
+		return &models.ConstraintError{Err: models.ErrDependencyProject, Field: "depends_on_id"}
+	}
+
+	var cycle bool
+	err = tx.QueryRow(`SELECT $1::int = $2::int OR $1 IN (SELECT id FROM task_blockers($2))`, taskID, dependsOnID).Scan(&cycle)
+	if err != nil {
+		return err
+	}
+	if cycle {
+		return &models.ConstraintError{Err: models.ErrDependencyCycle, Field: "depends_on_id"}
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO task_dependencies (task_id, depends_on_id, org_id) VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+	`, taskID, dependsOnID, q.orgID)
+	if err != nil {
+		log.Printf("Failed to add dependency of task %d on task %d: %v", taskID, dependsOnID, err)
+		return translateError(err)
+	}
+	return tx.Commit()
+}
+
+func (q *Query) RemoveDependency(taskID, dependsOnID int) error {
+	return execAffectingOne(q.db, `DELETE FROM task_dependencies WHERE task_id = $1 AND depends_on_id = $2 AND org_id = $3`,
+		taskID, dependsOnID, q.orgID)
+}
+
+func (q *Query) ListDependencies(taskID int) ([]models.Task, error) {
+	tasks, err := q.tasksWhere("id IN (SELECT depends_on_id FROM task_dependencies WHERE task_id = $1)", taskID, "id")
+	if err != nil {
+		log.Printf("Failed to list dependencies of task %d: %v", taskID, err)
+		return nil, err
+	}
 	return tasks, nil
 }
+
+func (q *Query) GetCriticalPath(projectID int) ([]models.Task, error) {
+	tasks, err := q.tasksWhere("project_id = $1 AND status <> 'done'", projectID, "id")
+	if err != nil {
+		log.Printf("Failed to get critical path of project %d: %v", projectID, err)
+		return nil, err
+	}
+	return models.CriticalPath(tasks), nil
+}
+
+// ======================== Comment Functions ========================
+
+// commentColumns is the column list scanned by scanComment; c is task_comments.
+const commentColumns = `c.id, c.task_id, COALESCE(c.parent_id, 0), c.author_id, c.body,
+	ARRAY(SELECT u.username FROM comment_mentions m JOIN users u ON u.id = m.user_id
+		WHERE m.comment_id = c.id ORDER BY u.username),
+	(SELECT COUNT(*) FROM task_comments r WHERE r.parent_id = c.id),
+	c.created_at, c.updated_at`
+
+func scanComment(row rowScanner, comment *models.Comment) error {
+	return row.Scan(&comment.ID, &comment.TaskID, &comment.ParentID, &comment.AuthorID, &comment.Body,
+		pq.Array(&comment.Mentions), &comment.ReplyCount, &comment.CreatedAt, &comment.UpdatedAt)
+}
+
+// CreateComment stores a comment and the users it mentions. A reply must
+// belong to the same task as its parent.
+func (q *Query) CreateComment(comment models.Comment) (models.Comment, error) {
+	if err := q.checkReference("tasks", "task_id", comment.TaskID); err != nil {
+		return models.Comment{}, err
+	}
+
+	tx, err := q.db.Begin()
+	if err != nil {
+		return models.Comment{}, err
+	}
+	defer tx.Rollback()
+
+	if comment.ParentID != 0 {
+		var parentTask int
+		err := tx.QueryRow(`SELECT task_id FROM task_comments WHERE id = $1 AND `+inOrg("task_id", "tasks", 2),
+			comment.ParentID, q.orgID).Scan(&parentTask)
+		if err == sql.ErrNoRows || (err == nil && parentTask != comment.TaskID) {
+			return models.Comment{}, &models.ConstraintError{Err: models.ErrReferenceNotFound, Field: "parent_id"}
+		}
+		if err != nil {
+			return models.Comment{}, err
+		}
+	}
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO task_comments (task_id, parent_id, author_id, body)
+		SELECT id, $2::int, $3::int, $4::text FROM tasks WHERE id = $1 AND org_id = $5
+		RETURNING id
+	`, comment.TaskID, nullableID(comment.ParentID), comment.AuthorID, comment.Body, q.orgID).Scan(&id)
+	if err != nil {
+		log.Printf("Failed to create comment on task %d: %v", comment.TaskID, err)
+		return models.Comment{}, translateError(err)
+	}
+
+	if err := setMentions(tx, q.orgID, id, comment.Body); err != nil {
+		return models.Comment{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Comment{}, err
+	}
+	return q.GetComment(id)
+}
+
+func (q *Query) GetComment(id int) (models.Comment, error) {
+	var comment models.Comment
+	err := scanComment(q.db.QueryRow(`SELECT `+commentColumns+` FROM task_comments c WHERE c.id = $1 AND `+
+		inOrg("c.task_id", "tasks", 2), id, q.orgID), &comment)
+	return comment, err
+}
+
+// UpdateComment replaces the body of a comment and re-resolves its mentions.
+func (q *Query) UpdateComment(id int, body string) (models.Comment, error) {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return models.Comment{}, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE task_comments SET body = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND `+
+		inOrg("task_id", "tasks", 3), body, id, q.orgID)
+	if err != nil {
+		log.Printf("Failed to update comment %d: %v", id, err)
+		return models.Comment{}, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.Comment{}, err
+	}
+	if affected == 0 {
+		return models.Comment{}, sql.ErrNoRows
+	}
+
+	if err := setMentions(tx, q.orgID, id, body); err != nil {
+		return models.Comment{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Comment{}, err
+	}
+	return q.GetComment(id)
+}
+
+// setMentions records the users of the organization mentioned in body;
+// unknown names are ignored.
+func setMentions(tx *sql.Tx, orgID, commentID int, body string) error {
+	_, err := tx.Exec(`
+		DELETE FROM comment_mentions WHERE comment_id = $1
+			AND comment_id IN (SELECT c.id FROM task_comments c JOIN tasks t ON t.id = c.task_id WHERE t.org_id = $2)
+	`, commentID, orgID)
+	if err != nil {
+		return err
+	}
+	names := models.ParseMentions(body)
+	if len(names) == 0 {
+		return nil
+	}
+	_, err = tx.Exec(`
+		INSERT INTO comment_mentions (comment_id, user_id)
+		SELECT $1, id FROM users WHERE username = ANY($2) AND org_id = $3
+	`, commentID, pq.Array(names), orgID)
+	return err
+}
+
+// DeleteComment removes a comment together with its replies.
+func (q *Query) DeleteComment(id int) error {
+	res, err := q.db.Exec(`DELETE FROM task_comments WHERE id = $1 AND `+inOrg("task_id", "tasks", 2), id, q.orgID)
+	if err != nil {
+		log.Printf("Failed to delete comment %d: %v", id, err)
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListComments returns the replies to parentID, or the top-level comments of
+// the task when parentID is 0, oldest first.
+func (q *Query) ListComments(taskID, parentID int, opts models.ListOptions) (models.CommentPage, error) {
+	page := models.CommentPage{Items: []models.Comment{}}
+
+	where := "c.task_id = $1 AND " + inOrg("c.task_id", "tasks", 2) + " AND c.parent_id IS NULL"
+	args := []interface{}{taskID, q.orgID}
+	if parentID != 0 {
+		where = "c.task_id = $1 AND " + inOrg("c.task_id", "tasks", 2) + " AND c.parent_id = $3"
+		args = append(args, parentID)
+	}
+
+	err := q.db.QueryRow(`SELECT COUNT(*) FROM task_comments c WHERE `+where, args...).Scan(&page.Total)
+	if err != nil {
+		log.Printf("Failed to count comments of task %d: %v", taskID, err)
+		return page, err
+	}
+
+	if opts.After != "" {
+		cursor, err := utils.DecodeCursor(opts.After, models.CommentSort, models.SortAsc)
+		if err != nil {
+			return page, err
+		}
+		args = append(args, cursor.ID)
+		where += fmt.Sprintf(" AND c.id > $%d", len(args))
+	}
+
+	args = append(args, opts.Limit+1)
+	rows, err := q.db.Query(fmt.Sprintf(`
+		SELECT %s
+		FROM task_comments c
+		WHERE %s
+		ORDER BY c.id
+		LIMIT $%d
+	`, commentColumns, where, len(args)), args...)
+	if err != nil {
+		log.Printf("Failed to list comments of task %d: %v", taskID, err)
+		return page, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var comment models.Comment
+		if err := scanComment(rows, &comment); err != nil {
+			log.Printf("Failed to scan comment row: %v", err)
+			return page, err
+		}
+		if len(page.Items) == opts.Limit {
+			page.NextCursor = commentCursor(page.Items[len(page.Items)-1].ID)
+			break
+		}
+		page.Items = append(page.Items, comment)
+	}
+	return page, rows.Err()
+}
+
+func commentCursor(lastID int) string {
+	return utils.EncodeCursor(utils.Cursor{
+		Sort:  models.CommentSort,
+		Order: models.SortAsc,
+		Value: strconv.Itoa(lastID),
+		ID:    lastID,
+	})
+}
+
+// ======================== Attachment Functions ========================
+
+const attachmentColumns = `id, task_id, filename, size, content_type, sha256, storage_key, uploaded_by, created_at`
+
+func scanAttachment(row rowScanner, attachment *models.Attachment) error {
+	return row.Scan(&attachment.ID, &attachment.TaskID, &attachment.Filename, &attachment.Size,
+		&attachment.ContentType, &attachment.SHA256, &attachment.StorageKey, &attachment.UploadedBy, &attachment.CreatedAt)
+}
+
+func (q *Query) CreateAttachment(attachment models.Attachment) (models.Attachment, error) {
+	if err := q.checkReference("tasks", "task_id", attachment.TaskID); err != nil {
+		return models.Attachment{}, err
+	}
+
+	var created models.Attachment
+	err := scanAttachment(q.db.QueryRow(`
+		INSERT INTO task_attachments (task_id, filename, size, content_type, sha256, storage_key, uploaded_by)
+		SELECT id, $2::text, $3::bigint, $4::text, $5::text, $6::text, $7::int FROM tasks WHERE id = $1 AND org_id = $8
+		RETURNING `+attachmentColumns,
+		attachment.TaskID, attachment.Filename, attachment.Size, attachment.ContentType,
+		attachment.SHA256, attachment.StorageKey, attachment.UploadedBy, q.orgID), &created)
+	if err != nil {
+		log.Printf("Failed to create attachment on task %d: %v", attachment.TaskID, err)
+		return models.Attachment{}, translateError(err)
+	}
+	return created, nil
+}
+
+func (q *Query) GetAttachment(id int) (models.Attachment, error) {
+	var attachment models.Attachment
+	err := scanAttachment(q.db.QueryRow(`SELECT `+attachmentColumns+` FROM task_attachments WHERE id = $1 AND `+
+		inOrg("task_id", "tasks", 2), id, q.orgID), &attachment)
+	return attachment, err
+}
+
+func (q *Query) ListAttachments(taskID int) ([]models.Attachment, error) {
+	rows, err := q.db.Query(`SELECT `+attachmentColumns+` FROM task_attachments WHERE task_id = $1 AND `+
+		inOrg("task_id", "tasks", 2)+` ORDER BY id`, taskID, q.orgID)
+	if err != nil {
+		log.Printf("Failed to list attachments of task %d: %v", taskID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []models.Attachment{}
+	for rows.Next() {
+		var attachment models.Attachment
+		if err := scanAttachment(rows, &attachment); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, rows.Err()
+}
+
+func (q *Query) DeleteAttachment(id int) error {
+	res, err := q.db.Exec(`DELETE FROM task_attachments WHERE id = $1 AND `+inOrg("task_id", "tasks", 2), id, q.orgID)
+	if err != nil {
+		log.Printf("Failed to delete attachment %d: %v", id, err)
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ======================== Project Functions ========================
+
+// projectColumns is the column list scanned by scanProject; p is projects.
+const projectColumns = `p.id, p.key, p.name, p.description, COALESCE(p.created_by, 0), p.archived_at,
+	p.created_at, p.updated_at, (SELECT COUNT(*) FROM tasks t WHERE t.project_id = p.id), p.org_id`
+
+func scanProject(row rowScanner, project *models.Project) error {
+	var archivedAt sql.NullTime
+	err := row.Scan(&project.ID, &project.Key, &project.Name, &project.Description, &project.CreatedBy,
+		&archivedAt, &project.CreatedAt, &project.UpdatedAt, &project.TaskCount, &project.OrgID)
+	if archivedAt.Valid {
+		project.ArchivedAt = &archivedAt.Time
+	}
+	return err
+}
+
+// CreateProject inserts the project and makes its creator the first admin.
+func (q *Query) CreateProject(project models.Project) (models.Project, error) {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return models.Project{}, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO projects (key, name, description, created_by, org_id)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, project.Key, project.Name, project.Description, nullableID(project.CreatedBy), q.orgID).Scan(&id)
+	if err != nil {
+		log.Printf("Failed to create project %s: %v", project.Key, err)
+		return models.Project{}, translateError(err)
+	}
+	if project.CreatedBy != 0 {
+		_, err = tx.Exec(`
+			INSERT INTO project_members (project_id, user_id, role)
+			SELECT $1::int, id, $3::text FROM users WHERE id = $2 AND org_id = $4
+		`, id, project.CreatedBy, models.RoleAdmin, q.orgID)
+		if err != nil {
+			return models.Project{}, translateError(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Project{}, err
+	}
+	return q.GetProject(id)
+}
+
+func (q *Query) GetProject(id int) (models.Project, error) {
+	var project models.Project
+	err := scanProject(q.db.QueryRow(`SELECT `+projectColumns+` FROM projects p WHERE p.id = $1 AND p.org_id = $2`, id, q.orgID), &project)
+	return project, err
+}
+
+func (q *Query) ListProjects(includeArchived bool) ([]models.Project, error) {
+	where := " WHERE p.org_id = $1 AND p.archived_at IS NULL"
+	if includeArchived {
+		where = " WHERE p.org_id = $1"
+	}
+	rows, err := q.db.Query(`SELECT `+projectColumns+` FROM projects p`+where+` ORDER BY p.key`, q.orgID)
+	if err != nil {
+		log.Printf("Failed to list projects: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := []models.Project{}
+	for rows.Next() {
+		var project models.Project
+		if err := scanProject(rows, &project); err != nil {
+			return nil, err
+		}
+		projects = append(projects, project)
+	}
+	return projects, rows.Err()
+}
+
+// UpdateProject changes the name and description of an unarchived project.
+func (q *Query) UpdateProject(project models.Project) (models.Project, error) {
+	res, err := q.db.Exec(`
+		UPDATE projects SET name = $1, description = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3 AND org_id = $4 AND archived_at IS NULL
+	`, project.Name, project.Description, project.ID, q.orgID)
+	if err != nil {
+		log.Printf("Failed to update project %d: %v", project.ID, err)
+		return models.Project{}, translateError(err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.Project{}, err
+	}
+	if affected == 0 {
+		existing, err := q.GetProject(project.ID)
+		if err != nil {
+			return models.Project{}, err
+		}
+		if existing.Archived() {
+			return models.Project{}, models.ErrProjectArchived
+		}
+	}
+	return q.GetProject(project.ID)
+}
+
+// SetProjectArchived archives or restores a project. Archiving an archived
+// project keeps its original archived_at.
+func (q *Query) SetProjectArchived(id int, archived bool) (models.Project, error) {
+	stmt := `UPDATE projects SET archived_at = COALESCE(archived_at, CURRENT_TIMESTAMP), updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND org_id = $2`
+	if !archived {
+		stmt = `UPDATE projects SET archived_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND org_id = $2`
+	}
+	res, err := q.db.Exec(stmt, id, q.orgID)
+	if err != nil {
+		log.Printf("Failed to change archive state of project %d: %v", id, err)
+		return models.Project{}, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.Project{}, err
+	}
+	if affected == 0 {
+		return models.Project{}, sql.ErrNoRows
+	}
+	return q.GetProject(id)
+}
+
+// DeleteProject removes a project that has no tasks.
+func (q *Query) DeleteProject(id int) error {
+	res, err := q.db.Exec(`
+		DELETE FROM projects p
+		WHERE p.id = $1 AND p.org_id = $2 AND NOT EXISTS (SELECT 1 FROM tasks t WHERE t.project_id = p.id)
+	`, id, q.orgID)
+	if err != nil {
+		log.Printf("Failed to delete project %d: %v", id, err)
+		return translateError(err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	var exists bool
+	if err := q.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM projects WHERE id = $1 AND org_id = $2)`, id, q.orgID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+	return models.ErrProjectNotEmpty
+}
+
+// ======================== Team Functions ========================
+
+const teamColumns = `t.id, t.name, t.description, COALESCE(t.created_by, 0), t.created_at,
+	(SELECT COUNT(*) FROM team_members m WHERE m.team_id = t.id), t.org_id`
+
+func scanTeam(row rowScanner, team *models.Team) error {
+	return row.Scan(&team.ID, &team.Name, &team.Description, &team.CreatedBy, &team.CreatedAt, &team.MemberCount, &team.OrgID)
+}
+
+func (q *Query) CreateTeam(team models.Team) (models.Team, error) {
+	var id int
+	err := q.db.QueryRow(`
+		INSERT INTO teams (name, description, created_by, org_id) VALUES ($1, $2, $3, $4) RETURNING id
+	`, team.Name, team.Description, nullableID(team.CreatedBy), q.orgID).Scan(&id)
+	if err != nil {
+		log.Printf("Failed to create team %s: %v", team.Name, err)
+		return models.Team{}, translateError(err)
+	}
+	return q.GetTeam(id)
+}
+
+func (q *Query) GetTeam(id int) (models.Team, error) {
+	var team models.Team
+	err := scanTeam(q.db.QueryRow(`SELECT `+teamColumns+` FROM teams t WHERE t.id = $1 AND t.org_id = $2`, id, q.orgID), &team)
+	return team, err
+}
+
+func (q *Query) ListTeams() ([]models.Team, error) {
+	rows, err := q.db.Query(`SELECT `+teamColumns+` FROM teams t WHERE t.org_id = $1 ORDER BY t.name`, q.orgID)
+	if err != nil {
+		log.Printf("Failed to list teams: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := []models.Team{}
+	for rows.Next() {
+		var team models.Team
+		if err := scanTeam(rows, &team); err != nil {
+			return nil, err
+		}
+		teams = append(teams, team)
+	}
+	return teams, rows.Err()
+}
+
+func (q *Query) DeleteTeam(id int) error {
+	return execAffectingOne(q.db, `DELETE FROM teams WHERE id = $1 AND org_id = $2`, id, q.orgID)
+}
+
+// AddTeamMember adds the user to the team; adding an existing member is a
+// no-op. Both must belong to the organization.
+func (q *Query) AddTeamMember(teamID, userID int) error {
+	if err := q.checkReference("teams", "team_id", teamID); err != nil {
+		return err
+	}
+	if err := q.checkReference("users", "user_id", userID); err != nil {
+		return err
+	}
+
+	_, err := q.db.Exec(`
+		INSERT INTO team_members (team_id, user_id)
+		SELECT t.id, u.id FROM teams t, users u
+		WHERE t.id = $1 AND t.org_id = $3 AND u.id = $2 AND u.org_id = $3
+		ON CONFLICT DO NOTHING
+	`, teamID, userID, q.orgID)
+	if err != nil {
+		log.Printf("Failed to add user %d to team %d: %v", userID, teamID, err)
+		return translateError(err)
+	}
+	return nil
+}
+
+func (q *Query) RemoveTeamMember(teamID, userID int) error {
+	return execAffectingOne(q.db, `DELETE FROM team_members WHERE team_id = $1 AND user_id = $2 AND `+
+		inOrg("team_id", "teams", 3), teamID, userID, q.orgID)
+}
+
+func (q *Query) ListTeamMembers(teamID int) ([]models.TeamMember, error) {
+	rows, err := q.db.Query(`
+		SELECT m.team_id, m.user_id, u.username
+		FROM team_members m JOIN users u ON u.id = m.user_id
+		WHERE m.team_id = $1 AND `+inOrg("m.team_id", "teams", 2)+`
+		ORDER BY u.username
+	`, teamID, q.orgID)
+	if err != nil {
+		log.Printf("Failed to list members of team %d: %v", teamID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []models.TeamMember{}
+	for rows.Next() {
+		var member models.TeamMember
+		if err := rows.Scan(&member.TeamID, &member.UserID, &member.Username); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// execAffectingOne runs a statement that must affect at least one row and
+// returns sql.ErrNoRows when it affects none.
+func execAffectingOne(db dbtx, query string, args ...interface{}) error {
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		log.Printf("Failed to execute %q: %v", query, err)
+		return translateError(err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ======================== Membership Functions ========================
+
+// roleRank orders project roles in SQL like rbac.RoleRank does in Go.
+const roleRank = `CASE role WHEN 'admin' THEN 3 WHEN 'manager' THEN 2 WHEN 'user' THEN 1 ELSE 0 END`
+
+// SetProjectMember adds the user to the project or changes their role. Both
+// must belong to the organization.
+func (q *Query) SetProjectMember(projectID, userID int, role string) error {
+	if err := q.checkReference("projects", "project_id", projectID); err != nil {
+		return err
+	}
+	if err := q.checkReference("users", "user_id", userID); err != nil {
+		return err
+	}
+
+	_, err := q.db.Exec(`
+		INSERT INTO project_members (project_id, user_id, role)
+		SELECT p.id, u.id, $3::text FROM projects p, users u
+		WHERE p.id = $1 AND p.org_id = $4 AND u.id = $2 AND u.org_id = $4
+		ON CONFLICT (project_id, user_id) DO UPDATE SET role = EXCLUDED.role
+	`, projectID, userID, role, q.orgID)
+	if err != nil {
+		log.Printf("Failed to set role of user %d on project %d: %v", userID, projectID, err)
+		return translateError(err)
+	}
+	return nil
+}
+
+func (q *Query) RemoveProjectMember(projectID, userID int) error {
+	return execAffectingOne(q.db, `DELETE FROM project_members WHERE project_id = $1 AND user_id = $2 AND `+
+		inOrg("project_id", "projects", 3), projectID, userID, q.orgID)
+}
+
+// ListProjectMembers returns direct members followed by members through
+// team grants, each ordered by username.
+func (q *Query) ListProjectMembers(projectID int) ([]models.ProjectMember, error) {
+	rows, err := q.db.Query(`
+		SELECT pm.project_id, pm.user_id, u.username, pm.role, 0 AS team_id
+		FROM project_members pm JOIN users u ON u.id = pm.user_id
+		WHERE pm.project_id = $1 AND `+inOrg("pm.project_id", "projects", 2)+`
+		UNION ALL
+		SELECT pt.project_id, tm.user_id, u.username, pt.role, pt.team_id
+		FROM project_teams pt
+		JOIN team_members tm ON tm.team_id = pt.team_id
+		JOIN users u ON u.id = tm.user_id
+		WHERE pt.project_id = $1 AND `+inOrg("pt.project_id", "projects", 2)+`
+		ORDER BY team_id, username
+	`, projectID, q.orgID)
+	if err != nil {
+		log.Printf("Failed to list members of project %d: %v", projectID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []models.ProjectMember{}
+	for rows.Next() {
+		var member models.ProjectMember
+		if err := rows.Scan(&member.ProjectID, &member.UserID, &member.Username, &member.Role, &member.TeamID); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// SetProjectTeam grants the team a role on the project or changes it. Both
+// must belong to the organization.
+func (q *Query) SetProjectTeam(projectID, teamID int, role string) error {
+	if err := q.checkReference("projects", "project_id", projectID); err != nil {
+		return err
+	}
+	if err := q.checkReference("teams", "team_id", teamID); err != nil {
+		return err
+	}
+
+	_, err := q.db.Exec(`
+		INSERT INTO project_teams (project_id, team_id, role)
+		SELECT p.id, t.id, $3::text FROM projects p, teams t
+		WHERE p.id = $1 AND p.org_id = $4 AND t.id = $2 AND t.org_id = $4
+		ON CONFLICT (project_id, team_id) DO UPDATE SET role = EXCLUDED.role
+	`, projectID, teamID, role, q.orgID)
+	if err != nil {
+		log.Printf("Failed to set role of team %d on project %d: %v", teamID, projectID, err)
+		return translateError(err)
+	}
+	return nil
+}
+
+func (q *Query) RemoveProjectTeam(projectID, teamID int) error {
+	return execAffectingOne(q.db, `DELETE FROM project_teams WHERE project_id = $1 AND team_id = $2 AND `+
+		inOrg("project_id", "projects", 3), projectID, teamID, q.orgID)
+}
+
+func (q *Query) ListProjectTeams(projectID int) ([]models.ProjectTeam, error) {
+	rows, err := q.db.Query(`
+		SELECT pt.project_id, pt.team_id, t.name, pt.role
+		FROM project_teams pt JOIN teams t ON t.id = pt.team_id
+		WHERE pt.project_id = $1 AND t.org_id = $2
+		ORDER BY t.name
+	`, projectID, q.orgID)
+	if err != nil {
+		log.Printf("Failed to list teams of project %d: %v", projectID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := []models.ProjectTeam{}
+	for rows.Next() {
+		var team models.ProjectTeam
+		if err := rows.Scan(&team.ProjectID, &team.TeamID, &team.TeamName, &team.Role); err != nil {
+			return nil, err
+		}
+		teams = append(teams, team)
+	}
+	return teams, rows.Err()
+}
+
+// projectGrants selects (project_id, role) for every role user $1 holds,
+// directly or through a team.
+const projectGrants = `
+	SELECT project_id, role FROM project_members WHERE user_id = $1
+	UNION ALL
+	SELECT pt.project_id, pt.role FROM project_teams pt
+	JOIN team_members tm ON tm.team_id = pt.team_id
+	WHERE tm.user_id = $1`
+
+func (q *Query) GetProjectRole(projectID, userID int) (string, error) {
+	var role string
+	err := q.db.QueryRow(`
+		SELECT role FROM (`+projectGrants+`) grants
+		WHERE project_id = $2 AND `+inOrg("project_id", "projects", 3)+`
+		ORDER BY `+roleRank+` DESC
+		LIMIT 1
+	`, userID, projectID, q.orgID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+func (q *Query) ListMemberProjects(userID int) ([]int, error) {
+	rows, err := q.db.Query(`
+		SELECT DISTINCT project_id FROM (`+projectGrants+`) grants
+		WHERE `+inOrg("project_id", "projects", 2)+`
+		ORDER BY project_id
+	`, userID, q.orgID)
+	if err != nil {
+		log.Printf("Failed to list projects of user %d: %v", userID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+const invitationColumns = `id, project_id, email, role, token_hash, COALESCE(invited_by, 0), expires_at, accepted_at, created_at,
+	(SELECT p.org_id FROM projects p WHERE p.id = project_invitations.project_id)`
+
+func scanInvitation(row rowScanner, invitation *models.Invitation) error {
+	var acceptedAt sql.NullTime
+	err := row.Scan(&invitation.ID, &invitation.ProjectID, &invitation.Email, &invitation.Role, &invitation.TokenHash,
+		&invitation.InvitedBy, &invitation.ExpiresAt, &acceptedAt, &invitation.CreatedAt, &invitation.OrgID)
+	if acceptedAt.Valid {
+		invitation.AcceptedAt = &acceptedAt.Time
+	}
+	return err
+}
+
+func (q *Query) CreateInvitation(invitation models.Invitation) (models.Invitation, error) {
+	if err := q.checkReference("projects", "project_id", invitation.ProjectID); err != nil {
+		return models.Invitation{}, err
+	}
+
+	var created models.Invitation
+	err := scanInvitation(q.db.QueryRow(`
+		INSERT INTO project_invitations (project_id, email, role, token_hash, invited_by, expires_at)
+		SELECT id, $2::text, $3::text, $4::text, $5::int, $6::timestamptz FROM projects WHERE id = $1 AND org_id = $7
+		RETURNING `+invitationColumns,
+		invitation.ProjectID, invitation.Email, invitation.Role, invitation.TokenHash,
+		nullableID(invitation.InvitedBy), invitation.ExpiresAt, q.orgID), &created)
+	if err != nil {
+		log.Printf("Failed to create invitation to project %d: %v", invitation.ProjectID, err)
+		return models.Invitation{}, translateError(err)
+	}
+	return created, nil
+}
+
+// GetInvitationByToken looks an invitation up by the hash of its token; the
+// condition on $2 lets the unscoped Query find invitations of every
+// organization.
+func (q *Query) GetInvitationByToken(tokenHash string) (models.Invitation, error) {
+	var invitation models.Invitation
+	err := scanInvitation(q.db.QueryRow(`
+		SELECT `+invitationColumns+` FROM project_invitations
+		WHERE token_hash = $1 AND project_id IN (SELECT id FROM projects WHERE $2 = 0 OR org_id = $2)
+	`, tokenHash, q.orgID), &invitation)
+	return invitation, err
+}
+
+// ListInvitations returns the invitations of a project that can still be
+// accepted, newest first.
+func (q *Query) ListInvitations(projectID int) ([]models.Invitation, error) {
+	rows, err := q.db.Query(`
+		SELECT `+invitationColumns+` FROM project_invitations
+		WHERE project_id = $1 AND `+inOrg("project_id", "projects", 2)+`
+			AND accepted_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		ORDER BY id DESC
+	`, projectID, q.orgID)
+	if err != nil {
+		log.Printf("Failed to list invitations of project %d: %v", projectID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []models.Invitation{}
+	for rows.Next() {
+		var invitation models.Invitation
+		if err := scanInvitation(rows, &invitation); err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, invitation)
+	}
+	return invitations, rows.Err()
+}
+
+func (q *Query) DeleteInvitation(projectID, id int) error {
+	return execAffectingOne(q.db, `DELETE FROM project_invitations WHERE project_id = $1 AND id = $2 AND `+
+		inOrg("project_id", "projects", 3), projectID, id, q.orgID)
+}
+
+// AcceptInvitation marks a pending invitation accepted and gives the user
+// its role on the project, keeping a higher role they already hold directly.
+func (q *Query) AcceptInvitation(id, userID int) error {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var projectID int
+	var role string
+	err = tx.QueryRow(`
+		UPDATE project_invitations SET accepted_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND `+inOrg("project_id", "projects", 2)+`
+			AND accepted_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING project_id, role
+	`, id, q.orgID).Scan(&projectID, &role)
+	if err == sql.ErrNoRows {
+		return models.ErrInvitationInvalid
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO project_members AS pm (project_id, user_id, role)
+		SELECT $1::int, id, $3::text FROM users WHERE id = $2 AND org_id = $4
+		ON CONFLICT (project_id, user_id) DO UPDATE SET role = EXCLUDED.role
+		WHERE (CASE pm.role WHEN 'admin' THEN 3 WHEN 'manager' THEN 2 WHEN 'user' THEN 1 ELSE 0 END) <
+			(CASE EXCLUDED.role WHEN 'admin' THEN 3 WHEN 'manager' THEN 2 WHEN 'user' THEN 1 ELSE 0 END)
+	`, projectID, userID, role, q.orgID)
+	if err != nil {
+		log.Printf("Failed to add user %d to project %d: %v", userID, projectID, err)
+		return translateError(err)
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,330 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode"
+
+	"github.com/naveeshkumar24/internal/models"
+)
+
+// unscopedMethods only run on the Query of NewQuery: organizations and
+// refresh tokens are not rows of a tenant.
+var unscopedMethods = map[string]bool{
+	"Close":               true,
+	"GetOrganization":     true,
+	"CreateOrganization":  true,
+	"CreateRefreshToken":  true,
+	"GetRefreshToken":     true,
+	"RotateRefreshToken":  true,
+	"RevokeTokenFamily":   true,
+	"RevokeAllUserTokens": true,
+}
+
+// unconditionedStatements may leave out org_id because they touch no rows
+// by themselves: advisory locks are keyed by organization, and the graph
+// checks walk from tasks their caller has already found in the
+// organization.
+var unconditionedStatements = []*regexp.Regexp{
+	regexp.MustCompile(`^SELECT pg_advisory_xact_lock\(\$1, \$2\)$`),
+	regexp.MustCompile(`^SELECT \$1::int = \$2::int OR \$[12] IN \(SELECT id FROM task_(descendants|blockers)\(\$[12]\)\)$`),
+}
+
+// TestOrgQueryStatementsCarryOrgID calls every method of an organization
+// scoped Query against a driver that records the statements, answering each
+// with no rows, with rows of ones and with rows of zeros so that every
+// branch on a lookup is taken, and requires each statement to condition on
+// org_id.
+func TestOrgQueryStatementsCarryOrgID(t *testing.T) {
+	queryType := reflect.TypeOf(&Query{})
+	for i := 0; i < queryType.NumMethod(); i++ {
+		method := queryType.Method(i)
+		if unscopedMethods[method.Name] {
+			continue
+		}
+		t.Run(method.Name, func(t *testing.T) {
+			seen := map[string]bool{}
+			for _, answer := range []answer{answerNone, answerOnes, answerZeros} {
+				for _, filled := range []bool{false, true} {
+					recorder := &recorder{answer: answer}
+					db := sql.OpenDB(recorder)
+					query := &Query{db: pool{db}, orgID: 7}
+
+					args := []reflect.Value{reflect.ValueOf(query)}
+					counter := 0
+					for j := 1; j < method.Type.NumIn(); j++ {
+						args = append(args, fakeValue(method.Type.In(j), filled, &counter))
+					}
+					method.Func.Call(args)
+					db.Close()
+
+					for _, statement := range recorder.statements {
+						seen[statement] = true
+					}
+				}
+			}
+
+			if len(seen) == 0 {
+				t.Fatalf("%s ran no statement", method.Name)
+			}
+			for statement := range seen {
+				if !strings.Contains(statement, "org_id") && !unconditioned(statement) {
+					t.Errorf("statement has no org_id condition:\n%s", statement)
+				}
+			}
+		})
+	}
+}
+
+func unconditioned(statement string) bool {
+	for _, pattern := range unconditionedStatements {
+		if pattern.MatchString(statement) {
+			return true
+		}
+	}
+	return false
+}
+
+// fakeValue builds an argument of type typ: the zero value, or when filled
+// is set one with every settable field set, numbers counting up from 1 so
+// that IDs differ. List options are always a valid first page, as the
+// handlers check them before they reach a Query.
+func fakeValue(typ reflect.Type, filled bool, counter *int) reflect.Value {
+	value := reflect.New(typ).Elem()
+	fill(value, filled, counter)
+	return value
+}
+
+func fill(value reflect.Value, filled bool, counter *int) {
+	switch value.Kind() {
+	case reflect.Ptr:
+		value.Set(reflect.New(value.Type().Elem()))
+		fill(value.Elem(), filled, counter)
+	case reflect.Func:
+		typ := value.Type()
+		value.Set(reflect.MakeFunc(typ, func([]reflect.Value) []reflect.Value {
+			results := make([]reflect.Value, typ.NumOut())
+			for i := range results {
+				results[i] = reflect.Zero(typ.Out(i))
+			}
+			return results
+		}))
+	case reflect.Struct:
+		if value.Type() == reflect.TypeOf(time.Time{}) {
+			if filled {
+				value.Set(reflect.ValueOf(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)))
+			}
+			return
+		}
+		for i := 0; i < value.NumField(); i++ {
+			if value.Field(i).CanSet() {
+				fill(value.Field(i), filled, counter)
+			}
+		}
+		if value.Type() == reflect.TypeOf(models.ListOptions{}) {
+			value.Set(reflect.ValueOf(models.ListOptions{Limit: 10, Sort: "created_at"}))
+		}
+	}
+	if !filled {
+		return
+	}
+	switch value.Kind() {
+	case reflect.Int, reflect.Int64:
+		*counter++
+		value.SetInt(int64(*counter))
+	case reflect.Float64:
+		value.SetFloat(1)
+	case reflect.Bool:
+		value.SetBool(true)
+	case reflect.String:
+		value.SetString("a")
+	case reflect.Slice:
+		slice := reflect.MakeSlice(value.Type(), 1, 1)
+		fill(slice.Index(0), filled, counter)
+		value.Set(slice)
+	}
+}
+
+// answer is what the recorder returns for every statement.
+type answer int
+
+const (
+	answerNone answer = iota
+	answerOnes
+	answerZeros
+)
+
+// recorder is a database/sql driver that keeps every statement it is given,
+// with whitespace collapsed.
+type recorder struct {
+	answer answer
+
+	mu         sync.Mutex
+	statements []string
+}
+
+func (r *recorder) Connect(context.Context) (driver.Conn, error) { return &recorderConn{r}, nil }
+func (r *recorder) Driver() driver.Driver                        { return nil }
+
+func (r *recorder) record(query string) string {
+	statement := strings.Join(strings.Fields(query), " ")
+	r.mu.Lock()
+	r.statements = append(r.statements, statement)
+	r.mu.Unlock()
+	return statement
+}
+
+type recorderConn struct {
+	r *recorder
+}
+
+func (c *recorderConn) Prepare(string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("recorder: Prepare is not supported")
+}
+func (c *recorderConn) Close() error              { return nil }
+func (c *recorderConn) Begin() (driver.Tx, error) { return recorderTx{}, nil }
+
+func (c *recorderConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.r.record(query)
+	if c.r.answer == answerOnes {
+		return driver.RowsAffected(1), nil
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (c *recorderConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	statement := c.r.record(query)
+	rows := &recorderRows{columns: resultColumns(statement)}
+	if c.r.answer != answerNone {
+		rows.row = make([]driver.Value, len(rows.columns))
+		for i, column := range rows.columns {
+			rows.row[i] = columnValue(column, c.r.answer)
+		}
+	}
+	return rows, nil
+}
+
+type recorderTx struct{}
+
+func (recorderTx) Commit() error   { return nil }
+func (recorderTx) Rollback() error { return nil }
+
+// recorderRows holds at most one row.
+type recorderRows struct {
+	columns []string
+	row     []driver.Value
+}
+
+func (r *recorderRows) Columns() []string { return r.columns }
+func (r *recorderRows) Close() error      { return nil }
+
+func (r *recorderRows) Next(dest []driver.Value) error {
+	if r.row == nil {
+		return io.EOF
+	}
+	copy(dest, r.row)
+	r.row = nil
+	return nil
+}
+
+var (
+	timeColumn  = regexp.MustCompile(`(_at|due_date)$`)
+	arrayColumn = regexp.MustCompile(`^(\(SELECT )?ARRAY\b`)
+)
+
+// columnValue is a value every scan of column accepts: times for
+// timestamps, pairs for arrays and numbers, which also scan into strings
+// and booleans, for everything else.
+func columnValue(column string, answer answer) driver.Value {
+	switch {
+	case arrayColumn.MatchString(column) && answer == answerOnes:
+		return []byte("{1,1}")
+	case arrayColumn.MatchString(column):
+		return []byte("{0,0}")
+	case timeColumn.MatchString(column):
+		return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	case answer == answerOnes:
+		return int64(1)
+	default:
+		return int64(0)
+	}
+}
+
+// resultColumns splits the RETURNING list of statement, or else the list of
+// its outermost SELECT, into column expressions.
+func resultColumns(statement string) []string {
+	list := ""
+	if i := topLevelKeyword(statement, "RETURNING", 0); i >= 0 {
+		list = statement[i+len("RETURNING"):]
+	} else if i := topLevelKeyword(statement, "SELECT", 0); i >= 0 {
+		list = statement[i+len("SELECT"):]
+		if end := topLevelKeyword(list, "FROM", 0); end >= 0 {
+			list = list[:end]
+		}
+		list = strings.TrimPrefix(strings.TrimSpace(list), "DISTINCT ")
+	}
+	var columns []string
+	for _, column := range splitTopLevel(list) {
+		columns = append(columns, strings.TrimSpace(column))
+	}
+	return columns
+}
+
+// topLevelKeyword returns the index of the first keyword of s from start
+// outside parentheses and quotes, or -1.
+func topLevelKeyword(s, keyword string, start int) int {
+	depth, quoted := 0, false
+	for i := start; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\'':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case depth == 0 && strings.HasPrefix(strings.ToUpper(s[i:]), keyword) &&
+			(i == 0 || !isWordByte(s[i-1])) &&
+			(i+len(keyword) == len(s) || !isWordByte(s[i+len(keyword)])):
+			return i
+		}
+	}
+	return -1
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+// splitTopLevel splits s at the commas outside parentheses and quotes.
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, quoted, start := 0, false, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\'':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	if strings.TrimSpace(s[start:]) != "" {
+		parts = append(parts, s[start:])
+	}
+	return parts
+}
@@ -0,0 +1,246 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// testDBEnv names a Postgres database the policy tests may create scratch
+// schemas and roles in. The tests are skipped without it.
+const testDBEnv = "TEST_DB_URL"
+
+// policyTables are read by the policy tests; each query returns the
+// organization every visible row belongs to. Comments carry no org_id and
+// name the organization in their body instead.
+var policyTables = map[string]string{
+	"users":             `SELECT org_id::text FROM users ORDER BY 1`,
+	"projects":          `SELECT org_id::text FROM projects ORDER BY 1`,
+	"tasks":             `SELECT org_id::text FROM tasks ORDER BY 1`,
+	"teams":             `SELECT org_id::text FROM teams ORDER BY 1`,
+	"task_dependencies": `SELECT org_id::text FROM task_dependencies ORDER BY 1`,
+	"task_comments":     `SELECT body FROM task_comments ORDER BY 1`,
+}
+
+// TestRowLevelSecurityPolicies migrates a scratch schema, fills it with two
+// organizations and reads it back as a role the policies apply to, with
+// app.org_id and app.bypass_rls set in different ways.
+func TestRowLevelSecurityPolicies(t *testing.T) {
+	dsn := os.Getenv(testDBEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDBEnv)
+	}
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		var err error
+		if dsn, err = pq.ParseURL(dsn); err != nil {
+			t.Fatalf("invalid %s: %v", testDBEnv, err)
+		}
+	}
+
+	admin := openTestDB(t, dsn)
+	schema := fmt.Sprintf("rls_test_%d", time.Now().UnixNano())
+	mustExec(t, admin, `CREATE SCHEMA `+schema)
+	t.Cleanup(func() {
+		if _, err := admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`); err != nil {
+			t.Errorf("drop schema %s: %v", schema, err)
+		}
+	})
+
+	dsn += " search_path=" + schema
+	bypassDSN, err := BypassRowLevelSecurity(dsn)
+	if err != nil {
+		t.Fatalf("BypassRowLevelSecurity: %v", err)
+	}
+	bypass := openTestDB(t, bypassDSN)
+	migrator, err := NewMigrator(bypass)
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	orgA, orgB, userB := seedPolicyData(t, bypass)
+
+	// Superusers and BYPASSRLS roles skip every policy, so the checks run as
+	// a scratch role unless the connecting user is already subject to them
+	ctx := context.Background()
+	conn, err := openTestDB(t, dsn).Conn(ctx)
+	if err != nil {
+		t.Fatalf("Conn: %v", err)
+	}
+	defer conn.Close()
+	var superuser, bypassRLS bool
+	if err := admin.QueryRow(`SELECT rolsuper, rolbypassrls FROM pg_roles WHERE rolname = current_user`).Scan(&superuser, &bypassRLS); err != nil {
+		t.Fatalf("read role: %v", err)
+	}
+	if superuser || bypassRLS {
+		role := schema + "_app"
+		mustExec(t, admin, `CREATE ROLE `+role+` NOLOGIN`)
+		t.Cleanup(func() {
+			admin.Exec(`DROP OWNED BY ` + role)
+			admin.Exec(`DROP ROLE ` + role)
+		})
+		mustExec(t, admin, `GRANT `+role+` TO CURRENT_USER`)
+		mustExec(t, admin, `GRANT USAGE ON SCHEMA `+schema+` TO `+role)
+		mustExec(t, admin, `GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA `+schema+` TO `+role)
+		mustExec(t, admin, `GRANT USAGE ON ALL SEQUENCES IN SCHEMA `+schema+` TO `+role)
+		if _, err := conn.ExecContext(ctx, `SET ROLE `+role); err != nil {
+			t.Fatalf("SET ROLE: %v", err)
+		}
+		defer conn.ExecContext(ctx, `RESET ROLE`)
+	}
+
+	a, b := fmt.Sprint(orgA), fmt.Sprint(orgB)
+	none := []string{}
+	tests := []struct {
+		name     string
+		settings map[string]string
+		want     []string
+	}{
+		{"app.org_id unset", nil, none},
+		{"app.org_id empty", map[string]string{"app.org_id": ""}, none},
+		{"app.org_id of the first organization", map[string]string{"app.org_id": a}, []string{a}},
+		{"app.org_id of the second organization", map[string]string{"app.org_id": b}, []string{b}},
+		{"app.bypass_rls off", map[string]string{"app.bypass_rls": "off"}, none},
+		{"app.bypass_rls on", map[string]string{"app.bypass_rls": "on"}, []string{a, b}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, err := conn.BeginTx(ctx, nil)
+			if err != nil {
+				t.Fatalf("Begin: %v", err)
+			}
+			defer tx.Rollback()
+			for setting, value := range tt.settings {
+				if _, err := tx.Exec(`SELECT set_config($1, $2, true)`, setting, value); err != nil {
+					t.Fatalf("set %s: %v", setting, err)
+				}
+			}
+
+			for table, query := range policyTables {
+				want := tt.want
+				if table == "tasks" {
+					// Every organization has two tasks
+					want = nil
+					for _, org := range tt.want {
+						want = append(want, org, org)
+					}
+				}
+				if got := column(t, tx, query); !reflect.DeepEqual(got, want) && len(got)+len(want) > 0 {
+					t.Errorf("%s: visible rows of organizations %v, want %v", table, got, want)
+				}
+			}
+		})
+	}
+
+	// Writes are checked against the same policies: a session of one
+	// organization can neither create nor change rows of another
+	t.Run("writes outside app.org_id", func(t *testing.T) {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatalf("Begin: %v", err)
+		}
+		defer tx.Rollback()
+		if _, err := tx.Exec(`SELECT set_config('app.org_id', $1, true)`, a); err != nil {
+			t.Fatalf("set app.org_id: %v", err)
+		}
+
+		result, err := tx.Exec(`UPDATE tasks SET title = 'Taken over' WHERE org_id = $1`, orgB)
+		if err != nil {
+			t.Fatalf("update: %v", err)
+		}
+		if n, _ := result.RowsAffected(); n != 0 {
+			t.Errorf("updated %d tasks of another organization", n)
+		}
+
+		_, err = tx.Exec(`INSERT INTO tasks (title, created_by, org_id) VALUES ('Planted', $1, $2)`, userB, orgB)
+		if err == nil || !strings.Contains(err.Error(), "row-level security") {
+			t.Errorf("insert into another organization: err = %v, want a row-level security violation", err)
+		}
+	})
+}
+
+// seedPolicyData creates an organization next to the default one and gives
+// each a user, a project, two tasks with a dependency, a comment and a team.
+func seedPolicyData(t *testing.T, db *sql.DB) (orgA, orgB, userB int) {
+	t.Helper()
+	orgA = 1
+	if err := db.QueryRow(`INSERT INTO organizations (name) VALUES ('Beta') RETURNING id`).Scan(&orgB); err != nil {
+		t.Fatalf("create organization: %v", err)
+	}
+
+	for _, org := range []int{orgA, orgB} {
+		var user, project, task, blocker int
+		name := fmt.Sprintf("org%d", org)
+		if err := db.QueryRow(`INSERT INTO users (username, email, password, org_id) VALUES ($1, $2, 'x', $3) RETURNING id`,
+			name, name+"@example.com", org).Scan(&user); err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		if err := db.QueryRow(`INSERT INTO projects (key, name, created_by, org_id) VALUES ('RLS', $1, $2, $3) RETURNING id`,
+			name, user, org).Scan(&project); err != nil {
+			t.Fatalf("create project: %v", err)
+		}
+		for _, id := range []*int{&task, &blocker} {
+			if err := db.QueryRow(`INSERT INTO tasks (title, created_by, project_id, org_id) VALUES ($1, $2, $3, $4) RETURNING id`,
+				name, user, project, org).Scan(id); err != nil {
+				t.Fatalf("create task: %v", err)
+			}
+		}
+		mustExec(t, db, `INSERT INTO task_dependencies (task_id, depends_on_id, org_id) VALUES ($1, $2, $3)`, task, blocker, org)
+		mustExec(t, db, `INSERT INTO task_comments (task_id, author_id, body) VALUES ($1, $2, $3)`, task, user, fmt.Sprint(org))
+		mustExec(t, db, `INSERT INTO teams (name, created_by, org_id) VALUES ($1, $2, $3)`, name, user, org)
+		if org == orgB {
+			userB = user
+		}
+	}
+	return orgA, orgB, userB
+}
+
+func openTestDB(t *testing.T, dsn string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Ping(); err != nil {
+		t.Fatalf("connect to database: %v", err)
+	}
+	return db
+}
+
+func mustExec(t *testing.T, db *sql.DB, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+// column returns the first column of every row of query.
+func column(t *testing.T, tx *sql.Tx, query string) []string {
+	t.Helper()
+	rows, err := tx.Query(query)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	defer rows.Close()
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		values = append(values, value)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return values
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// The settings the row-level security policies look at. The policies deny
+// every row unless app.org_id names its organization or app.bypass_rls is
+// on (migration 0014).
+const (
+	orgIDSetting     = "app.org_id"
+	bypassRLSSetting = "app.bypass_rls"
+)
+
+// rowLevelSecurity makes organization-scoped queries set app.org_id and
+// unscoped ones app.bypass_rls. The queries filter by organization either
+// way; the policies catch any statement that forgets to.
+var rowLevelSecurity bool
+
+// SetRowLevelSecurity turns setting app.org_id on or off. Call it once at
+// startup, before any query runs. Without it the connections must bypass
+// the policies altogether; see BypassRowLevelSecurity.
+func SetRowLevelSecurity(enabled bool) {
+	rowLevelSecurity = enabled
+}
+
+// BypassRowLevelSecurity returns dsn with app.bypass_rls turned on for
+// every connection, for services that run without DB_ROW_LEVEL_SECURITY and
+// rely on the org_id conditions of the queries alone. lib/pq sends settings
+// it does not know to the server when connecting.
+func BypassRowLevelSecurity(dsn string) (string, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		var err error
+		if dsn, err = pq.ParseURL(dsn); err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimSpace(dsn + " " + bypassRLSSetting + "=on"), nil
+}
+
+// dbtx is the part of *sql.DB the Query functions use, so that they can run
+// on a pooled connection pinned to one organization as well.
+type dbtx interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) rowScanner
+	Begin() (*sql.Tx, error)
+}
+
+// pool runs each statement on any connection of the pool.
+type pool struct {
+	*sql.DB
+}
+
+func (p pool) QueryRow(query string, args ...interface{}) rowScanner {
+	return p.DB.QueryRow(query, args...)
+}
+
+// session runs every statement on one pooled connection with a row-level
+// security setting applied: app.org_id for a Query scoped to an organization,
+// app.bypass_rls for an unscoped one. The connection is taken on first use
+// and handed back by Close once the setting is reset.
+type session struct {
+	db      *sql.DB
+	setting string
+	value   string
+	conn    *sql.Conn
+}
+
+func orgSession(db *sql.DB, orgID int) *session {
+	return &session{db: db, setting: orgIDSetting, value: strconv.Itoa(orgID)}
+}
+
+func bypassSession(db *sql.DB) *session {
+	return &session{db: db, setting: bypassRLSSetting, value: "on"}
+}
+
+func (s *session) acquire() (*sql.Conn, error) {
+	if s.conn != nil {
+		return s.conn, nil
+	}
+	ctx := context.Background()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, err = conn.ExecContext(ctx, `SELECT set_config($1, $2, false)`, s.setting, s.value)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	s.conn = conn
+	return conn, nil
+}
+
+func (s *session) Exec(query string, args ...interface{}) (sql.Result, error) {
+	conn, err := s.acquire()
+	if err != nil {
+		return nil, err
+	}
+	return conn.ExecContext(context.Background(), query, args...)
+}
+
+func (s *session) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	conn, err := s.acquire()
+	if err != nil {
+		return nil, err
+	}
+	return conn.QueryContext(context.Background(), query, args...)
+}
+
+func (s *session) QueryRow(query string, args ...interface{}) rowScanner {
+	conn, err := s.acquire()
+	if err != nil {
+		return errRow{err}
+	}
+	return conn.QueryRowContext(context.Background(), query, args...)
+}
+
+func (s *session) Begin() (*sql.Tx, error) {
+	conn, err := s.acquire()
+	if err != nil {
+		return nil, err
+	}
+	return conn.BeginTx(context.Background(), nil)
+}
+
+// Close resets the setting and returns the connection to the pool. A
+// connection whose setting cannot be reset is discarded instead, so that it
+// never serves another organization or bypasses the policies for one.
+func (s *session) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	conn := s.conn
+	s.conn = nil
+	if _, err := conn.ExecContext(context.Background(), `RESET `+s.setting); err != nil {
+		log.Printf("Failed to reset %s of pooled connection: %v", s.setting, err)
+		conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		return err
+	}
+	return conn.Close()
+}
+
+// errRow reports err from Scan, like the *sql.Row of a failed query.
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(dest ...interface{}) error {
+	return r.err
+}
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestBypassRowLevelSecurity(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{"", "app.bypass_rls=on"},
+		{"host=db dbname=tasks sslmode=disable", "host=db dbname=tasks sslmode=disable app.bypass_rls=on"},
+		{"postgres://app:secret@db:5432/tasks?sslmode=disable", "dbname='tasks' host='db' password='secret' port='5432' sslmode='disable' user='app' app.bypass_rls=on"},
+	}
+	for _, tt := range tests {
+		got, err := BypassRowLevelSecurity(tt.dsn)
+		if err != nil {
+			t.Errorf("BypassRowLevelSecurity(%q): %v", tt.dsn, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("BypassRowLevelSecurity(%q) = %q, want %q", tt.dsn, got, tt.want)
+		}
+		if _, err := pq.NewConnector(got); err != nil {
+			t.Errorf("lib/pq rejects %q: %v", got, err)
+		}
+	}
+
+	if _, err := BypassRowLevelSecurity("postgres://%zz"); err == nil {
+		t.Error("accepted a malformed URL")
+	}
+}
@@ -0,0 +1,197 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// sameJSON compares two documents semantically.
+func sameJSON(t *testing.T, got []byte, want string) bool {
+	t.Helper()
+	var g, w interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("result is not JSON: %s", got)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("expected value is not JSON: %s", want)
+	}
+	return reflect.DeepEqual(g, w)
+}
+
+// The examples of RFC 6902, Appendix A.
+func TestApplyRFC6902Examples(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string // empty when the patch must fail
+	}{
+		{"A.1 add object member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{"A.2 add array element", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{"A.3 remove object member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{"A.4 remove array element", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{"A.5 replace value", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{"A.6 move value",
+			`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{"A.7 move array element", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{"A.8 test value success", `{"baz":"qux","foo":["a",2,"c"]}`,
+			`[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`, `{"baz":"qux","foo":["a",2,"c"]}`},
+		{"A.10 add nested member object", `{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`},
+		{"A.11 ignore unrecognized elements", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux","xyz":123}]`, `{"foo":"bar","baz":"qux"}`},
+		{"A.12 add to nonexistent target", `{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, ""},
+		{"A.14 ~ escape ordering", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10}]`, `{"/":9,"~1":10}`},
+		{"A.16 add array value", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+
+		{"copy", `{"a":{"b":1}}`, `[{"op":"copy","from":"/a","path":"/c"}]`, `{"a":{"b":1},"c":{"b":1}}`},
+		{"replace whole document", `{"a":1}`, `[{"op":"replace","path":"","value":[1]}]`, `[1]`},
+		{"test numbers by value", `{"a":1}`, `[{"op":"test","path":"/a","value":1.0}]`, `{"a":1}`},
+		{"unknown op", `{}`, `[{"op":"frobnicate","path":"/a"}]`, ""},
+		{"missing value", `{}`, `[{"op":"add","path":"/a"}]`, ""},
+		{"invalid pointer", `{}`, `[{"op":"add","path":"a","value":1}]`, ""},
+		{"patch is not an array", `{}`, `{"op":"add","path":"/a","value":1}`, ""},
+		{"failed operation discards earlier ones", `{"a":1}`,
+			`[{"op":"replace","path":"/a","value":2},{"op":"remove","path":"/missing"}]`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply([]byte(tt.doc), []byte(tt.patch))
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("Apply succeeded with %s, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if !sameJSON(t, got, tt.want) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyTestFailure(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+	}{
+		{"A.9 test value error", `{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`},
+		{"A.15 string is not a number", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":"10"}]`},
+		{"array order matters", `{"a":[1,2]}`, `[{"op":"test","path":"/a","value":[2,1]}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Apply([]byte(tt.doc), []byte(tt.patch))
+			if !errors.Is(err, ErrTestFailed) {
+				t.Errorf("got error %v, want %v", err, ErrTestFailed)
+			}
+		})
+	}
+
+	// A missing path is an error, but not a failed comparison
+	_, err := Apply([]byte(`{}`), []byte(`[{"op":"test","path":"/a","value":1}]`))
+	if err == nil || errors.Is(err, ErrTestFailed) {
+		t.Errorf("test of a missing path: got %v", err)
+	}
+}
+
+func TestApplyMoveIntoChild(t *testing.T) {
+	doc := []byte(`{"a":{"b":{"c":1}}}`)
+
+	if _, err := Apply(doc, []byte(`[{"op":"move","from":"/a","path":"/a/b/d"}]`)); err == nil {
+		t.Error("moving a value into its own child succeeded")
+	}
+
+	// Moving onto itself, or to a sibling with a common prefix, is fine
+	got, err := Apply(doc, []byte(`[{"op":"move","from":"/a","path":"/a"}]`))
+	if err != nil || !sameJSON(t, got, string(doc)) {
+		t.Errorf("move onto itself: got %s, %v", got, err)
+	}
+	got, err = Apply([]byte(`{"a":1}`), []byte(`[{"op":"move","from":"/a","path":"/ab"}]`))
+	if err != nil || !sameJSON(t, got, `{"ab":1}`) {
+		t.Errorf("move to sibling: got %s, %v", got, err)
+	}
+}
+
+func TestApplyArrayIndexes(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  string // empty when the patch must fail
+	}{
+		{"append with -", `[{"op":"add","path":"/a/-","value":3}]`, `{"a":[1,2,3]}`},
+		{"insert at end", `[{"op":"add","path":"/a/2","value":3}]`, `{"a":[1,2,3]}`},
+		{"insert at start", `[{"op":"add","path":"/a/0","value":0}]`, `{"a":[0,1,2]}`},
+		{"add past end", `[{"op":"add","path":"/a/3","value":3}]`, ""},
+		{"remove with -", `[{"op":"remove","path":"/a/-"}]`, ""},
+		{"replace with -", `[{"op":"replace","path":"/a/-","value":3}]`, ""},
+		{"test with -", `[{"op":"test","path":"/a/-","value":2}]`, ""},
+		{"remove past end", `[{"op":"remove","path":"/a/2"}]`, ""},
+		{"leading zero", `[{"op":"replace","path":"/a/01","value":3}]`, ""},
+		{"negative index", `[{"op":"remove","path":"/a/-1"}]`, ""},
+		{"not a number", `[{"op":"remove","path":"/a/x"}]`, ""},
+		{"move to -", `[{"op":"move","from":"/a/0","path":"/a/-"}]`, `{"a":[2,1]}`},
+		{"copy from - ", `[{"op":"copy","from":"/a/-","path":"/b"}]`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply([]byte(`{"a":[1,2]}`), []byte(tt.patch))
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("Apply succeeded with %s, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if !sameJSON(t, got, tt.want) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// The examples of RFC 7396, Appendix A.
+func TestMergePatchRFC7396Examples(t *testing.T) {
+	tests := []struct {
+		doc, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.doc+" + "+tt.patch, func(t *testing.T) {
+			got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("MergePatch: %v", err)
+			}
+			if !sameJSON(t, got, tt.want) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := MergePatch([]byte(`{}`), []byte(`{"a":`)); err == nil {
+		t.Error("MergePatch accepted a malformed patch")
+	}
+}
@@ -0,0 +1,234 @@
+// Package search implements a small full-text matcher that understands the
+// same query syntax as Postgres' websearch_to_tsquery: bare words are ANDed,
+// "quoted text" is a phrase, OR separates alternatives and a leading minus
+// excludes a word or phrase. It backs full-text search for storage backends
+// other than Postgres; matching is simpler (no dictionaries, crude stemming)
+// but ranks and highlights results in the same spirit.
+package search
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Term is a word or phrase that must (or, if Negated, must not) appear.
+type Term struct {
+	Words   []string
+	Negated bool
+}
+
+// Query is a disjunction of conjunctions: a document matches if every term of
+// at least one group matches.
+type Query struct {
+	Groups [][]Term
+}
+
+// Empty reports whether the query has nothing to search for.
+func (q Query) Empty() bool {
+	return len(q.Groups) == 0
+}
+
+// Parse reads a websearch-style query.
+func Parse(text string) Query {
+	var query Query
+	var group []Term
+
+	flush := func() {
+		if len(group) > 0 {
+			query.Groups = append(query.Groups, group)
+			group = nil
+		}
+	}
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		switch {
+		case unicode.IsSpace(runes[i]):
+			i++
+
+		case runes[i] == '"' || (runes[i] == '-' && i+1 < len(runes) && runes[i+1] == '"'):
+			negated := runes[i] == '-'
+			if negated {
+				i++
+			}
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if words := Tokenize(string(runes[i+1 : end])); len(words) > 0 {
+				group = append(group, Term{Words: words, Negated: negated})
+			}
+			i = end + 1
+
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && runes[end] != '"' {
+				end++
+			}
+			word := string(runes[i:end])
+			i = end
+
+			if strings.EqualFold(word, "or") {
+				flush()
+				continue
+			}
+			negated := strings.HasPrefix(word, "-")
+			if words := Tokenize(strings.TrimPrefix(word, "-")); len(words) > 0 {
+				group = append(group, Term{Words: words, Negated: negated})
+			}
+		}
+	}
+	flush()
+
+	// A group made only of exclusions cannot select anything on its own
+	groups := query.Groups[:0]
+	for _, g := range query.Groups {
+		for _, term := range g {
+			if !term.Negated {
+				groups = append(groups, g)
+				break
+			}
+		}
+	}
+	query.Groups = groups
+	return query
+}
+
+// Tokenize splits text into normalized words.
+func Tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, field := range fields {
+		fields[i] = stem(field)
+	}
+	return fields
+}
+
+// stem strips a few common English suffixes so that "login", "logins" and
+// "logging" are treated alike.
+func stem(word string) string {
+	for _, suffix := range []string{"ing", "ed", "es", "s"} {
+		if len(word) > len(suffix)+3 && strings.HasSuffix(word, suffix) {
+			return strings.TrimSuffix(word, suffix)
+		}
+	}
+	return word
+}
+
+// Field is a weighted piece of a document, e.g. a title or description.
+type Field struct {
+	Text   string
+	Weight float64
+}
+
+// Match reports whether the fields satisfy the query and, if so, a relevance
+// rank: weighted term frequency normalised by document length.
+func (q Query) Match(fields ...Field) (bool, float64) {
+	tokens := make([][]string, len(fields))
+	total := 0
+	for i, field := range fields {
+		tokens[i] = Tokenize(field.Text)
+		total += len(tokens[i])
+	}
+
+	matched := false
+	rank := 0.0
+	for _, group := range q.Groups {
+		ok, score := matchGroup(group, fields, tokens)
+		if ok {
+			matched = true
+			rank = math.Max(rank, score)
+		}
+	}
+	if !matched {
+		return false, 0
+	}
+	return true, rank / (1 + math.Log(float64(1+total)))
+}
+
+func matchGroup(group []Term, fields []Field, tokens [][]string) (bool, float64) {
+	score := 0.0
+	for _, term := range group {
+		termScore := 0.0
+		for i := range fields {
+			termScore += float64(countPhrase(tokens[i], term.Words)) * fields[i].Weight
+		}
+		if term.Negated && termScore > 0 || !term.Negated && termScore == 0 {
+			return false, 0
+		}
+		score += termScore
+	}
+	return true, score
+}
+
+func countPhrase(tokens, phrase []string) int {
+	count := 0
+	for i := 0; i+len(phrase) <= len(tokens); i++ {
+		match := true
+		for j, word := range phrase {
+			if tokens[i+j] != word {
+				match = false
+				break
+			}
+		}
+		if match {
+			count++
+		}
+	}
+	return count
+}
+
+// Highlight returns a short excerpt of text around the first matching word,
+// with every matching word wrapped in <mark></mark> like the Postgres
+// ts_headline configuration used by the database backend.
+func (q Query) Highlight(text string, maxWords int) string {
+	wanted := make(map[string]bool)
+	for _, group := range q.Groups {
+		for _, term := range group {
+			if term.Negated {
+				continue
+			}
+			for _, word := range term.Words {
+				wanted[word] = true
+			}
+		}
+	}
+
+	words := strings.Fields(text)
+	first := -1
+	for i, word := range words {
+		if isWanted(word, wanted) {
+			first = i
+			break
+		}
+	}
+
+	start := 0
+	if first > maxWords/3 {
+		start = first - maxWords/3
+	}
+	end := start + maxWords
+	if end > len(words) {
+		end = len(words)
+	}
+
+	excerpt := make([]string, 0, end-start)
+	for _, word := range words[start:end] {
+		if isWanted(word, wanted) {
+			word = "<mark>" + word + "</mark>"
+		}
+		excerpt = append(excerpt, word)
+	}
+	return strings.Join(excerpt, " ")
+}
+
+func isWanted(word string, wanted map[string]bool) bool {
+	for _, token := range Tokenize(word) {
+		if wanted[token] {
+			return true
+		}
+	}
+	return false
+}
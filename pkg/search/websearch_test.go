@@ -0,0 +1,96 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  [][]Term
+	}{
+		{"empty", "", nil},
+		{"whitespace only", "  \t\n ", nil},
+		{"punctuation only", `!! "" - -""`, nil},
+		{"words are ANDed", "login bug", [][]Term{{{Words: []string{"login"}}, {Words: []string{"bug"}}}}},
+		{"words are stemmed", "Logins FAILED", [][]Term{{{Words: []string{"login"}}, {Words: []string{"fail"}}}}},
+		{"quoted phrase", `"reset password" email`, [][]Term{{{Words: []string{"reset", "password"}}, {Words: []string{"email"}}}}},
+		{"unterminated quote", `"reset password`, [][]Term{{{Words: []string{"reset", "password"}}}}},
+		{"negated word", "login -mobile", [][]Term{{{Words: []string{"login"}}, {Words: []string{"mobile"}, Negated: true}}}},
+		{"negated phrase", `login -"dark mode"`, [][]Term{{{Words: []string{"login"}}, {Words: []string{"dark", "mode"}, Negated: true}}}},
+		{"or splits groups", "login or signup", [][]Term{{{Words: []string{"login"}}}, {{Words: []string{"signup"}}}}},
+		{"OR is case-insensitive", "login OR signup", [][]Term{{{Words: []string{"login"}}}, {{Words: []string{"signup"}}}}},
+		{"leading and trailing or", "or login or", [][]Term{{{Words: []string{"login"}}}}},
+		{"exclusion-only group is dropped", "-mobile or login", [][]Term{{{Words: []string{"login"}}}}},
+		{"only exclusions", "-mobile -tablet", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.query)
+			if len(got.Groups) == 0 && len(tt.want) == 0 {
+				if !got.Empty() {
+					t.Error("Empty() = false for a query without groups")
+				}
+				return
+			}
+			if !reflect.DeepEqual(got.Groups, tt.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.query, got.Groups, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	doc := []Field{
+		{Text: "Reset password e-mail is not sent", Weight: 1},
+		{Text: "Users on mobile report that the reset link never arrives", Weight: 0.4},
+	}
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"reset", true},
+		{"reset sent", true},
+		{`"e-mail"`, true},
+		{"reset missing", false},
+		{`"reset password"`, true},
+		{`"password reset"`, false},
+		{"reset -mobile", false},
+		{"reset -desktop", true},
+		{`reset -"reset link"`, false},
+		{"missing or arrives", true},
+		{"missing or absent", false},
+		{"", false},
+		{"   ", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, rank := Parse(tt.query).Match(doc...)
+			if got != tt.want {
+				t.Errorf("Match(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+			if got && rank <= 0 {
+				t.Errorf("Match(%q) rank = %v, want > 0", tt.query, rank)
+			}
+		})
+	}
+}
+
+func TestMatchRanksTitleAboveDescription(t *testing.T) {
+	query := Parse("deploy")
+	_, inTitle := query.Match(Field{Text: "Deploy service", Weight: 1}, Field{Text: "Nothing here", Weight: 0.4})
+	_, inDescription := query.Match(Field{Text: "Nothing here", Weight: 1}, Field{Text: "Deploy service", Weight: 0.4})
+	if inTitle <= inDescription {
+		t.Errorf("title rank %v not above description rank %v", inTitle, inDescription)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	got := Parse(`"reset password" -mobile`).Highlight("Please reset the password on mobile", 10)
+	want := "Please <mark>reset</mark> the <mark>password</mark> on mobile"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/naveeshkumar24/internal/models"
+)
+
+// Cursor identifies the last row of a page in keyset pagination: the value of
+// the sort column and the row ID used as tie-breaker. Sort and Order are kept
+// so that a cursor cannot be replayed against a different ordering.
+type Cursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+func EncodeCursor(c Cursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeCursor parses a cursor and checks it belongs to the requested ordering.
+func DecodeCursor(s string, sort, order string) (Cursor, error) {
+	var c Cursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, models.ErrInvalidCursor
+	}
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return c, models.ErrInvalidCursor
+	}
+	if c.Sort != sort || c.Order != order || c.ID == 0 {
+		return c, models.ErrInvalidCursor
+	}
+	return c, nil
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/naveeshkumar24/internal/models"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	want := Cursor{Sort: "due_date", Order: "asc", Value: "2024-05-01", ID: 42}
+
+	got, err := DecodeCursor(EncodeCursor(want), "due_date", "asc")
+	if err != nil {
+		t.Fatalf("DecodeCursor: %v", err)
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	valid := EncodeCursor(Cursor{Sort: "created_at", Order: "desc", Value: "2024-05-01T10:00:00Z", ID: 7})
+	raw, _ := base64.RawURLEncoding.DecodeString(valid)
+	tampered := base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(raw), `"id":7`, `"id":"7"`, 1)))
+
+	tests := []struct {
+		name   string
+		cursor string
+		sort   string
+		order  string
+	}{
+		{"garbage", "not a cursor!", "created_at", "desc"},
+		{"empty", "", "created_at", "desc"},
+		{"tampered", tampered, "created_at", "desc"},
+		{"truncated", valid[:len(valid)-4], "created_at", "desc"},
+		{"not JSON", base64.RawURLEncoding.EncodeToString([]byte("hello")), "created_at", "desc"},
+		{"missing ID", EncodeCursor(Cursor{Sort: "created_at", Order: "desc", Value: "x"}), "created_at", "desc"},
+		{"other sort", valid, "due_date", "desc"},
+		{"other order", valid, "created_at", "asc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeCursor(tt.cursor, tt.sort, tt.order); !errors.Is(err, models.ErrInvalidCursor) {
+				t.Errorf("got error %v, want %v", err, models.ErrInvalidCursor)
+			}
+		})
+	}
+}
@@ -1,21 +1,108 @@
 package utils
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/naveeshkumar24/pkg/config"
 )
 
-var jwtKey = []byte("your-secret-key") // Use a secure method to handle this key
+// jwtConfig is replaced at startup by ConfigureJWT; the default only exists so
+// that code paths work in dev mode before configuration is loaded.
+var jwtConfig = config.JWTConfig{
+	Keys:       map[string][]byte{"default": []byte(config.PlaceholderSecret)},
+	ActiveKID:  "default",
+	TTL:        15 * time.Minute,
+	RefreshTTL: 30 * 24 * time.Hour,
+}
+
+// Claims holds the identity carried inside every access token. OrgID is the
+// organization every request of the token is confined to.
+type Claims struct {
+	UserID   int    `json:"user_id"`
+	OrgID    int    `json:"org_id"`
+	Email    string `json:"email"`
+	Role     string `json:"role"`
+	Timezone string `json:"tz,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// ConfigureJWT sets the signing keys, lifetime, issuer and audience used for tokens.
+func ConfigureJWT(cfg config.JWTConfig) {
+	jwtConfig = cfg
+}
 
-func GenerateJWT(userID int, email, role string) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"email":   email,
-		"role":    role,
-		"exp":     time.Now().Add(24 * time.Hour).Unix(),
+func GenerateJWT(userID, orgID int, email, role, timezone string) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		UserID:   userID,
+		OrgID:    orgID,
+		Email:    email,
+		Role:     role,
+		Timezone: timezone,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    jwtConfig.Issuer,
+			ExpiresAt: jwt.NewNumericDate(now.Add(jwtConfig.TTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	if jwtConfig.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{jwtConfig.Audience}
+	}
+
+	key, ok := jwtConfig.Keys[jwtConfig.ActiveKID]
+	if !ok {
+		return "", fmt.Errorf("active signing key %q is not configured", jwtConfig.ActiveKID)
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+	token.Header["kid"] = jwtConfig.ActiveKID
+	return token.SignedString(key)
+}
+
+// ParseJWT verifies the signature, expiry, issuer and audience of an HS256
+// token and returns its claims. The verification key is selected by the
+// token's "kid" header so that tokens signed with a rotated-out key keep
+// working as long as that key is still configured.
+func ParseJWT(tokenString string) (*Claims, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	}
+	if jwtConfig.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(jwtConfig.Issuer))
+	}
+	if jwtConfig.Audience != "" {
+		opts = append(opts, jwt.WithAudience(jwtConfig.Audience))
+	}
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" {
+			// Tokens issued before key rotation was introduced carry no kid
+			kid = jwtConfig.ActiveKID
+		}
+		key, ok := jwtConfig.Keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key, nil
+	}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	if claims.UserID == 0 {
+		return nil, errors.New("invalid token: missing user_id")
+	}
+	if claims.OrgID == 0 {
+		// Tokens issued before organizations existed must be refreshed
+		return nil, errors.New("invalid token: missing org_id")
+	}
+	return claims, nil
 }
@@ -0,0 +1,14 @@
+package utils
+
+// GenerateInvitationToken returns a new opaque project invitation token and
+// the hash stored in its place. Invitation tokens have the same shape as
+// refresh tokens.
+func GenerateInvitationToken() (token string, hash string, err error) {
+	return GenerateRefreshToken()
+}
+
+// HashInvitationToken hashes an invitation token the same way it was hashed
+// on issue.
+func HashInvitationToken(token string) string {
+	return HashRefreshToken(token)
+}
@@ -2,17 +2,174 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/naveeshkumar24/pkg/config"
 )
 
+// maxBodyBytes is replaced at startup by ConfigureDecoding.
+var maxBodyBytes int64 = config.DefaultMaxBodyBytes
+
+// ConfigureDecoding sets the largest request body Decode and ReadBody accept.
+func ConfigureDecoding(maxBytes int64) {
+	maxBodyBytes = maxBytes
+}
+
+// DecodeError describes why a request body was rejected, precisely enough
+// for the client to fix it.
+type DecodeError struct {
+	Status int    // 400, 413 or 415
+	Msg    string // human-readable explanation
+	Field  string // dotted JSON path of the offending field, if known
+	Offset int64  // byte offset in the body, if known
+}
+
+func (e *DecodeError) Error() string { return e.Msg }
+
+// Decode reads a single JSON value from the request body into arg. The body
+// must be declared as JSON, stay within the configured size limit, contain
+// only fields known to arg and nothing after the value. Failures are returned
+// as *DecodeError.
 func Decode(r *http.Request, arg any) error {
-	err := json.NewDecoder(r.Body).Decode(arg)
-	if err != nil {
+	if err := checkJSONContentType(r); err != nil {
 		return err
 	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(arg); err != nil {
+		return decodeError(err, arg)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return decodeError(err, arg)
+		}
+		return &DecodeError{
+			Status: http.StatusBadRequest,
+			Msg:    fmt.Sprintf("request body must contain a single JSON value; found more data at byte offset %d", dec.InputOffset()),
+			Offset: dec.InputOffset(),
+		}
+	}
 	return nil
 }
 
+// ReadBody returns the raw request body, enforcing the same size limit as
+// Decode. It is meant for handlers that interpret the body themselves.
+func ReadBody(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodyBytes))
+	var maxErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxErr):
+		return nil, tooLarge(maxErr.Limit)
+	case err != nil:
+		return nil, &DecodeError{Status: http.StatusBadRequest, Msg: "could not read request body: " + err.Error()}
+	}
+	return body, nil
+}
+
+func checkJSONContentType(r *http.Request) error {
+	header := r.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(header)
+	if header == "" || err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+		return &DecodeError{
+			Status: http.StatusUnsupportedMediaType,
+			Msg:    fmt.Sprintf("Content-Type must be application/json, got %q", header),
+		}
+	}
+	return nil
+}
+
+// decodeError translates encoding/json failures into a DecodeError.
+func decodeError(err error, arg any) error {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		maxErr    *http.MaxBytesError
+	)
+	switch {
+	case errors.As(err, &maxErr):
+		return tooLarge(maxErr.Limit)
+
+	case errors.Is(err, io.EOF):
+		return &DecodeError{Status: http.StatusBadRequest, Msg: "request body must not be empty"}
+
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &DecodeError{Status: http.StatusBadRequest, Msg: "request body contains incomplete JSON"}
+
+	case errors.As(err, &syntaxErr):
+		return &DecodeError{
+			Status: http.StatusBadRequest,
+			Msg:    fmt.Sprintf("malformed JSON at byte offset %d: %s", syntaxErr.Offset, strings.TrimPrefix(syntaxErr.Error(), "json: ")),
+			Offset: syntaxErr.Offset,
+		}
+
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return &DecodeError{
+				Status: http.StatusBadRequest,
+				Msg:    fmt.Sprintf("request body must be %s, got JSON %s", describeType(reflect.TypeOf(arg)), typeErr.Value),
+				Offset: typeErr.Offset,
+			}
+		}
+		return &DecodeError{
+			Status: http.StatusBadRequest,
+			Msg: fmt.Sprintf("field %q must be %s, got JSON %s (byte offset %d)",
+				typeErr.Field, describeType(typeErr.Type), typeErr.Value, typeErr.Offset),
+			Field:  typeErr.Field,
+			Offset: typeErr.Offset,
+		}
+
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return &DecodeError{
+			Status: http.StatusBadRequest,
+			Msg:    fmt.Sprintf("unknown field %q", field),
+			Field:  field,
+		}
+	}
+
+	// Errors returned by custom UnmarshalJSON methods, such as due dates
+	return &DecodeError{Status: http.StatusBadRequest, Msg: err.Error()}
+}
+
+func tooLarge(limit int64) *DecodeError {
+	return &DecodeError{
+		Status: http.StatusRequestEntityTooLarge,
+		Msg:    fmt.Sprintf("request body must not exceed %d bytes", limit),
+	}
+}
+
+// describeType names a Go type the way a JSON client thinks of it.
+func describeType(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	}
+	return t.String()
+}
+
 func Encode(r http.ResponseWriter, arg any) error {
 	err := json.NewEncoder(r).Encode(arg)
 	if err != nil {
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/naveeshkumar24/pkg/config"
+)
+
+type decodeTarget struct {
+	Title string `json:"title"`
+	Owner struct {
+		ID int `json:"id"`
+	} `json:"owner"`
+	Tags []string `json:"tags"`
+}
+
+func TestDecode(t *testing.T) {
+	ConfigureDecoding(64)
+	t.Cleanup(func() { ConfigureDecoding(config.DefaultMaxBodyBytes) })
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int    // 0 when decoding must succeed
+		field       string // expected DecodeError.Field
+	}{
+		{"valid", "application/json", `{"title":"a","owner":{"id":1}}`, 0, ""},
+		{"content type parameters", "application/json; charset=utf-8", `{"title":"a"}`, 0, ""},
+		{"json suffix", "application/merge-patch+json", `{"title":"a"}`, 0, ""},
+		{"trailing whitespace", "application/json", "{\"title\":\"a\"}\n\t ", 0, ""},
+
+		{"missing content type", "", `{"title":"a"}`, http.StatusUnsupportedMediaType, ""},
+		{"wrong content type", "text/plain", `{"title":"a"}`, http.StatusUnsupportedMediaType, ""},
+		{"form content type", "application/x-www-form-urlencoded", `title=a`, http.StatusUnsupportedMediaType, ""},
+		{"oversize body", "application/json", `{"title":"` + strings.Repeat("x", 100) + `"}`, http.StatusRequestEntityTooLarge, ""},
+		{"oversize trailing data", "application/json", `{"title":"a"}` + strings.Repeat(" ", 100) + `{}`, http.StatusRequestEntityTooLarge, ""},
+		{"unknown field", "application/json", `{"title":"a","colour":"red"}`, http.StatusBadRequest, "colour"},
+		{"trailing value", "application/json", `{"title":"a"} {"title":"b"}`, http.StatusBadRequest, ""},
+		{"trailing garbage", "application/json", `{"title":"a"}x`, http.StatusBadRequest, ""},
+		{"type mismatch", "application/json", `{"title":5}`, http.StatusBadRequest, "title"},
+		{"nested type mismatch", "application/json", `{"owner":{"id":"one"}}`, http.StatusBadRequest, "owner.id"},
+		{"element type mismatch", "application/json", `{"tags":["a",2]}`, http.StatusBadRequest, "tags.1"},
+		{"wrong top-level type", "application/json", `[1,2]`, http.StatusBadRequest, ""},
+		{"empty body", "application/json", ``, http.StatusBadRequest, ""},
+		{"incomplete JSON", "application/json", `{"title":`, http.StatusBadRequest, ""},
+		{"malformed JSON", "application/json", `{"title" "a"}`, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+
+			var target decodeTarget
+			err := Decode(r, &target)
+			if tt.status == 0 {
+				if err != nil {
+					t.Fatalf("Decode: %v", err)
+				}
+				return
+			}
+
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Fatalf("got error %v (%T), want *DecodeError", err, err)
+			}
+			if decodeErr.Status != tt.status {
+				t.Errorf("Status = %d, want %d (%s)", decodeErr.Status, tt.status, decodeErr.Msg)
+			}
+			if decodeErr.Field != tt.field {
+				t.Errorf("Field = %q, want %q (%s)", decodeErr.Field, tt.field, decodeErr.Msg)
+			}
+			if decodeErr.Msg == "" {
+				t.Error("Msg is empty")
+			}
+		})
+	}
+}
+
+func TestReadBody(t *testing.T) {
+	ConfigureDecoding(8)
+	t.Cleanup(func() { ConfigureDecoding(config.DefaultMaxBodyBytes) })
+
+	body, err := ReadBody(httptest.NewRequest("POST", "/", strings.NewReader("12345678")))
+	if err != nil || string(body) != "12345678" {
+		t.Errorf("ReadBody = %q, %v", body, err)
+	}
+
+	_, err = ReadBody(httptest.NewRequest("POST", "/", strings.NewReader("123456789")))
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.Status != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversize body: got %v, want a 413 DecodeError", err)
+	}
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+// GenerateRefreshToken returns a new opaque refresh token together with the
+// SHA-256 hash that is persisted in place of the token itself.
+func GenerateRefreshToken() (token string, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashRefreshToken(token), nil
+}
+
+// HashRefreshToken hashes a refresh token the same way it was hashed on issue.
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewTokenFamily returns a random identifier shared by all refresh tokens
+// descending from a single login.
+func NewTokenFamily() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// AccessTokenTTL is the lifetime of access tokens issued by GenerateJWT.
+func AccessTokenTTL() time.Duration {
+	return jwtConfig.TTL
+}
+
+// RefreshTokenTTL is the lifetime of newly issued refresh tokens.
+func RefreshTokenTTL() time.Duration {
+	return jwtConfig.RefreshTTL
+}
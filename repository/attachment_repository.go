@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/naveeshkumar24/internal/models"
+	"github.com/naveeshkumar24/pkg/database"
+)
+
+type AttachmentRepository struct {
+	db    *sql.DB
+	orgID int
+}
+
+func NewAttachmentRepository(db *sql.DB) *AttachmentRepository {
+	return &AttachmentRepository{
+		db: db,
+	}
+}
+
+// ForOrg returns the repository confined to the organization orgID.
+func (a *AttachmentRepository) ForOrg(orgID int) models.AttachmentInterface {
+	return &AttachmentRepository{db: a.db, orgID: orgID}
+}
+
+func (a *AttachmentRepository) CreateAttachment(attachment models.Attachment) (models.Attachment, error) {
+	query := database.NewOrgQuery(a.db, a.orgID)
+	defer query.Close()
+	created, err := query.CreateAttachment(attachment)
+	if err != nil {
+		log.Printf("Repository: Failed to create attachment: %v", err)
+		return models.Attachment{}, err
+	}
+	return created, nil
+}
+
+func (a *AttachmentRepository) GetAttachment(id int) (models.Attachment, error) {
+	query := database.NewOrgQuery(a.db, a.orgID)
+	defer query.Close()
+	attachment, err := query.GetAttachment(id)
+	if err != nil {
+		log.Printf("Repository: Failed to fetch attachment by ID: %v", err)
+		return models.Attachment{}, err
+	}
+	return attachment, nil
+}
+
+func (a *AttachmentRepository) ListAttachments(taskID int) ([]models.Attachment, error) {
+	query := database.NewOrgQuery(a.db, a.orgID)
+	defer query.Close()
+	attachments, err := query.ListAttachments(taskID)
+	if err != nil {
+		log.Printf("Repository: Failed to list attachments: %v", err)
+		return nil, err
+	}
+	return attachments, nil
+}
+
+func (a *AttachmentRepository) DeleteAttachment(id int) error {
+	query := database.NewOrgQuery(a.db, a.orgID)
+	defer query.Close()
+	err := query.DeleteAttachment(id)
+	if err != nil {
+		log.Printf("Repository: Failed to delete attachment: %v", err)
+		return err
+	}
+	return nil
+}
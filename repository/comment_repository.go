@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/naveeshkumar24/internal/models"
+	"github.com/naveeshkumar24/pkg/database"
+)
+
+type CommentRepository struct {
+	db    *sql.DB
+	orgID int
+}
+
+func NewCommentRepository(db *sql.DB) *CommentRepository {
+	return &CommentRepository{
+		db: db,
+	}
+}
+
+// ForOrg returns the repository confined to the organization orgID.
+func (c *CommentRepository) ForOrg(orgID int) models.CommentInterface {
+	return &CommentRepository{db: c.db, orgID: orgID}
+}
+
+func (c *CommentRepository) CreateComment(comment models.Comment) (models.Comment, error) {
+	query := database.NewOrgQuery(c.db, c.orgID)
+	defer query.Close()
+	created, err := query.CreateComment(comment)
+	if err != nil {
+		log.Printf("Repository: Failed to create comment: %v", err)
+		return models.Comment{}, err
+	}
+	return created, nil
+}
+
+func (c *CommentRepository) GetComment(id int) (models.Comment, error) {
+	query := database.NewOrgQuery(c.db, c.orgID)
+	defer query.Close()
+	comment, err := query.GetComment(id)
+	if err != nil {
+		log.Printf("Repository: Failed to fetch comment by ID: %v", err)
+		return models.Comment{}, err
+	}
+	return comment, nil
+}
+
+func (c *CommentRepository) UpdateComment(id int, body string) (models.Comment, error) {
+	query := database.NewOrgQuery(c.db, c.orgID)
+	defer query.Close()
+	comment, err := query.UpdateComment(id, body)
+	if err != nil {
+		log.Printf("Repository: Failed to update comment: %v", err)
+		return models.Comment{}, err
+	}
+	return comment, nil
+}
+
+func (c *CommentRepository) DeleteComment(id int) error {
+	query := database.NewOrgQuery(c.db, c.orgID)
+	defer query.Close()
+	err := query.DeleteComment(id)
+	if err != nil {
+		log.Printf("Repository: Failed to delete comment: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (c *CommentRepository) ListComments(taskID, parentID int, opts models.ListOptions) (models.CommentPage, error) {
+	query := database.NewOrgQuery(c.db, c.orgID)
+	defer query.Close()
+	page, err := query.ListComments(taskID, parentID, opts)
+	if err != nil {
+		log.Printf("Repository: Failed to list comments: %v", err)
+		return models.CommentPage{}, err
+	}
+	return page, nil
+}
@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/naveeshkumar24/internal/models"
+	"github.com/naveeshkumar24/pkg/database"
+)
+
+type MembershipRepository struct {
+	db    *sql.DB
+	orgID int
+}
+
+func NewMembershipRepository(db *sql.DB) *MembershipRepository {
+	return &MembershipRepository{
+		db: db,
+	}
+}
+
+// ForOrg returns the repository confined to the organization orgID.
+func (m *MembershipRepository) ForOrg(orgID int) models.MembershipInterface {
+	return &MembershipRepository{db: m.db, orgID: orgID}
+}
+
+func (m *MembershipRepository) SetProjectMember(projectID, userID int, role string) error {
+	query := database.NewOrgQuery(m.db, m.orgID)
+	defer query.Close()
+	err := query.SetProjectMember(projectID, userID, role)
+	if err != nil {
+		log.Printf("Repository: Failed to set project member: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (m *MembershipRepository) RemoveProjectMember(projectID, userID int) error {
+	query := database.NewOrgQuery(m.db, m.orgID)
+	defer query.Close()
+	err := query.RemoveProjectMember(projectID, userID)
+	if err != nil {
+		log.Printf("Repository: Failed to remove project member: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (m *MembershipRepository) ListProjectMembers(projectID int) ([]models.ProjectMember, error) {
+	query := database.NewOrgQuery(m.db, m.orgID)
+	defer query.Close()
+	members, err := query.ListProjectMembers(projectID)
+	if err != nil {
+		log.Printf("Repository: Failed to list project members: %v", err)
+		return nil, err
+	}
+	return members, nil
+}
+
+func (m *MembershipRepository) SetProjectTeam(projectID, teamID int, role string) error {
+	query := database.NewOrgQuery(m.db, m.orgID)
+	defer query.Close()
+	err := query.SetProjectTeam(projectID, teamID, role)
+	if err != nil {
+		log.Printf("Repository: Failed to set project team: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (m *MembershipRepository) RemoveProjectTeam(projectID, teamID int) error {
+	query := database.NewOrgQuery(m.db, m.orgID)
+	defer query.Close()
+	err := query.RemoveProjectTeam(projectID, teamID)
+	if err != nil {
+		log.Printf("Repository: Failed to remove project team: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (m *MembershipRepository) ListProjectTeams(projectID int) ([]models.ProjectTeam, error) {
+	query := database.NewOrgQuery(m.db, m.orgID)
+	defer query.Close()
+	teams, err := query.ListProjectTeams(projectID)
+	if err != nil {
+		log.Printf("Repository: Failed to list project teams: %v", err)
+		return nil, err
+	}
+	return teams, nil
+}
+
+func (m *MembershipRepository) GetProjectRole(projectID, userID int) (string, error) {
+	query := database.NewOrgQuery(m.db, m.orgID)
+	defer query.Close()
+	role, err := query.GetProjectRole(projectID, userID)
+	if err != nil {
+		log.Printf("Repository: Failed to resolve project role: %v", err)
+		return "", err
+	}
+	return role, nil
+}
+
+func (m *MembershipRepository) ListMemberProjects(userID int) ([]int, error) {
+	query := database.NewOrgQuery(m.db, m.orgID)
+	defer query.Close()
+	ids, err := query.ListMemberProjects(userID)
+	if err != nil {
+		log.Printf("Repository: Failed to list member projects: %v", err)
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (m *MembershipRepository) CreateInvitation(invitation models.Invitation) (models.Invitation, error) {
+	query := database.NewOrgQuery(m.db, m.orgID)
+	defer query.Close()
+	created, err := query.CreateInvitation(invitation)
+	if err != nil {
+		log.Printf("Repository: Failed to create invitation: %v", err)
+		return models.Invitation{}, err
+	}
+	return created, nil
+}
+
+func (m *MembershipRepository) GetInvitationByToken(tokenHash string) (models.Invitation, error) {
+	query := database.NewOrgQuery(m.db, m.orgID)
+	defer query.Close()
+	invitation, err := query.GetInvitationByToken(tokenHash)
+	if err != nil {
+		log.Printf("Repository: Failed to fetch invitation: %v", err)
+		return models.Invitation{}, err
+	}
+	return invitation, nil
+}
+
+func (m *MembershipRepository) ListInvitations(projectID int) ([]models.Invitation, error) {
+	query := database.NewOrgQuery(m.db, m.orgID)
+	defer query.Close()
+	invitations, err := query.ListInvitations(projectID)
+	if err != nil {
+		log.Printf("Repository: Failed to list invitations: %v", err)
+		return nil, err
+	}
+	return invitations, nil
+}
+
+func (m *MembershipRepository) DeleteInvitation(projectID, id int) error {
+	query := database.NewOrgQuery(m.db, m.orgID)
+	defer query.Close()
+	err := query.DeleteInvitation(projectID, id)
+	if err != nil {
+		log.Printf("Repository: Failed to delete invitation: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (m *MembershipRepository) AcceptInvitation(id, userID int) error {
+	query := database.NewOrgQuery(m.db, m.orgID)
+	defer query.Close()
+	err := query.AcceptInvitation(id, userID)
+	if err != nil {
+		log.Printf("Repository: Failed to accept invitation: %v", err)
+		return err
+	}
+	return nil
+}
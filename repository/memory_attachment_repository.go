@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/naveeshkumar24/internal/models"
+)
+
+// MemoryAttachmentRepository implements models.AttachmentInterface on top of
+// a MemoryStore.
+type MemoryAttachmentRepository struct {
+	store *MemoryStore
+	orgID int
+}
+
+func NewMemoryAttachmentRepository(store *MemoryStore) *MemoryAttachmentRepository {
+	return &MemoryAttachmentRepository{store: store}
+}
+
+// ForOrg returns the repository confined to the organization orgID.
+func (a *MemoryAttachmentRepository) ForOrg(orgID int) models.AttachmentInterface {
+	return &MemoryAttachmentRepository{store: a.store, orgID: orgID}
+}
+
+func (a *MemoryAttachmentRepository) CreateAttachment(attachment models.Attachment) (models.Attachment, error) {
+	a.store.mu.Lock()
+	defer a.store.mu.Unlock()
+
+	if _, ok := a.store.orgTask(a.orgID, attachment.TaskID); !ok {
+		return models.Attachment{}, &models.ConstraintError{Err: models.ErrReferenceNotFound, Field: "task_id"}
+	}
+	if _, ok := a.store.orgUser(a.orgID, attachment.UploadedBy); !ok {
+		return models.Attachment{}, &models.ConstraintError{Err: models.ErrReferenceNotFound, Field: "uploaded_by"}
+	}
+
+	attachment.ID = a.store.nextAttachmentID
+	attachment.CreatedAt = time.Now().UTC()
+	a.store.nextAttachmentID++
+	a.store.attachments[attachment.ID] = attachment
+	return attachment, nil
+}
+
+func (a *MemoryAttachmentRepository) GetAttachment(id int) (models.Attachment, error) {
+	a.store.mu.RLock()
+	defer a.store.mu.RUnlock()
+
+	attachment, ok := a.attachment(id)
+	if !ok {
+		return models.Attachment{}, sql.ErrNoRows
+	}
+	return attachment, nil
+}
+
+func (a *MemoryAttachmentRepository) ListAttachments(taskID int) ([]models.Attachment, error) {
+	a.store.mu.RLock()
+	defer a.store.mu.RUnlock()
+
+	attachments := []models.Attachment{}
+	if _, ok := a.store.orgTask(a.orgID, taskID); !ok {
+		return attachments, nil
+	}
+	for _, attachment := range a.store.attachments {
+		if attachment.TaskID == taskID {
+			attachments = append(attachments, attachment)
+		}
+	}
+	sort.Slice(attachments, func(i, j int) bool { return attachments[i].ID < attachments[j].ID })
+	return attachments, nil
+}
+
+func (a *MemoryAttachmentRepository) DeleteAttachment(id int) error {
+	a.store.mu.Lock()
+	defer a.store.mu.Unlock()
+
+	if _, ok := a.attachment(id); !ok {
+		return sql.ErrNoRows
+	}
+	delete(a.store.attachments, id)
+	return nil
+}
+
+// attachment finds an attachment of a task of the organization. The caller
+// must hold the store lock.
+func (a *MemoryAttachmentRepository) attachment(id int) (models.Attachment, bool) {
+	attachment, ok := a.store.attachments[id]
+	if !ok {
+		return models.Attachment{}, false
+	}
+	_, ok = a.store.orgTask(a.orgID, attachment.TaskID)
+	return attachment, ok
+}
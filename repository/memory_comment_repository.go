@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"database/sql"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/naveeshkumar24/internal/models"
+	"github.com/naveeshkumar24/pkg/utils"
+)
+
+// MemoryCommentRepository implements models.CommentInterface on top of a
+// MemoryStore.
+type MemoryCommentRepository struct {
+	store *MemoryStore
+	orgID int
+}
+
+func NewMemoryCommentRepository(store *MemoryStore) *MemoryCommentRepository {
+	return &MemoryCommentRepository{store: store}
+}
+
+// ForOrg returns the repository confined to the organization orgID.
+func (c *MemoryCommentRepository) ForOrg(orgID int) models.CommentInterface {
+	return &MemoryCommentRepository{store: c.store, orgID: orgID}
+}
+
+func (c *MemoryCommentRepository) CreateComment(comment models.Comment) (models.Comment, error) {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+
+	if _, ok := c.store.orgTask(c.orgID, comment.TaskID); !ok {
+		return models.Comment{}, &models.ConstraintError{Err: models.ErrReferenceNotFound, Field: "task_id"}
+	}
+	if _, ok := c.store.orgUser(c.orgID, comment.AuthorID); !ok {
+		return models.Comment{}, &models.ConstraintError{Err: models.ErrReferenceNotFound, Field: "author_id"}
+	}
+	if comment.ParentID != 0 {
+		parent, ok := c.store.comments[comment.ParentID]
+		if !ok || parent.TaskID != comment.TaskID {
+			return models.Comment{}, &models.ConstraintError{Err: models.ErrReferenceNotFound, Field: "parent_id"}
+		}
+	}
+
+	now := time.Now().UTC()
+	comment.ID = c.store.nextCommentID
+	comment.Mentions = c.resolveMentions(comment.Body)
+	comment.ReplyCount = 0
+	comment.CreatedAt = now
+	comment.UpdatedAt = now
+	c.store.nextCommentID++
+	c.store.comments[comment.ID] = comment
+	return comment, nil
+}
+
+func (c *MemoryCommentRepository) GetComment(id int) (models.Comment, error) {
+	c.store.mu.RLock()
+	defer c.store.mu.RUnlock()
+
+	comment, ok := c.comment(id)
+	if !ok {
+		return models.Comment{}, sql.ErrNoRows
+	}
+	return c.withReplyCount(comment), nil
+}
+
+func (c *MemoryCommentRepository) UpdateComment(id int, body string) (models.Comment, error) {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+
+	comment, ok := c.comment(id)
+	if !ok {
+		return models.Comment{}, sql.ErrNoRows
+	}
+	comment.Body = body
+	comment.Mentions = c.resolveMentions(body)
+	comment.UpdatedAt = time.Now().UTC()
+	c.store.comments[id] = comment
+	return c.withReplyCount(comment), nil
+}
+
+// DeleteComment removes a comment together with its replies, like the
+// ON DELETE CASCADE of the Postgres schema.
+func (c *MemoryCommentRepository) DeleteComment(id int) error {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+
+	if _, ok := c.comment(id); !ok {
+		return sql.ErrNoRows
+	}
+	doomed := []int{id}
+	for len(doomed) > 0 {
+		current := doomed[0]
+		doomed = doomed[1:]
+		delete(c.store.comments, current)
+		for childID, child := range c.store.comments {
+			if child.ParentID == current {
+				doomed = append(doomed, childID)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *MemoryCommentRepository) ListComments(taskID, parentID int, opts models.ListOptions) (models.CommentPage, error) {
+	page := models.CommentPage{Items: []models.Comment{}}
+
+	afterID := 0
+	if opts.After != "" {
+		cursor, err := utils.DecodeCursor(opts.After, models.CommentSort, models.SortAsc)
+		if err != nil {
+			return page, err
+		}
+		afterID = cursor.ID
+	}
+
+	c.store.mu.RLock()
+	defer c.store.mu.RUnlock()
+
+	var matches []models.Comment
+	for _, comment := range c.store.comments {
+		if comment.TaskID == taskID && comment.ParentID == parentID && c.store.tasks[taskID].OrgID == c.orgID {
+			matches = append(matches, comment)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].ID < matches[j].ID })
+	page.Total = len(matches)
+
+	for _, comment := range matches {
+		if comment.ID <= afterID {
+			continue
+		}
+		if len(page.Items) == opts.Limit {
+			last := page.Items[len(page.Items)-1].ID
+			page.NextCursor = utils.EncodeCursor(utils.Cursor{
+				Sort:  models.CommentSort,
+				Order: models.SortAsc,
+				Value: strconv.Itoa(last),
+				ID:    last,
+			})
+			break
+		}
+		page.Items = append(page.Items, c.withReplyCount(comment))
+	}
+	return page, nil
+}
+
+// comment finds a comment on a task of the organization. The caller must
+// hold the store lock.
+func (c *MemoryCommentRepository) comment(id int) (models.Comment, bool) {
+	comment, ok := c.store.comments[id]
+	if !ok {
+		return models.Comment{}, false
+	}
+	_, ok = c.store.orgTask(c.orgID, comment.TaskID)
+	return comment, ok
+}
+
+// resolveMentions returns the mentioned usernames that belong to users of
+// the organization, sorted like the Postgres query. The caller must hold
+// the store lock.
+func (c *MemoryCommentRepository) resolveMentions(body string) []string {
+	mentions := []string{}
+	for _, name := range models.ParseMentions(body) {
+		for _, user := range c.store.users {
+			if user.Username == name && user.OrgID == c.orgID {
+				mentions = append(mentions, name)
+				break
+			}
+		}
+	}
+	sort.Strings(mentions)
+	return mentions
+}
+
+// withReplyCount fills in ReplyCount. The caller must hold the store lock.
+func (c *MemoryCommentRepository) withReplyCount(comment models.Comment) models.Comment {
+	comment.ReplyCount = 0
+	for _, other := range c.store.comments {
+		if other.ParentID == comment.ID {
+			comment.ReplyCount++
+		}
+	}
+	return comment
+}
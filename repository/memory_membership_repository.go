@@ -0,0 +1,277 @@
+package repository
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/naveeshkumar24/internal/models"
+	"github.com/naveeshkumar24/internal/rbac"
+)
+
+// MemoryMembershipRepository implements models.MembershipInterface on top
+// of a MemoryStore.
+type MemoryMembershipRepository struct {
+	store *MemoryStore
+	orgID int
+}
+
+func NewMemoryMembershipRepository(store *MemoryStore) *MemoryMembershipRepository {
+	return &MemoryMembershipRepository{store: store}
+}
+
+// ForOrg returns the repository confined to the organization orgID.
+func (m *MemoryMembershipRepository) ForOrg(orgID int) models.MembershipInterface {
+	return &MemoryMembershipRepository{store: m.store, orgID: orgID}
+}
+
+func (m *MemoryMembershipRepository) SetProjectMember(projectID, userID int, role string) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if err := m.checkProject(projectID); err != nil {
+		return err
+	}
+	if _, ok := m.store.orgUser(m.orgID, userID); !ok {
+		return &models.ConstraintError{Err: models.ErrReferenceNotFound, Field: "user_id"}
+	}
+	m.setMember(projectID, userID, role)
+	return nil
+}
+
+func (m *MemoryMembershipRepository) RemoveProjectMember(projectID, userID int) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if _, ok := m.store.projectMembers[projectID][userID]; !ok || !m.inOrg(projectID) {
+		return sql.ErrNoRows
+	}
+	delete(m.store.projectMembers[projectID], userID)
+	return nil
+}
+
+func (m *MemoryMembershipRepository) ListProjectMembers(projectID int) ([]models.ProjectMember, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	members := []models.ProjectMember{}
+	if !m.inOrg(projectID) {
+		return members, nil
+	}
+	for userID, role := range m.store.projectMembers[projectID] {
+		members = append(members, models.ProjectMember{
+			ProjectID: projectID,
+			UserID:    userID,
+			Username:  m.store.users[userID].Username,
+			Role:      role,
+		})
+	}
+	for teamID, role := range m.store.projectTeams[projectID] {
+		for userID := range m.store.teamMembers[teamID] {
+			members = append(members, models.ProjectMember{
+				ProjectID: projectID,
+				UserID:    userID,
+				Username:  m.store.users[userID].Username,
+				Role:      role,
+				TeamID:    teamID,
+			})
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].TeamID != members[j].TeamID {
+			return members[i].TeamID < members[j].TeamID
+		}
+		return members[i].Username < members[j].Username
+	})
+	return members, nil
+}
+
+func (m *MemoryMembershipRepository) SetProjectTeam(projectID, teamID int, role string) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if err := m.checkProject(projectID); err != nil {
+		return err
+	}
+	if _, ok := m.store.orgTeam(m.orgID, teamID); !ok {
+		return &models.ConstraintError{Err: models.ErrReferenceNotFound, Field: "team_id"}
+	}
+	if m.store.projectTeams[projectID] == nil {
+		m.store.projectTeams[projectID] = make(map[int]string)
+	}
+	m.store.projectTeams[projectID][teamID] = role
+	return nil
+}
+
+func (m *MemoryMembershipRepository) RemoveProjectTeam(projectID, teamID int) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if _, ok := m.store.projectTeams[projectID][teamID]; !ok || !m.inOrg(projectID) {
+		return sql.ErrNoRows
+	}
+	delete(m.store.projectTeams[projectID], teamID)
+	return nil
+}
+
+func (m *MemoryMembershipRepository) ListProjectTeams(projectID int) ([]models.ProjectTeam, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	teams := []models.ProjectTeam{}
+	if !m.inOrg(projectID) {
+		return teams, nil
+	}
+	for teamID, role := range m.store.projectTeams[projectID] {
+		teams = append(teams, models.ProjectTeam{
+			ProjectID: projectID,
+			TeamID:    teamID,
+			TeamName:  m.store.teams[teamID].Name,
+			Role:      role,
+		})
+	}
+	sort.Slice(teams, func(i, j int) bool { return teams[i].TeamName < teams[j].TeamName })
+	return teams, nil
+}
+
+func (m *MemoryMembershipRepository) GetProjectRole(projectID, userID int) (string, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	if !m.inOrg(projectID) {
+		return "", nil
+	}
+	return m.projectRole(projectID, userID), nil
+}
+
+func (m *MemoryMembershipRepository) ListMemberProjects(userID int) ([]int, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	ids := []int{}
+	for projectID, project := range m.store.projects {
+		if project.OrgID == m.orgID && m.projectRole(projectID, userID) != "" {
+			ids = append(ids, projectID)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+func (m *MemoryMembershipRepository) CreateInvitation(invitation models.Invitation) (models.Invitation, error) {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if err := m.checkProject(invitation.ProjectID); err != nil {
+		return models.Invitation{}, err
+	}
+	for _, existing := range m.store.invitations {
+		if existing.TokenHash == invitation.TokenHash {
+			return models.Invitation{}, &models.ConstraintError{Err: models.ErrDuplicate, Field: "token"}
+		}
+	}
+
+	invitation.ID = m.store.nextInvitationID
+	invitation.OrgID = m.orgID
+	invitation.AcceptedAt = nil
+	invitation.CreatedAt = time.Now().UTC()
+	m.store.nextInvitationID++
+	m.store.invitations[invitation.ID] = invitation
+	return invitation, nil
+}
+
+func (m *MemoryMembershipRepository) GetInvitationByToken(tokenHash string) (models.Invitation, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	for _, invitation := range m.store.invitations {
+		if invitation.TokenHash == tokenHash && (m.orgID == 0 || m.inOrg(invitation.ProjectID)) {
+			return invitation, nil
+		}
+	}
+	return models.Invitation{}, sql.ErrNoRows
+}
+
+func (m *MemoryMembershipRepository) ListInvitations(projectID int) ([]models.Invitation, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	now := time.Now()
+	invitations := []models.Invitation{}
+	for _, invitation := range m.store.invitations {
+		if invitation.ProjectID == projectID && invitation.Pending(now) && m.inOrg(projectID) {
+			invitations = append(invitations, invitation)
+		}
+	}
+	sort.Slice(invitations, func(i, j int) bool { return invitations[i].ID > invitations[j].ID })
+	return invitations, nil
+}
+
+func (m *MemoryMembershipRepository) DeleteInvitation(projectID, id int) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	invitation, ok := m.store.invitations[id]
+	if !ok || invitation.ProjectID != projectID || !m.inOrg(projectID) {
+		return sql.ErrNoRows
+	}
+	delete(m.store.invitations, id)
+	return nil
+}
+
+func (m *MemoryMembershipRepository) AcceptInvitation(id, userID int) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	invitation, ok := m.store.invitations[id]
+	now := time.Now().UTC()
+	if !ok || !invitation.Pending(now) || !m.inOrg(invitation.ProjectID) {
+		return models.ErrInvitationInvalid
+	}
+	if _, ok := m.store.orgUser(m.orgID, userID); !ok {
+		return &models.ConstraintError{Err: models.ErrReferenceNotFound, Field: "user_id"}
+	}
+
+	invitation.AcceptedAt = &now
+	m.store.invitations[id] = invitation
+	if current := m.store.projectMembers[invitation.ProjectID][userID]; rbac.RoleRank(current) < rbac.RoleRank(invitation.Role) {
+		m.setMember(invitation.ProjectID, userID, invitation.Role)
+	}
+	return nil
+}
+
+// checkProject reports a project missing from the organization the way
+// the foreign key would. The caller must hold the store lock.
+func (m *MemoryMembershipRepository) checkProject(projectID int) error {
+	if !m.inOrg(projectID) {
+		return &models.ConstraintError{Err: models.ErrReferenceNotFound, Field: "project_id"}
+	}
+	return nil
+}
+
+// inOrg reports whether the project belongs to the organization. The caller
+// must hold the store lock.
+func (m *MemoryMembershipRepository) inOrg(projectID int) bool {
+	_, ok := m.store.orgProject(m.orgID, projectID)
+	return ok
+}
+
+// setMember records a direct role. The caller must hold the store lock.
+func (m *MemoryMembershipRepository) setMember(projectID, userID int, role string) {
+	if m.store.projectMembers[projectID] == nil {
+		m.store.projectMembers[projectID] = make(map[int]string)
+	}
+	m.store.projectMembers[projectID][userID] = role
+}
+
+// projectRole returns the highest role the user holds on the project,
+// directly or through a team. The caller must hold the store lock.
+func (m *MemoryMembershipRepository) projectRole(projectID, userID int) string {
+	best := m.store.projectMembers[projectID][userID]
+	for teamID, role := range m.store.projectTeams[projectID] {
+		if m.store.teamMembers[teamID][userID] && rbac.RoleRank(role) > rbac.RoleRank(best) {
+			best = role
+		}
+	}
+	return best
+}
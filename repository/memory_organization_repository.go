@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/naveeshkumar24/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MemoryOrganizationRepository implements models.OrganizationInterface on
+// top of a MemoryStore.
+type MemoryOrganizationRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryOrganizationRepository(store *MemoryStore) *MemoryOrganizationRepository {
+	return &MemoryOrganizationRepository{store: store}
+}
+
+// CreateOrganization - Hashes the admin's password and saves the
+// organization together with its admin
+func (o *MemoryOrganizationRepository) CreateOrganization(org models.Organization, admin models.User) (models.Organization, models.User, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(admin.Password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Printf("Failed to hash password: %v", err)
+		return models.Organization{}, models.User{}, err
+	}
+	admin.Password = string(hashedPassword)
+
+	o.store.mu.Lock()
+	defer o.store.mu.Unlock()
+
+	org.ID = o.store.nextOrgID
+	org.CreatedAt = time.Now().UTC()
+	admin.OrgID = org.ID
+	admin, err = o.store.addUser(admin)
+	if err != nil {
+		return models.Organization{}, models.User{}, err
+	}
+	o.store.nextOrgID++
+	o.store.orgs[org.ID] = org
+	return org, admin, nil
+}
+
+func (o *MemoryOrganizationRepository) GetOrganization(id int) (models.Organization, error) {
+	o.store.mu.RLock()
+	defer o.store.mu.RUnlock()
+
+	org, ok := o.store.orgs[id]
+	if !ok {
+		return models.Organization{}, sql.ErrNoRows
+	}
+	return org, nil
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/naveeshkumar24/internal/models"
+)
+
+// MemoryProjectRepository implements models.ProjectInterface on top of a
+// MemoryStore.
+type MemoryProjectRepository struct {
+	store *MemoryStore
+	orgID int
+}
+
+func NewMemoryProjectRepository(store *MemoryStore) *MemoryProjectRepository {
+	return &MemoryProjectRepository{store: store}
+}
+
+// ForOrg returns the repository confined to the organization orgID.
+func (p *MemoryProjectRepository) ForOrg(orgID int) models.ProjectInterface {
+	return &MemoryProjectRepository{store: p.store, orgID: orgID}
+}
+
+func (p *MemoryProjectRepository) CreateProject(project models.Project) (models.Project, error) {
+	p.store.mu.Lock()
+	defer p.store.mu.Unlock()
+
+	for _, existing := range p.store.projects {
+		if existing.Key == project.Key && existing.OrgID == p.orgID {
+			return models.Project{}, &models.ConstraintError{Err: models.ErrDuplicate, Field: "key"}
+		}
+	}
+	if project.CreatedBy != 0 {
+		if _, ok := p.store.orgUser(p.orgID, project.CreatedBy); !ok {
+			return models.Project{}, &models.ConstraintError{Err: models.ErrReferenceNotFound, Field: "created_by"}
+		}
+	}
+
+	now := time.Now().UTC()
+	project.ID = p.store.nextProjectID
+	project.OrgID = p.orgID
+	project.ArchivedAt = nil
+	project.CreatedAt = now
+	project.UpdatedAt = now
+	project.TaskCount = 0
+	p.store.nextProjectID++
+	p.store.projects[project.ID] = project
+	if project.CreatedBy != 0 {
+		p.store.projectMembers[project.ID] = map[int]string{project.CreatedBy: models.RoleAdmin}
+	}
+	return project, nil
+}
+
+func (p *MemoryProjectRepository) GetProject(id int) (models.Project, error) {
+	p.store.mu.RLock()
+	defer p.store.mu.RUnlock()
+
+	project, ok := p.store.orgProject(p.orgID, id)
+	if !ok {
+		return models.Project{}, sql.ErrNoRows
+	}
+	return p.withTaskCount(project), nil
+}
+
+func (p *MemoryProjectRepository) ListProjects(includeArchived bool) ([]models.Project, error) {
+	p.store.mu.RLock()
+	defer p.store.mu.RUnlock()
+
+	projects := []models.Project{}
+	for _, project := range p.store.projects {
+		if project.OrgID == p.orgID && (includeArchived || !project.Archived()) {
+			projects = append(projects, p.withTaskCount(project))
+		}
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Key < projects[j].Key })
+	return projects, nil
+}
+
+func (p *MemoryProjectRepository) UpdateProject(project models.Project) (models.Project, error) {
+	p.store.mu.Lock()
+	defer p.store.mu.Unlock()
+
+	existing, ok := p.store.orgProject(p.orgID, project.ID)
+	if !ok {
+		return models.Project{}, sql.ErrNoRows
+	}
+	if existing.Archived() {
+		return models.Project{}, models.ErrProjectArchived
+	}
+	existing.Name = project.Name
+	existing.Description = project.Description
+	existing.UpdatedAt = time.Now().UTC()
+	p.store.projects[project.ID] = existing
+	return p.withTaskCount(existing), nil
+}
+
+func (p *MemoryProjectRepository) SetProjectArchived(id int, archived bool) (models.Project, error) {
+	p.store.mu.Lock()
+	defer p.store.mu.Unlock()
+
+	project, ok := p.store.orgProject(p.orgID, id)
+	if !ok {
+		return models.Project{}, sql.ErrNoRows
+	}
+	now := time.Now().UTC()
+	switch {
+	case archived && project.ArchivedAt == nil:
+		project.ArchivedAt = &now
+	case !archived:
+		project.ArchivedAt = nil
+	}
+	project.UpdatedAt = now
+	p.store.projects[id] = project
+	return p.withTaskCount(project), nil
+}
+
+func (p *MemoryProjectRepository) DeleteProject(id int) error {
+	p.store.mu.Lock()
+	defer p.store.mu.Unlock()
+
+	project, ok := p.store.orgProject(p.orgID, id)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if p.withTaskCount(project).TaskCount > 0 {
+		return models.ErrProjectNotEmpty
+	}
+	delete(p.store.projects, id)
+	delete(p.store.taskCounters, id)
+	delete(p.store.projectMembers, id)
+	delete(p.store.projectTeams, id)
+	for invID, invitation := range p.store.invitations {
+		if invitation.ProjectID == id {
+			delete(p.store.invitations, invID)
+		}
+	}
+	return nil
+}
+
+// withTaskCount fills in TaskCount. The caller must hold the store lock.
+func (p *MemoryProjectRepository) withTaskCount(project models.Project) models.Project {
+	project.TaskCount = 0
+	for _, task := range p.store.tasks {
+		if task.ProjectID == project.ID {
+			project.TaskCount++
+		}
+	}
+	return project
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"sync"
+	"time"
+
+	"github.com/naveeshkumar24/internal/models"
+)
+
+// MemoryStore keeps organizations, users, teams, projects and their members,
+// tasks and their dependencies, comments, attachment metadata and refresh
+// tokens in process memory. It is shared by the in-memory repositories so that
+// they see each other's data the same way the Postgres repositories share one
+// database. It is meant for tests and local demos; nothing survives a restart.
+type MemoryStore struct {
+	mu sync.RWMutex
+
+	orgs      map[int]models.Organization
+	nextOrgID int
+
+	users      map[int]models.User
+	nextUserID int
+
+	tasks        map[int]models.Task
+	nextTaskID   int
+	dependencies map[int]map[int]bool // task ID -> IDs of the tasks it depends on
+
+	tokens      map[int]models.RefreshToken
+	nextTokenID int
+
+	comments      map[int]models.Comment
+	nextCommentID int
+
+	attachments      map[int]models.Attachment
+	nextAttachmentID int
+
+	projects      map[int]models.Project
+	nextProjectID int
+	taskCounters  map[int]int // last task number handed out per project
+
+	teams       map[int]models.Team
+	nextTeamID  int
+	teamMembers map[int]map[int]bool // team ID -> user IDs
+
+	projectMembers map[int]map[int]string // project ID -> user ID -> role
+	projectTeams   map[int]map[int]string // project ID -> team ID -> role
+
+	invitations      map[int]models.Invitation
+	nextInvitationID int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		orgs: map[int]models.Organization{
+			models.DefaultOrgID: {ID: models.DefaultOrgID, Name: "Default", CreatedAt: time.Now().UTC()},
+		},
+		nextOrgID: models.DefaultOrgID + 1,
+
+		users:       make(map[int]models.User),
+		nextUserID:  1,
+		tasks:       make(map[int]models.Task),
+		nextTaskID:  1,
+		tokens:      make(map[int]models.RefreshToken),
+		nextTokenID: 1,
+
+		dependencies: make(map[int]map[int]bool),
+
+		comments:      make(map[int]models.Comment),
+		nextCommentID: 1,
+
+		attachments:      make(map[int]models.Attachment),
+		nextAttachmentID: 1,
+
+		projects:      make(map[int]models.Project),
+		nextProjectID: 1,
+		taskCounters:  make(map[int]int),
+
+		teams:       make(map[int]models.Team),
+		nextTeamID:  1,
+		teamMembers: make(map[int]map[int]bool),
+
+		projectMembers: make(map[int]map[int]string),
+		projectTeams:   make(map[int]map[int]string),
+
+		invitations:      make(map[int]models.Invitation),
+		nextInvitationID: 1,
+	}
+}
+
+// The lookups below find a row only if it belongs to organization orgID,
+// like the org_id conditions of the Postgres queries. The caller must hold mu.
+
+func (s *MemoryStore) orgUser(orgID, id int) (models.User, bool) {
+	user, ok := s.users[id]
+	return user, ok && user.OrgID == orgID
+}
+
+func (s *MemoryStore) orgTask(orgID, id int) (models.Task, bool) {
+	task, ok := s.tasks[id]
+	return task, ok && task.OrgID == orgID
+}
+
+func (s *MemoryStore) orgProject(orgID, id int) (models.Project, bool) {
+	project, ok := s.projects[id]
+	return project, ok && project.OrgID == orgID
+}
+
+func (s *MemoryStore) orgTeam(orgID, id int) (models.Team, bool) {
+	team, ok := s.teams[id]
+	return team, ok && team.OrgID == orgID
+}
+
+// commentCount returns the number of comments on a task. The caller must hold mu.
+func (s *MemoryStore) commentCount(taskID int) int {
+	n := 0
+	for _, comment := range s.comments {
+		if comment.TaskID == taskID {
+			n++
+		}
+	}
+	return n
+}
+
+var (
+	_ models.TaskInterface         = (*TaskRepository)(nil)
+	_ models.UserInterface         = (*UserRepository)(nil)
+	_ models.TokenInterface        = (*TokenRepository)(nil)
+	_ models.CommentInterface      = (*CommentRepository)(nil)
+	_ models.AttachmentInterface   = (*AttachmentRepository)(nil)
+	_ models.ProjectInterface      = (*ProjectRepository)(nil)
+	_ models.TeamInterface         = (*TeamRepository)(nil)
+	_ models.MembershipInterface   = (*MembershipRepository)(nil)
+	_ models.OrganizationInterface = (*OrganizationRepository)(nil)
+
+	_ models.TaskInterface         = (*MemoryTaskRepository)(nil)
+	_ models.UserInterface         = (*MemoryUserRepository)(nil)
+	_ models.TokenInterface        = (*MemoryTokenRepository)(nil)
+	_ models.CommentInterface      = (*MemoryCommentRepository)(nil)
+	_ models.AttachmentInterface   = (*MemoryAttachmentRepository)(nil)
+	_ models.ProjectInterface      = (*MemoryProjectRepository)(nil)
+	_ models.TeamInterface         = (*MemoryTeamRepository)(nil)
+	_ models.MembershipInterface   = (*MemoryMembershipRepository)(nil)
+	_ models.OrganizationInterface = (*MemoryOrganizationRepository)(nil)
+)
@@ -0,0 +1,564 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/naveeshkumar24/internal/models"
+	"github.com/naveeshkumar24/pkg/search"
+	"github.com/naveeshkumar24/pkg/utils"
+)
+
+// MemoryTaskRepository implements models.TaskInterface on top of a MemoryStore.
+type MemoryTaskRepository struct {
+	store *MemoryStore
+	orgID int
+}
+
+func NewMemoryTaskRepository(store *MemoryStore) *MemoryTaskRepository {
+	return &MemoryTaskRepository{store: store}
+}
+
+// ForOrg returns the repository confined to the organization orgID.
+func (t *MemoryTaskRepository) ForOrg(orgID int) models.TaskInterface {
+	return &MemoryTaskRepository{store: t.store, orgID: orgID}
+}
+
+// memorySortLayout is fixed-width so that formatted UTC times sort as strings.
+const memorySortLayout = "2006-01-02T15:04:05.000000000Z"
+
+func (t *MemoryTaskRepository) CreateTask(task models.Task) (models.Task, error) {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	if _, ok := t.store.orgUser(t.orgID, task.CreatedBy); !ok {
+		return models.Task{}, &models.ConstraintError{Err: models.ErrReferenceNotFound, Field: "created_by"}
+	}
+	if task.AssignedTo != 0 {
+		if _, ok := t.store.orgUser(t.orgID, task.AssignedTo); !ok {
+			return models.Task{}, &models.ConstraintError{Err: models.ErrReferenceNotFound, Field: "assigned_to"}
+		}
+	}
+
+	var project models.Project
+	if task.ProjectID != 0 {
+		var ok bool
+		project, ok = t.store.orgProject(t.orgID, task.ProjectID)
+		if !ok {
+			return models.Task{}, &models.ConstraintError{Err: models.ErrReferenceNotFound, Field: "project_id"}
+		}
+		if project.Archived() {
+			return models.Task{}, models.ErrProjectArchived
+		}
+	}
+	if err := t.store.checkParent(t.orgID, 0, task.ParentID, task.ProjectID); err != nil {
+		return models.Task{}, err
+	}
+
+	task.Key = ""
+	if task.ProjectID != 0 {
+		t.store.taskCounters[project.ID]++
+		task.Key = fmt.Sprintf("%s-%d", project.Key, t.store.taskCounters[project.ID])
+	}
+
+	now := time.Now().UTC()
+	task.ID = t.store.nextTaskID
+	task.OrgID = t.orgID
+	task.CommentCount = 0
+	task.Progress = models.SubtaskProgress{}
+	task.BlockedBy = nil
+	task.Blocked = false
+	task.CreatedAt = now
+	task.UpdatedAt = now
+	task.Version = 1
+	t.store.nextTaskID++
+	t.store.tasks[task.ID] = task
+	return task, nil
+}
+
+func (t *MemoryTaskRepository) GetTaskByID(id int) (models.Task, error) {
+	t.store.mu.RLock()
+	defer t.store.mu.RUnlock()
+
+	task, ok := t.store.orgTask(t.orgID, id)
+	if !ok {
+		return models.Task{}, sql.ErrNoRows
+	}
+	return t.store.derivedTask(task), nil
+}
+
+func (t *MemoryTaskRepository) GetTaskByKey(key string) (models.Task, error) {
+	t.store.mu.RLock()
+	defer t.store.mu.RUnlock()
+
+	for _, task := range t.store.tasks {
+		if task.Key != "" && task.Key == key && task.OrgID == t.orgID {
+			return t.store.derivedTask(task), nil
+		}
+	}
+	return models.Task{}, sql.ErrNoRows
+}
+
+func (t *MemoryTaskRepository) UpdateTask(task models.Task, check func(stored models.Task) error) error {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	existing, ok := t.store.orgTask(t.orgID, task.ID)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if existing.Version != task.Version {
+		return models.ErrVersionConflict
+	}
+	if err := check(t.store.derivedTask(existing)); err != nil {
+		return err
+	}
+	if err := t.store.checkParent(t.orgID, task.ID, task.ParentID, existing.ProjectID); err != nil {
+		return err
+	}
+	if task.AssignedTo != 0 {
+		if _, ok := t.store.orgUser(t.orgID, task.AssignedTo); !ok {
+			return &models.ConstraintError{Err: models.ErrReferenceNotFound, Field: "assigned_to"}
+		}
+	}
+
+	existing.Title = task.Title
+	existing.Description = task.Description
+	existing.DueDate = task.DueDate
+	existing.Priority = task.Priority
+	existing.Status = task.Status
+	existing.AssignedTo = task.AssignedTo
+	existing.ParentID = task.ParentID
+	existing.UpdatedAt = time.Now().UTC()
+	existing.Version++
+	t.store.tasks[task.ID] = existing
+	return nil
+}
+
+func (t *MemoryTaskRepository) DeleteTask(id, version int) error {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	existing, ok := t.store.orgTask(t.orgID, id)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if len(t.store.descendants(id)) > 0 {
+		return models.ErrTaskHasSubtasks
+	}
+	if existing.Version != version {
+		return models.ErrVersionConflict
+	}
+	delete(t.store.tasks, id)
+	delete(t.store.dependencies, id)
+	for _, dependsOn := range t.store.dependencies {
+		delete(dependsOn, id)
+	}
+	for commentID, comment := range t.store.comments {
+		if comment.TaskID == id {
+			delete(t.store.comments, commentID)
+		}
+	}
+	for attachmentID, attachment := range t.store.attachments {
+		if attachment.TaskID == id {
+			delete(t.store.attachments, attachmentID)
+		}
+	}
+	return nil
+}
+
+func (t *MemoryTaskRepository) ListTasks(opts models.ListOptions) (models.TaskPage, error) {
+	return paginateTasks(t.filter(func(models.Task) bool { return true }), opts)
+}
+
+func (t *MemoryTaskRepository) SearchAndFilterTasks(filter models.TaskFilter, opts models.ListOptions) (models.TaskPage, error) {
+	return paginateTasks(t.filter(func(task models.Task) bool {
+		return matchesFilter(task, filter)
+	}), opts)
+}
+
+// FullTextSearchTasks is the tokenizer-based counterpart of the Postgres
+// tsvector search. The title weighs more than the description, as with the
+// A/B weights of the search_vector column.
+func (t *MemoryTaskRepository) FullTextSearchTasks(text string, filter models.TaskFilter, opts models.ListOptions) (models.TaskSearchPage, error) {
+	page := models.TaskSearchPage{Items: []models.TaskSearchHit{}}
+	query := search.Parse(text)
+	if query.Empty() {
+		return page, nil
+	}
+
+	var hits []models.TaskSearchHit
+	for _, task := range t.filter(func(task models.Task) bool { return matchesFilter(task, filter) }) {
+		ok, rank := query.Match(
+			search.Field{Text: task.Title, Weight: 1.0},
+			search.Field{Text: task.Description, Weight: 0.4},
+		)
+		if !ok {
+			continue
+		}
+		hits = append(hits, models.TaskSearchHit{
+			Task:    task,
+			Rank:    rank,
+			Snippet: query.Highlight(task.Title+" "+task.Description, 35),
+		})
+	}
+	page.Total = len(hits)
+
+	// Most relevant first, newest first among equals, like ORDER BY rank DESC, id DESC
+	before := func(rankA float64, idA int, rankB float64, idB int) bool {
+		if rankA != rankB {
+			return rankA > rankB
+		}
+		return idA > idB
+	}
+	sort.Slice(hits, func(i, j int) bool { return before(hits[i].Rank, hits[i].ID, hits[j].Rank, hits[j].ID) })
+
+	start := 0
+	if opts.After != "" {
+		cursor, err := utils.DecodeCursor(opts.After, models.SortRank, models.SortDesc)
+		if err != nil {
+			return page, err
+		}
+		cursorRank, err := strconv.ParseFloat(cursor.Value, 64)
+		if err != nil {
+			return page, models.ErrInvalidCursor
+		}
+		start = sort.Search(len(hits), func(i int) bool {
+			return before(cursorRank, cursor.ID, hits[i].Rank, hits[i].ID)
+		})
+	}
+
+	end := start + opts.Limit
+	if end < len(hits) {
+		last := hits[end-1]
+		page.NextCursor = utils.EncodeCursor(utils.Cursor{
+			Sort:  models.SortRank,
+			Order: models.SortDesc,
+			Value: strconv.FormatFloat(last.Rank, 'g', -1, 64),
+			ID:    last.ID,
+		})
+	} else {
+		end = len(hits)
+	}
+	page.Items = append(page.Items, hits[start:end]...)
+	return page, nil
+}
+
+func (t *MemoryTaskRepository) GetUserDashboard(userID int, loc *time.Location) (models.Dashboard, error) {
+	tasks := t.filter(func(task models.Task) bool {
+		return task.CreatedBy == userID || task.AssignedTo == userID
+	})
+	return models.BuildDashboard(tasks, time.Now(), loc), nil
+}
+
+func (t *MemoryTaskRepository) GetProjectDashboard(projectID int, loc *time.Location) (models.Dashboard, error) {
+	tasks := t.filter(func(task models.Task) bool { return task.ProjectID == projectID })
+	return models.BuildDashboard(tasks, time.Now(), loc), nil
+}
+
+func (t *MemoryTaskRepository) ListSubtasks(parentID int) ([]models.Task, error) {
+	return t.filter(func(task models.Task) bool { return task.ParentID == parentID }), nil
+}
+
+func (t *MemoryTaskRepository) GetSubtree(rootID int) ([]models.Task, error) {
+	t.store.mu.RLock()
+	defer t.store.mu.RUnlock()
+
+	tasks := []models.Task{}
+	if _, ok := t.store.orgTask(t.orgID, rootID); !ok {
+		return tasks, nil
+	}
+	for _, task := range t.store.descendants(rootID) {
+		tasks = append(tasks, t.store.derivedTask(task))
+	}
+	return tasks, nil
+}
+
+func (t *MemoryTaskRepository) AddDependency(taskID, dependsOnID int) error {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	task, ok := t.store.orgTask(t.orgID, taskID)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	dependsOn, ok := t.store.orgTask(t.orgID, dependsOnID)
+	if !ok {
+		return &models.ConstraintError{Err: models.ErrReferenceNotFound, Field: "depends_on_id"}
+	}
+	if dependsOn.ProjectID != task.ProjectID {
+		return &models.ConstraintError{Err: models.ErrDependencyProject, Field: "depends_on_id"}
+	}
+	if taskID == dependsOnID || slices.Contains(t.store.blockers(dependsOnID), taskID) {
+		return &models.ConstraintError{Err: models.ErrDependencyCycle, Field: "depends_on_id"}
+	}
+
+	if t.store.dependencies[taskID] == nil {
+		t.store.dependencies[taskID] = make(map[int]bool)
+	}
+	t.store.dependencies[taskID][dependsOnID] = true
+	return nil
+}
+
+func (t *MemoryTaskRepository) RemoveDependency(taskID, dependsOnID int) error {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	if _, ok := t.store.orgTask(t.orgID, taskID); !ok || !t.store.dependencies[taskID][dependsOnID] {
+		return sql.ErrNoRows
+	}
+	delete(t.store.dependencies[taskID], dependsOnID)
+	return nil
+}
+
+func (t *MemoryTaskRepository) ListDependencies(taskID int) ([]models.Task, error) {
+	t.store.mu.RLock()
+	dependsOn := maps.Clone(t.store.dependencies[taskID])
+	t.store.mu.RUnlock()
+
+	return t.filter(func(task models.Task) bool { return dependsOn[task.ID] }), nil
+}
+
+func (t *MemoryTaskRepository) GetCriticalPath(projectID int) ([]models.Task, error) {
+	tasks := t.filter(func(task models.Task) bool {
+		return task.ProjectID == projectID && task.Status != models.StatusDone
+	})
+	return models.CriticalPath(tasks), nil
+}
+
+// filter returns copies of the tasks of the organization matching keep,
+// ordered by ID.
+func (t *MemoryTaskRepository) filter(keep func(models.Task) bool) []models.Task {
+	t.store.mu.RLock()
+	defer t.store.mu.RUnlock()
+
+	// Empty rather than nil, like the Postgres lists, so that it encodes as []
+	tasks := []models.Task{}
+	for _, task := range t.store.tasks {
+		if task.OrgID == t.orgID && keep(task) {
+			tasks = append(tasks, t.store.derivedTask(task))
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+	return tasks
+}
+
+// memorySortKey returns the value a task is ordered by, encoded so that plain
+// string comparison gives the same order as the SQL expressions used by the
+// Postgres backend.
+func memorySortKey(task models.Task, field string) string {
+	switch field {
+	case "due_date":
+		if task.DueDate.IsZero() {
+			return "9999-12-31T00:00:00.000000000Z"
+		}
+		return task.DueDate.Time.UTC().Format(memorySortLayout)
+	case "priority":
+		return strconv.Itoa(task.Priority.Rank())
+	case "created_at":
+		return task.CreatedAt.UTC().Format(memorySortLayout)
+	case "updated_at":
+		return task.UpdatedAt.UTC().Format(memorySortLayout)
+	case "title":
+		return task.Title
+	}
+	return ""
+}
+
+// paginateTasks sorts tasks and cuts out the page described by opts.
+func paginateTasks(tasks []models.Task, opts models.ListOptions) (models.TaskPage, error) {
+	page := models.TaskPage{Items: []models.Task{}, Total: len(tasks)}
+	if _, ok := models.TaskSortFields[opts.Sort]; !ok {
+		return page, fmt.Errorf("unsupported sort field %q", opts.Sort)
+	}
+	desc := opts.Order == models.SortDesc
+
+	less := func(keyA string, idA int, keyB string, idB int) bool {
+		if keyA != keyB {
+			return keyA < keyB
+		}
+		return idA < idB
+	}
+	before := func(a, b models.Task) bool {
+		ka, kb := memorySortKey(a, opts.Sort), memorySortKey(b, opts.Sort)
+		if desc {
+			return less(kb, b.ID, ka, a.ID)
+		}
+		return less(ka, a.ID, kb, b.ID)
+	}
+	sort.Slice(tasks, func(i, j int) bool { return before(tasks[i], tasks[j]) })
+
+	start := 0
+	if opts.After != "" {
+		cursor, err := utils.DecodeCursor(opts.After, opts.Sort, opts.Order)
+		if err != nil {
+			return page, err
+		}
+		start = sort.Search(len(tasks), func(i int) bool {
+			key := memorySortKey(tasks[i], opts.Sort)
+			if desc {
+				return less(key, tasks[i].ID, cursor.Value, cursor.ID)
+			}
+			return less(cursor.Value, cursor.ID, key, tasks[i].ID)
+		})
+	}
+
+	end := start + opts.Limit
+	if end < len(tasks) {
+		last := tasks[end-1]
+		page.NextCursor = utils.EncodeCursor(utils.Cursor{
+			Sort:  opts.Sort,
+			Order: opts.Order,
+			Value: memorySortKey(last, opts.Sort),
+			ID:    last.ID,
+		})
+	} else {
+		end = len(tasks)
+	}
+	page.Items = append(page.Items, tasks[start:end]...)
+	return page, nil
+}
+
+// matchesFilter applies a TaskFilter with the same semantics as the SQL
+// conditions built by the Postgres backend.
+func matchesFilter(task models.Task, filter models.TaskFilter) bool {
+	if filter.Query != "" && !containsFold(task.Title, filter.Query) && !containsFold(task.Description, filter.Query) {
+		return false
+	}
+	if filter.Title != "" && !containsFold(task.Title, filter.Title) {
+		return false
+	}
+	if len(filter.Status) > 0 && !slices.Contains(filter.Status, task.Status) {
+		return false
+	}
+	if len(filter.Priority) > 0 && !slices.Contains(filter.Priority, task.Priority) {
+		return false
+	}
+	if filter.DueBefore != "" || filter.DueAfter != "" {
+		if task.DueDate.IsZero() {
+			return false
+		}
+		loc := filter.Location
+		if loc == nil {
+			loc = time.UTC
+		}
+		date := task.DueDate.LocalDate(loc).Format("2006-01-02")
+		if filter.DueBefore != "" && date > filter.DueBefore {
+			return false
+		}
+		if filter.DueAfter != "" && date < filter.DueAfter {
+			return false
+		}
+	}
+	if filter.AssignedTo != 0 && task.AssignedTo != filter.AssignedTo {
+		return false
+	}
+	if filter.CreatedBy != 0 && task.CreatedBy != filter.CreatedBy {
+		return false
+	}
+	if filter.ProjectID != 0 && task.ProjectID != filter.ProjectID {
+		return false
+	}
+	if filter.RestrictProjects && task.ProjectID != 0 && !slices.Contains(filter.VisibleProjects, task.ProjectID) {
+		return false
+	}
+	return true
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// descendants returns the tasks below taskID at every depth, ordered by ID.
+// The caller must hold mu.
+func (s *MemoryStore) descendants(taskID int) []models.Task {
+	below := map[int]bool{taskID: true}
+	var tasks []models.Task
+	for grew := true; grew; {
+		grew = false
+		for _, task := range s.tasks {
+			if task.ParentID != 0 && below[task.ParentID] && !below[task.ID] {
+				below[task.ID] = true
+				tasks = append(tasks, task)
+				grew = true
+			}
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+	return tasks
+}
+
+// blockers returns the IDs of every task taskID waits for, directly or
+// through other tasks. The caller must hold mu.
+func (s *MemoryStore) blockers(taskID int) []int {
+	seen := map[int]bool{}
+	var ids []int
+	pending := []int{taskID}
+	for len(pending) > 0 {
+		id := pending[0]
+		pending = pending[1:]
+		for dependsOn := range s.dependencies[id] {
+			if !seen[dependsOn] {
+				seen[dependsOn] = true
+				ids = append(ids, dependsOn)
+				pending = append(pending, dependsOn)
+			}
+		}
+	}
+	return ids
+}
+
+// derivedTask fills in the read-only fields of task, like the derived
+// columns of the Postgres reads. The caller must hold mu.
+func (s *MemoryStore) derivedTask(task models.Task) models.Task {
+	task.CommentCount = s.commentCount(task.ID)
+	descendants := s.descendants(task.ID)
+	done := 0
+	for _, descendant := range descendants {
+		if descendant.Status == models.StatusDone {
+			done++
+		}
+	}
+	task.Progress = models.NewSubtaskProgress(len(descendants), done)
+
+	task.BlockedBy = nil
+	task.Blocked = false
+	for id := range s.dependencies[task.ID] {
+		task.BlockedBy = append(task.BlockedBy, id)
+		if s.tasks[id].Status != models.StatusDone && task.Status != models.StatusDone {
+			task.Blocked = true
+		}
+	}
+	slices.Sort(task.BlockedBy)
+	return task
+}
+
+// checkParent applies the parent_id rules of the Postgres backend: the
+// parent must be a task of the organization in projectID and neither task
+// id itself nor one of its subtasks. id is 0 for a task being created. The
+// caller must hold mu.
+func (s *MemoryStore) checkParent(orgID, id, parentID, projectID int) error {
+	if parentID == 0 {
+		return nil
+	}
+	parent, ok := s.orgTask(orgID, parentID)
+	if !ok {
+		return &models.ConstraintError{Err: models.ErrReferenceNotFound, Field: "parent_id"}
+	}
+	if parent.ProjectID != projectID {
+		return &models.ConstraintError{Err: models.ErrSubtaskProject, Field: "parent_id"}
+	}
+	if id == 0 {
+		return nil
+	}
+	if parentID == id || slices.ContainsFunc(s.descendants(id), func(task models.Task) bool { return task.ID == parentID }) {
+		return &models.ConstraintError{Err: models.ErrTaskCycle, Field: "parent_id"}
+	}
+	return nil
+}
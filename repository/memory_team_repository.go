@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/naveeshkumar24/internal/models"
+)
+
+// MemoryTeamRepository implements models.TeamInterface on top of a
+// MemoryStore.
+type MemoryTeamRepository struct {
+	store *MemoryStore
+	orgID int
+}
+
+func NewMemoryTeamRepository(store *MemoryStore) *MemoryTeamRepository {
+	return &MemoryTeamRepository{store: store}
+}
+
+// ForOrg returns the repository confined to the organization orgID.
+func (t *MemoryTeamRepository) ForOrg(orgID int) models.TeamInterface {
+	return &MemoryTeamRepository{store: t.store, orgID: orgID}
+}
+
+func (t *MemoryTeamRepository) CreateTeam(team models.Team) (models.Team, error) {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	for _, existing := range t.store.teams {
+		if existing.Name == team.Name && existing.OrgID == t.orgID {
+			return models.Team{}, &models.ConstraintError{Err: models.ErrDuplicate, Field: "name"}
+		}
+	}
+	if team.CreatedBy != 0 {
+		if _, ok := t.store.orgUser(t.orgID, team.CreatedBy); !ok {
+			return models.Team{}, &models.ConstraintError{Err: models.ErrReferenceNotFound, Field: "created_by"}
+		}
+	}
+
+	team.ID = t.store.nextTeamID
+	team.OrgID = t.orgID
+	team.CreatedAt = time.Now().UTC()
+	team.MemberCount = 0
+	t.store.nextTeamID++
+	t.store.teams[team.ID] = team
+	return team, nil
+}
+
+func (t *MemoryTeamRepository) GetTeam(id int) (models.Team, error) {
+	t.store.mu.RLock()
+	defer t.store.mu.RUnlock()
+
+	team, ok := t.store.orgTeam(t.orgID, id)
+	if !ok {
+		return models.Team{}, sql.ErrNoRows
+	}
+	team.MemberCount = len(t.store.teamMembers[id])
+	return team, nil
+}
+
+func (t *MemoryTeamRepository) ListTeams() ([]models.Team, error) {
+	t.store.mu.RLock()
+	defer t.store.mu.RUnlock()
+
+	teams := []models.Team{}
+	for id, team := range t.store.teams {
+		if team.OrgID != t.orgID {
+			continue
+		}
+		team.MemberCount = len(t.store.teamMembers[id])
+		teams = append(teams, team)
+	}
+	sort.Slice(teams, func(i, j int) bool { return teams[i].Name < teams[j].Name })
+	return teams, nil
+}
+
+// DeleteTeam removes the team along with its members and project grants.
+func (t *MemoryTeamRepository) DeleteTeam(id int) error {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	if _, ok := t.store.orgTeam(t.orgID, id); !ok {
+		return sql.ErrNoRows
+	}
+	delete(t.store.teams, id)
+	delete(t.store.teamMembers, id)
+	for _, teams := range t.store.projectTeams {
+		delete(teams, id)
+	}
+	return nil
+}
+
+func (t *MemoryTeamRepository) AddTeamMember(teamID, userID int) error {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	if _, ok := t.store.orgTeam(t.orgID, teamID); !ok {
+		return &models.ConstraintError{Err: models.ErrReferenceNotFound, Field: "team_id"}
+	}
+	if _, ok := t.store.orgUser(t.orgID, userID); !ok {
+		return &models.ConstraintError{Err: models.ErrReferenceNotFound, Field: "user_id"}
+	}
+	if t.store.teamMembers[teamID] == nil {
+		t.store.teamMembers[teamID] = make(map[int]bool)
+	}
+	t.store.teamMembers[teamID][userID] = true
+	return nil
+}
+
+func (t *MemoryTeamRepository) RemoveTeamMember(teamID, userID int) error {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	if _, ok := t.store.orgTeam(t.orgID, teamID); !ok || !t.store.teamMembers[teamID][userID] {
+		return sql.ErrNoRows
+	}
+	delete(t.store.teamMembers[teamID], userID)
+	return nil
+}
+
+func (t *MemoryTeamRepository) ListTeamMembers(teamID int) ([]models.TeamMember, error) {
+	t.store.mu.RLock()
+	defer t.store.mu.RUnlock()
+
+	members := []models.TeamMember{}
+	if _, ok := t.store.orgTeam(t.orgID, teamID); !ok {
+		return members, nil
+	}
+	for userID := range t.store.teamMembers[teamID] {
+		members = append(members, models.TeamMember{
+			TeamID:   teamID,
+			UserID:   userID,
+			Username: t.store.users[userID].Username,
+		})
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Username < members[j].Username })
+	return members, nil
+}
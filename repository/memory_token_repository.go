@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/naveeshkumar24/internal/models"
+)
+
+// MemoryTokenRepository implements models.TokenInterface on top of a MemoryStore.
+type MemoryTokenRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryTokenRepository(store *MemoryStore) *MemoryTokenRepository {
+	return &MemoryTokenRepository{store: store}
+}
+
+func (t *MemoryTokenRepository) CreateRefreshToken(token models.RefreshToken) error {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	t.insert(token)
+	return nil
+}
+
+func (t *MemoryTokenRepository) GetRefreshToken(tokenHash string) (models.RefreshToken, error) {
+	t.store.mu.RLock()
+	defer t.store.mu.RUnlock()
+
+	for _, token := range t.store.tokens {
+		if token.TokenHash == tokenHash {
+			return token, nil
+		}
+	}
+	return models.RefreshToken{}, sql.ErrNoRows
+}
+
+func (t *MemoryTokenRepository) RotateRefreshToken(oldID int, next models.RefreshToken) error {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	old, ok := t.store.tokens[oldID]
+	if !ok || old.RevokedAt != nil {
+		return models.ErrTokenReused
+	}
+
+	nextID := t.insert(next)
+	now := time.Now()
+	old.RevokedAt = &now
+	old.ReplacedBy = &nextID
+	t.store.tokens[oldID] = old
+	return nil
+}
+
+func (t *MemoryTokenRepository) RevokeTokenFamily(familyID string) error {
+	t.revokeWhere(func(token models.RefreshToken) bool { return token.FamilyID == familyID })
+	return nil
+}
+
+func (t *MemoryTokenRepository) RevokeAllUserTokens(userID int) error {
+	t.revokeWhere(func(token models.RefreshToken) bool { return token.UserID == userID })
+	return nil
+}
+
+// insert stores token and returns its new ID. The caller must hold the lock.
+func (t *MemoryTokenRepository) insert(token models.RefreshToken) int {
+	token.ID = t.store.nextTokenID
+	token.CreatedAt = time.Now()
+	t.store.nextTokenID++
+	t.store.tokens[token.ID] = token
+	return token.ID
+}
+
+func (t *MemoryTokenRepository) revokeWhere(match func(models.RefreshToken) bool) {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	now := time.Now()
+	for id, token := range t.store.tokens {
+		if token.RevokedAt == nil && match(token) {
+			token.RevokedAt = &now
+			t.store.tokens[id] = token
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/naveeshkumar24/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MemoryUserRepository implements models.UserInterface on top of a MemoryStore.
+type MemoryUserRepository struct {
+	store *MemoryStore
+	orgID int
+}
+
+func NewMemoryUserRepository(store *MemoryStore) *MemoryUserRepository {
+	return &MemoryUserRepository{store: store}
+}
+
+// ForOrg returns the repository confined to the organization orgID.
+func (u *MemoryUserRepository) ForOrg(orgID int) models.UserInterface {
+	return &MemoryUserRepository{store: u.store, orgID: orgID}
+}
+
+// Register - Hashes the password and saves the user in memory
+func (u *MemoryUserRepository) Register(user models.User) (models.User, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Printf("Failed to hash password: %v", err)
+		return models.User{}, err
+	}
+	user.Password = string(hashedPassword)
+	user.OrgID = u.orgID
+
+	u.store.mu.Lock()
+	defer u.store.mu.Unlock()
+
+	return u.store.addUser(user)
+}
+
+// Login - Verifies user credentials and returns user data
+func (u *MemoryUserRepository) Login(email, password string) (models.User, error) {
+	u.store.mu.RLock()
+	var user models.User
+	found := false
+	for _, existing := range u.store.users {
+		if existing.Email == email && (u.orgID == 0 || existing.OrgID == u.orgID) {
+			user, found = existing, true
+			break
+		}
+	}
+	u.store.mu.RUnlock()
+
+	if !found {
+		log.Printf("Repository: User with email %s not found", email)
+		return models.User{}, sql.ErrNoRows
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		log.Printf("Repository: Incorrect password for user %s", email)
+		return models.User{}, sql.ErrNoRows
+	}
+
+	return user, nil
+}
+
+// GetUserByID - Retrieves user details by ID
+func (u *MemoryUserRepository) GetUserByID(id int) (models.User, error) {
+	u.store.mu.RLock()
+	defer u.store.mu.RUnlock()
+
+	// The unscoped repository finds users of every organization, like the
+	// Postgres lookup
+	user, ok := u.store.users[id]
+	if !ok || (u.orgID != 0 && user.OrgID != u.orgID) {
+		return models.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+// UpdateRole - Changes the role of an existing user
+func (u *MemoryUserRepository) UpdateRole(id int, role string) error {
+	u.store.mu.Lock()
+	defer u.store.mu.Unlock()
+
+	user, ok := u.store.orgUser(u.orgID, id)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	user.Role = role
+	u.store.users[id] = user
+	return nil
+}
+
+// UpdateTimezone - Changes the time zone preference of an existing user
+func (u *MemoryUserRepository) UpdateTimezone(id int, timezone string) error {
+	u.store.mu.Lock()
+	defer u.store.mu.Unlock()
+
+	user, ok := u.store.orgUser(u.orgID, id)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	user.Timezone = timezone
+	u.store.users[id] = user
+	return nil
+}
+
+// addUser stores a user with a hashed password, enforcing the same unique
+// usernames and emails across organizations as the Postgres schema. The
+// caller must hold mu.
+func (s *MemoryStore) addUser(user models.User) (models.User, error) {
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+	if user.Timezone == "" {
+		user.Timezone = "UTC"
+	}
+
+	for _, existing := range s.users {
+		if existing.Username == user.Username {
+			return models.User{}, fmt.Errorf("error registering user: %w", &models.ConstraintError{Err: models.ErrDuplicate, Field: "username"})
+		}
+		if existing.Email == user.Email {
+			return models.User{}, fmt.Errorf("error registering user: %w", &models.ConstraintError{Err: models.ErrDuplicate, Field: "email"})
+		}
+	}
+
+	user.ID = s.nextUserID
+	s.nextUserID++
+	s.users[user.ID] = user
+	return user, nil
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/naveeshkumar24/internal/models"
+	"github.com/naveeshkumar24/pkg/database"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type OrganizationRepository struct {
+	db *sql.DB
+}
+
+func NewOrganizationRepository(db *sql.DB) *OrganizationRepository {
+	return &OrganizationRepository{
+		db: db,
+	}
+}
+
+// CreateOrganization - Hashes the admin's password and saves the
+// organization together with its admin
+func (o *OrganizationRepository) CreateOrganization(org models.Organization, admin models.User) (models.Organization, models.User, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(admin.Password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Printf("Failed to hash password: %v", err)
+		return models.Organization{}, models.User{}, err
+	}
+	admin.Password = string(hashedPassword)
+
+	query := database.NewQuery(o.db)
+	defer query.Close()
+	created, admin, err := query.CreateOrganization(org, admin)
+	if err != nil {
+		log.Printf("Repository: Failed to create organization: %v", err)
+		return models.Organization{}, models.User{}, err
+	}
+	return created, admin, nil
+}
+
+func (o *OrganizationRepository) GetOrganization(id int) (models.Organization, error) {
+	query := database.NewQuery(o.db)
+	defer query.Close()
+	org, err := query.GetOrganization(id)
+	if err != nil {
+		log.Printf("Repository: Failed to get organization: %v", err)
+		return models.Organization{}, err
+	}
+	return org, nil
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/naveeshkumar24/internal/models"
+	"github.com/naveeshkumar24/pkg/database"
+)
+
+type ProjectRepository struct {
+	db    *sql.DB
+	orgID int
+}
+
+func NewProjectRepository(db *sql.DB) *ProjectRepository {
+	return &ProjectRepository{
+		db: db,
+	}
+}
+
+// ForOrg returns the repository confined to the organization orgID.
+func (p *ProjectRepository) ForOrg(orgID int) models.ProjectInterface {
+	return &ProjectRepository{db: p.db, orgID: orgID}
+}
+
+func (p *ProjectRepository) CreateProject(project models.Project) (models.Project, error) {
+	query := database.NewOrgQuery(p.db, p.orgID)
+	defer query.Close()
+	created, err := query.CreateProject(project)
+	if err != nil {
+		log.Printf("Repository: Failed to create project: %v", err)
+		return models.Project{}, err
+	}
+	return created, nil
+}
+
+func (p *ProjectRepository) GetProject(id int) (models.Project, error) {
+	query := database.NewOrgQuery(p.db, p.orgID)
+	defer query.Close()
+	project, err := query.GetProject(id)
+	if err != nil {
+		log.Printf("Repository: Failed to fetch project by ID: %v", err)
+		return models.Project{}, err
+	}
+	return project, nil
+}
+
+func (p *ProjectRepository) ListProjects(includeArchived bool) ([]models.Project, error) {
+	query := database.NewOrgQuery(p.db, p.orgID)
+	defer query.Close()
+	projects, err := query.ListProjects(includeArchived)
+	if err != nil {
+		log.Printf("Repository: Failed to list projects: %v", err)
+		return nil, err
+	}
+	return projects, nil
+}
+
+func (p *ProjectRepository) UpdateProject(project models.Project) (models.Project, error) {
+	query := database.NewOrgQuery(p.db, p.orgID)
+	defer query.Close()
+	updated, err := query.UpdateProject(project)
+	if err != nil {
+		log.Printf("Repository: Failed to update project: %v", err)
+		return models.Project{}, err
+	}
+	return updated, nil
+}
+
+func (p *ProjectRepository) SetProjectArchived(id int, archived bool) (models.Project, error) {
+	query := database.NewOrgQuery(p.db, p.orgID)
+	defer query.Close()
+	project, err := query.SetProjectArchived(id, archived)
+	if err != nil {
+		log.Printf("Repository: Failed to change project archive state: %v", err)
+		return models.Project{}, err
+	}
+	return project, nil
+}
+
+func (p *ProjectRepository) DeleteProject(id int) error {
+	query := database.NewOrgQuery(p.db, p.orgID)
+	defer query.Close()
+	err := query.DeleteProject(id)
+	if err != nil {
+		log.Printf("Repository: Failed to delete project: %v", err)
+		return err
+	}
+	return nil
+}
@@ -3,14 +3,15 @@ package repository
 import (
 	"database/sql"
 	"log"
-	"net/http"
+	"time"
 
 	"github.com/naveeshkumar24/internal/models"
 	"github.com/naveeshkumar24/pkg/database"
 )
 
 type TaskRepository struct {
-	db *sql.DB
+	db    *sql.DB
+	orgID int
 }
 
 func NewTaskRepository(db *sql.DB) *TaskRepository {
@@ -19,18 +20,25 @@ func NewTaskRepository(db *sql.DB) *TaskRepository {
 	}
 }
 
-func (t *TaskRepository) CreateTask(task models.Task) error {
-	query := database.NewQuery(t.db)
-	err := query.CreateTask(task)
+// ForOrg returns the repository confined to the organization orgID.
+func (t *TaskRepository) ForOrg(orgID int) models.TaskInterface {
+	return &TaskRepository{db: t.db, orgID: orgID}
+}
+
+func (t *TaskRepository) CreateTask(task models.Task) (models.Task, error) {
+	query := database.NewOrgQuery(t.db, t.orgID)
+	defer query.Close()
+	created, err := query.CreateTask(task)
 	if err != nil {
 		log.Printf("Repository: Failed to create task: %v", err)
-		return err
+		return models.Task{}, err
 	}
-	return nil
+	return created, nil
 }
 
 func (t *TaskRepository) GetTaskByID(id int) (models.Task, error) {
-	query := database.NewQuery(t.db)
+	query := database.NewOrgQuery(t.db, t.orgID)
+	defer query.Close()
 	task, err := query.GetTaskByID(id)
 	if err != nil {
 		log.Printf("Repository: Failed to fetch task by ID: %v", err)
@@ -39,9 +47,21 @@ func (t *TaskRepository) GetTaskByID(id int) (models.Task, error) {
 	return task, nil
 }
 
-func (t *TaskRepository) UpdateTask(task models.Task) error {
-	query := database.NewQuery(t.db)
-	err := query.UpdateTask(task)
+func (t *TaskRepository) GetTaskByKey(key string) (models.Task, error) {
+	query := database.NewOrgQuery(t.db, t.orgID)
+	defer query.Close()
+	task, err := query.GetTaskByKey(key)
+	if err != nil {
+		log.Printf("Repository: Failed to fetch task by key: %v", err)
+		return models.Task{}, err
+	}
+	return task, nil
+}
+
+func (t *TaskRepository) UpdateTask(task models.Task, check func(stored models.Task) error) error {
+	query := database.NewOrgQuery(t.db, t.orgID)
+	defer query.Close()
+	err := query.UpdateTask(task, check)
 	if err != nil {
 		log.Printf("Repository: Failed to update task: %v", err)
 		return err
@@ -49,9 +69,10 @@ func (t *TaskRepository) UpdateTask(task models.Task) error {
 	return nil
 }
 
-func (t *TaskRepository) DeleteTask(id int) error {
-	query := database.NewQuery(t.db)
-	err := query.DeleteTask(id)
+func (t *TaskRepository) DeleteTask(id, version int) error {
+	query := database.NewOrgQuery(t.db, t.orgID)
+	defer query.Close()
+	err := query.DeleteTask(id, version)
 	if err != nil {
 		log.Printf("Repository: Failed to delete task: %v", err)
 		return err
@@ -59,32 +80,121 @@ func (t *TaskRepository) DeleteTask(id int) error {
 	return nil
 }
 
-func (t *TaskRepository) ListTasks(r *http.Request) ([]models.Task, error) {
-	query := database.NewQuery(t.db)
-	tasks, err := query.ListTasks()
+func (t *TaskRepository) ListTasks(opts models.ListOptions) (models.TaskPage, error) {
+	query := database.NewOrgQuery(t.db, t.orgID)
+	defer query.Close()
+	page, err := query.ListTasks(opts)
 	if err != nil {
 		log.Printf("Repository: Failed to list tasks: %v", err)
+		return models.TaskPage{}, err
+	}
+	return page, nil
+}
+
+func (t *TaskRepository) SearchAndFilterTasks(filter models.TaskFilter, opts models.ListOptions) (models.TaskPage, error) {
+	query := database.NewOrgQuery(t.db, t.orgID)
+	defer query.Close()
+	page, err := query.SearchAndFilterTasks(filter, opts)
+	if err != nil {
+		log.Printf("Repository: Failed to search/filter tasks: %v", err)
+		return models.TaskPage{}, err
+	}
+	return page, nil
+}
+
+func (t *TaskRepository) FullTextSearchTasks(text string, filter models.TaskFilter, opts models.ListOptions) (models.TaskSearchPage, error) {
+	query := database.NewOrgQuery(t.db, t.orgID)
+	defer query.Close()
+	page, err := query.FullTextSearchTasks(text, filter, opts)
+	if err != nil {
+		log.Printf("Repository: Failed to run full-text search: %v", err)
+		return models.TaskSearchPage{}, err
+	}
+	return page, nil
+}
+
+func (t *TaskRepository) GetUserDashboard(userID int, loc *time.Location) (models.Dashboard, error) {
+	query := database.NewOrgQuery(t.db, t.orgID)
+	defer query.Close()
+	dashboardData, err := query.GetUserDashboard(userID, loc)
+	if err != nil {
+		log.Printf("Repository: Failed to get user dashboard: %v", err)
+		return models.Dashboard{}, err
+	}
+	return dashboardData, nil
+}
+
+func (t *TaskRepository) GetProjectDashboard(projectID int, loc *time.Location) (models.Dashboard, error) {
+	query := database.NewOrgQuery(t.db, t.orgID)
+	defer query.Close()
+	dashboardData, err := query.GetProjectDashboard(projectID, loc)
+	if err != nil {
+		log.Printf("Repository: Failed to get project dashboard: %v", err)
+		return models.Dashboard{}, err
+	}
+	return dashboardData, nil
+}
+
+func (t *TaskRepository) ListSubtasks(parentID int) ([]models.Task, error) {
+	query := database.NewOrgQuery(t.db, t.orgID)
+	defer query.Close()
+	tasks, err := query.ListSubtasks(parentID)
+	if err != nil {
+		log.Printf("Repository: Failed to list subtasks: %v", err)
 		return nil, err
 	}
 	return tasks, nil
 }
 
-func (t *TaskRepository) SearchAndFilterTasks(filter models.TaskFilter) ([]models.Task, error) {
-	query := database.NewQuery(t.db)
-	tasks, err := query.SearchAndFilterTasks(filter)
+func (t *TaskRepository) GetSubtree(rootID int) ([]models.Task, error) {
+	query := database.NewOrgQuery(t.db, t.orgID)
+	defer query.Close()
+	tasks, err := query.GetSubtree(rootID)
 	if err != nil {
-		log.Printf("Repository: Failed to search/filter tasks: %v", err)
+		log.Printf("Repository: Failed to fetch task subtree: %v", err)
 		return nil, err
 	}
 	return tasks, nil
 }
 
-func (t *TaskRepository) GetUserDashboard(userID int) (map[string][]models.Task, error) {
-	query := database.NewQuery(t.db)
-	dashboardData, err := query.GetUserDashboard(userID)
+func (t *TaskRepository) AddDependency(taskID, dependsOnID int) error {
+	query := database.NewOrgQuery(t.db, t.orgID)
+	defer query.Close()
+	if err := query.AddDependency(taskID, dependsOnID); err != nil {
+		log.Printf("Repository: Failed to add task dependency: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (t *TaskRepository) RemoveDependency(taskID, dependsOnID int) error {
+	query := database.NewOrgQuery(t.db, t.orgID)
+	defer query.Close()
+	if err := query.RemoveDependency(taskID, dependsOnID); err != nil {
+		log.Printf("Repository: Failed to remove task dependency: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (t *TaskRepository) ListDependencies(taskID int) ([]models.Task, error) {
+	query := database.NewOrgQuery(t.db, t.orgID)
+	defer query.Close()
+	tasks, err := query.ListDependencies(taskID)
 	if err != nil {
-		log.Printf("Repository: Failed to get user dashboard: %v", err)
+		log.Printf("Repository: Failed to list task dependencies: %v", err)
 		return nil, err
 	}
-	return dashboardData, nil
+	return tasks, nil
+}
+
+func (t *TaskRepository) GetCriticalPath(projectID int) ([]models.Task, error) {
+	query := database.NewOrgQuery(t.db, t.orgID)
+	defer query.Close()
+	tasks, err := query.GetCriticalPath(projectID)
+	if err != nil {
+		log.Printf("Repository: Failed to get critical path: %v", err)
+		return nil, err
+	}
+	return tasks, nil
 }
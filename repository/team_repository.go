@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/naveeshkumar24/internal/models"
+	"github.com/naveeshkumar24/pkg/database"
+)
+
+type TeamRepository struct {
+	db    *sql.DB
+	orgID int
+}
+
+func NewTeamRepository(db *sql.DB) *TeamRepository {
+	return &TeamRepository{
+		db: db,
+	}
+}
+
+// ForOrg returns the repository confined to the organization orgID.
+func (t *TeamRepository) ForOrg(orgID int) models.TeamInterface {
+	return &TeamRepository{db: t.db, orgID: orgID}
+}
+
+func (t *TeamRepository) CreateTeam(team models.Team) (models.Team, error) {
+	query := database.NewOrgQuery(t.db, t.orgID)
+	defer query.Close()
+	created, err := query.CreateTeam(team)
+	if err != nil {
+		log.Printf("Repository: Failed to create team: %v", err)
+		return models.Team{}, err
+	}
+	return created, nil
+}
+
+func (t *TeamRepository) GetTeam(id int) (models.Team, error) {
+	query := database.NewOrgQuery(t.db, t.orgID)
+	defer query.Close()
+	team, err := query.GetTeam(id)
+	if err != nil {
+		log.Printf("Repository: Failed to fetch team by ID: %v", err)
+		return models.Team{}, err
+	}
+	return team, nil
+}
+
+func (t *TeamRepository) ListTeams() ([]models.Team, error) {
+	query := database.NewOrgQuery(t.db, t.orgID)
+	defer query.Close()
+	teams, err := query.ListTeams()
+	if err != nil {
+		log.Printf("Repository: Failed to list teams: %v", err)
+		return nil, err
+	}
+	return teams, nil
+}
+
+func (t *TeamRepository) DeleteTeam(id int) error {
+	query := database.NewOrgQuery(t.db, t.orgID)
+	defer query.Close()
+	err := query.DeleteTeam(id)
+	if err != nil {
+		log.Printf("Repository: Failed to delete team: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (t *TeamRepository) AddTeamMember(teamID, userID int) error {
+	query := database.NewOrgQuery(t.db, t.orgID)
+	defer query.Close()
+	err := query.AddTeamMember(teamID, userID)
+	if err != nil {
+		log.Printf("Repository: Failed to add team member: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (t *TeamRepository) RemoveTeamMember(teamID, userID int) error {
+	query := database.NewOrgQuery(t.db, t.orgID)
+	defer query.Close()
+	err := query.RemoveTeamMember(teamID, userID)
+	if err != nil {
+		log.Printf("Repository: Failed to remove team member: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (t *TeamRepository) ListTeamMembers(teamID int) ([]models.TeamMember, error) {
+	query := database.NewOrgQuery(t.db, t.orgID)
+	defer query.Close()
+	members, err := query.ListTeamMembers(teamID)
+	if err != nil {
+		log.Printf("Repository: Failed to list team members: %v", err)
+		return nil, err
+	}
+	return members, nil
+}
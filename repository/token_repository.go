@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/naveeshkumar24/internal/models"
+	"github.com/naveeshkumar24/pkg/database"
+)
+
+type TokenRepository struct {
+	db *sql.DB
+}
+
+func NewTokenRepository(db *sql.DB) *TokenRepository {
+	return &TokenRepository{db: db}
+}
+
+func (t *TokenRepository) CreateRefreshToken(token models.RefreshToken) error {
+	query := database.NewQuery(t.db)
+	defer query.Close()
+	err := query.CreateRefreshToken(token)
+	if err != nil {
+		log.Printf("Repository: Failed to create refresh token: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (t *TokenRepository) GetRefreshToken(tokenHash string) (models.RefreshToken, error) {
+	query := database.NewQuery(t.db)
+	defer query.Close()
+	token, err := query.GetRefreshToken(tokenHash)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Repository: Failed to get refresh token: %v", err)
+		}
+		return models.RefreshToken{}, err
+	}
+	return token, nil
+}
+
+func (t *TokenRepository) RotateRefreshToken(oldID int, next models.RefreshToken) error {
+	query := database.NewQuery(t.db)
+	defer query.Close()
+	err := query.RotateRefreshToken(oldID, next)
+	if err != nil {
+		log.Printf("Repository: Failed to rotate refresh token %d: %v", oldID, err)
+		return err
+	}
+	return nil
+}
+
+func (t *TokenRepository) RevokeTokenFamily(familyID string) error {
+	query := database.NewQuery(t.db)
+	defer query.Close()
+	err := query.RevokeTokenFamily(familyID)
+	if err != nil {
+		log.Printf("Repository: Failed to revoke token family: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (t *TokenRepository) RevokeAllUserTokens(userID int) error {
+	query := database.NewQuery(t.db)
+	defer query.Close()
+	err := query.RevokeAllUserTokens(userID)
+	if err != nil {
+		log.Printf("Repository: Failed to revoke user tokens: %v", err)
+		return err
+	}
+	return nil
+}
@@ -10,35 +10,43 @@ import (
 )
 
 type UserRepository struct {
-	db *sql.DB
+	db    *sql.DB
+	orgID int
 }
 
 func NewUserRepository(db *sql.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
+// ForOrg returns the repository confined to the organization orgID.
+func (u *UserRepository) ForOrg(orgID int) models.UserInterface {
+	return &UserRepository{db: u.db, orgID: orgID}
+}
+
 // Register - Hashes the password and saves the user to the database
-func (u *UserRepository) Register(user models.User) error {
+func (u *UserRepository) Register(user models.User) (models.User, error) {
 	// Hash the password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
 		log.Printf("Failed to hash password: %v", err)
-		return err
+		return models.User{}, err
 	}
 	user.Password = string(hashedPassword)
 
-	query := database.NewQuery(u.db)
-	err = query.RegisterUser(user)
+	query := database.NewOrgQuery(u.db, u.orgID)
+	defer query.Close()
+	created, err := query.RegisterUser(user)
 	if err != nil {
 		log.Printf("Repository: Failed to register user: %v", err)
-		return err
+		return models.User{}, err
 	}
-	return nil
+	return created, nil
 }
 
 // Login - Verifies user credentials and returns user data
 func (u *UserRepository) Login(email, password string) (models.User, error) {
-	query := database.NewQuery(u.db)
+	query := database.NewOrgQuery(u.db, u.orgID)
+	defer query.Close()
 	user, err := query.GetUserByEmail(email)
 	if err != nil {
 		// Check if the error is sql.ErrNoRows (when user is not found)
@@ -63,7 +71,8 @@ func (u *UserRepository) Login(email, password string) (models.User, error) {
 
 // GetUserByID - Retrieves user details by ID
 func (u *UserRepository) GetUserByID(id int) (models.User, error) {
-	query := database.NewQuery(u.db)
+	query := database.NewOrgQuery(u.db, u.orgID)
+	defer query.Close()
 	user, err := query.GetUserByID(id)
 	if err != nil {
 		log.Printf("Repository: Failed to get user by ID: %v", err)
@@ -71,3 +80,27 @@ func (u *UserRepository) GetUserByID(id int) (models.User, error) {
 	}
 	return user, nil
 }
+
+// UpdateRole - Changes the role of an existing user
+func (u *UserRepository) UpdateRole(id int, role string) error {
+	query := database.NewOrgQuery(u.db, u.orgID)
+	defer query.Close()
+	err := query.UpdateUserRole(id, role)
+	if err != nil {
+		log.Printf("Repository: Failed to update user role: %v", err)
+		return err
+	}
+	return nil
+}
+
+// UpdateTimezone - Changes the time zone preference of an existing user
+func (u *UserRepository) UpdateTimezone(id int, timezone string) error {
+	query := database.NewOrgQuery(u.db, u.orgID)
+	defer query.Close()
+	err := query.UpdateUserTimezone(id, timezone)
+	if err != nil {
+		log.Printf("Repository: Failed to update user timezone: %v", err)
+		return err
+	}
+	return nil
+}